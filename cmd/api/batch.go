@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	maxBatchOperations = 100
+
+	batchModeAtomic  = "atomic"
+	batchModePartial = "partial"
+
+	batchOpCreate = "create"
+	batchOpUpdate = "update"
+	batchOpDelete = "delete"
+)
+
+// errBatchOperationFailed is used to roll back the savepoint of a failed operation.
+var errBatchOperationFailed = errors.New("batch operation failed")
+
+// batchOperation is a single operation of a batch request.
+// ID and Version are required to update and delete, Movie to create and update.
+// Force creates the movie even if it's likely a duplicate.
+type batchOperation struct {
+	Op      string          `json:"op"`
+	ID      int64           `json:"id"`
+	Version int32           `json:"version"`
+	Movie   json.RawMessage `json:"movie"`
+	Force   bool            `json:"force"`
+}
+
+// batchResult reports the outcome of a single operation, with the status code
+// the equivalent single request would have returned.
+type batchResult struct {
+	Index  int         `json:"index"`
+	Op     string      `json:"op"`
+	Status int         `json:"status"`
+	Movie  *data.Movie `json:"movie,omitempty"`
+	Error  *batchError `json:"error,omitempty"`
+
+	Duplicates []*data.Movie `json:"duplicates,omitempty"`
+}
+
+// batchError describes why an operation failed, like the problem details
+// of the equivalent single request.
+type batchError struct {
+	Code   string     `json:"code"`
+	Detail string     `json:"detail"`
+	Errors []envelope `json:"errors,omitempty"`
+}
+
+func (result batchResult) failed() bool {
+	return result.Status >= http.StatusBadRequest
+}
+
+//nolint:funlen,cyclop
+func (app *application) batchMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Mode       string           `json:"mode"`
+		Operations []batchOperation `json:"operations"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Mode == "" {
+		input.Mode = batchModeAtomic
+	}
+
+	validate := validator.New()
+
+	if validateBatch(validate, input.Mode, input.Operations); !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), writeTimeout)
+	defer cancel()
+
+	tx, err := app.models.Movies.BeginTx(ctx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	results := make([]batchResult, 0, len(input.Operations))
+	status := http.StatusOK
+
+	for i, operation := range input.Operations {
+		// In atomic mode, the operations following a failure are not run.
+		if status != http.StatusOK {
+			results = append(results, batchResult{Index: i, Op: operation.Op, Status: http.StatusFailedDependency})
+			continue
+		}
+
+		var result batchResult
+
+		err = data.WithSavepoint(ctx, tx, func() error {
+			result = app.runBatchOperation(ctx, r, tx, operation, genres)
+			if result.failed() {
+				return errBatchOperationFailed
+			}
+
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBatchOperationFailed) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		result.Index = i
+		results = append(results, result)
+
+		if result.failed() && input.Mode == batchModeAtomic {
+			status = result.Status
+		}
+	}
+
+	committed := status == http.StatusOK
+
+	if committed {
+		if err = tx.Commit(); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", messageLanguage(r))
+
+	err = app.writeResponse(w, r, status, envelope{"mode": input.Mode, "committed": committed, "results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func validateBatch(validate *validator.Validator, mode string, operations []batchOperation) {
+	validate.CheckError(validator.PermittedValue(mode, batchModeAtomic, batchModePartial), "mode",
+		validator.OneOf(batchModeAtomic, batchModePartial))
+	validate.CheckError(len(operations) > 0, "operations", validator.MinItems(1))
+	validate.CheckError(len(operations) <= maxBatchOperations, "operations", validator.MaxItems(maxBatchOperations))
+
+	validator.Each(validate, "operations", operations, func(validate *validator.Validator, _ int, operation batchOperation) {
+		switch operation.Op {
+		case batchOpCreate:
+			validate.CheckError(operation.Movie != nil, "movie", validator.Required())
+		case batchOpUpdate:
+			validate.CheckError(operation.Movie != nil, "movie", validator.Required())
+			validate.CheckError(operation.ID > 0, "id", validator.GreaterThan(0))
+			validate.CheckError(operation.Version > 0, "version", validator.GreaterThan(0))
+		case batchOpDelete:
+			validate.CheckError(operation.ID > 0, "id", validator.GreaterThan(0))
+			validate.CheckError(operation.Version > 0, "version", validator.GreaterThan(0))
+		default:
+			validate.Add("op", validator.OneOf(batchOpCreate, batchOpUpdate, batchOpDelete))
+		}
+	})
+}
+
+// runBatchOperation runs a single operation in tx and reports its outcome.
+// Unexpected errors are logged and reported as internal server errors.
+//
+//nolint:cyclop
+func (app *application) runBatchOperation(
+	ctx context.Context, r *http.Request, tx *sqlx.Tx, operation batchOperation, genres data.GenreVocabulary,
+) batchResult {
+	result := batchResult{Op: operation.Op}
+	userID := app.contextGetUser(r).ID
+
+	var (
+		movie *data.Movie
+		err   error
+	)
+
+	switch operation.Op {
+	case batchOpCreate:
+		var doc movieDocument
+
+		if err = decodeBatchMovie(operation.Movie, &doc); err != nil {
+			return result.withProblem(r, problem{
+				status: http.StatusBadRequest,
+				code:   codeMalformedRequest,
+				params: map[string]any{"error": err.Error()},
+			})
+		}
+
+		movie = &data.Movie{Title: doc.Title, Year: doc.Year, Runtime: doc.Runtime, Genres: doc.Genres}
+		if failed, ok := validateBatchMovie(r, result, movie, genres); !ok {
+			return failed
+		}
+
+		if !operation.Force {
+			var duplicates [][]*data.Movie
+
+			duplicates, err = app.models.Movies.FindDuplicatesTx(ctx, tx, []*data.Movie{movie})
+			if err == nil && len(duplicates[0]) > 0 {
+				result.Duplicates = duplicates[0]
+
+				return result.withProblem(r, problem{status: http.StatusConflict, code: codeMovieDuplicate})
+			}
+		}
+
+		if err == nil {
+			err = app.models.Movies.InsertTx(ctx, tx, movie, userID)
+		}
+
+		result.Status = http.StatusCreated
+	case batchOpUpdate:
+		var update movieUpdate
+
+		if err = decodeBatchMovie(operation.Movie, &update); err != nil {
+			return result.withProblem(r, problem{
+				status: http.StatusBadRequest,
+				code:   codeMalformedRequest,
+				params: map[string]any{"error": err.Error()},
+			})
+		}
+
+		movie, err = app.getBatchMovie(ctx, tx, operation)
+		if err != nil {
+			break
+		}
+
+		update.apply(movie)
+
+		if err = app.embedUpcomingReleases(movie); err != nil {
+			break
+		}
+
+		if failed, ok := validateBatchMovie(r, result, movie, genres); !ok {
+			return failed
+		}
+
+		err = app.models.Movies.UpdateTx(ctx, tx, movie, userID)
+		result.Status = http.StatusOK
+	case batchOpDelete:
+		movie, err = app.getBatchMovie(ctx, tx, operation)
+		if err != nil {
+			break
+		}
+
+		err = app.models.Movies.DeleteTx(ctx, tx, operation.ID, operation.Version)
+		movie = nil
+		result.Status = http.StatusOK
+	}
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return result.withProblem(r, problem{status: http.StatusNotFound, code: codeMovieNotFound})
+	case errors.Is(err, data.ErrEditConflict):
+		return result.withProblem(r, problem{status: http.StatusConflict, code: codeEditConflict})
+	case err != nil:
+		app.logError(r, err)
+
+		return result.withProblem(r, problem{status: http.StatusInternalServerError, code: codeServerError})
+	}
+
+	result.Movie = movie
+
+	return result
+}
+
+// getBatchMovie locks the movie targeted by the operation, and checks that
+// its version matches.
+func (app *application) getBatchMovie(ctx context.Context, tx *sqlx.Tx, operation batchOperation) (*data.Movie, error) {
+	movie, err := app.models.Movies.GetTx(ctx, tx, operation.ID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if movie.Version != operation.Version {
+		return nil, data.ErrEditConflict
+	}
+
+	return movie, nil
+}
+
+// withProblem reports the problem as the error of the operation,
+// in the language preferred by the client.
+func (result batchResult) withProblem(r *http.Request, p problem) batchResult {
+	language := messageLanguage(r)
+
+	result.Status = p.status
+	result.Error = &batchError{Code: p.code, Detail: p.detail(language)}
+
+	if p.fields != nil {
+		result.Error.Errors = fieldErrors(localizeFieldErrors(language, p.fields))
+	}
+
+	return result
+}
+
+func validateBatchMovie(
+	r *http.Request, result batchResult, movie *data.Movie, genres data.GenreVocabulary,
+) (batchResult, bool) {
+	validate := validator.New()
+
+	if data.ValidateMovie(validate, movie, genres); !validate.Valid() {
+		return result.withProblem(r, problem{
+			status: http.StatusUnprocessableEntity,
+			code:   codeValidationFailed,
+			fields: validate.Errors,
+		}), false
+	}
+
+	return result, true
+}
+
+func decodeBatchMovie(raw json.RawMessage, dst any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestValidateBatch(t *testing.T) {
+	t.Parallel()
+
+	movie := json.RawMessage(`{"title": "Moana"}`)
+
+	tests := []struct {
+		name       string
+		mode       string
+		operations []batchOperation
+		wantErrors []string
+	}{
+		{"valid", batchModeAtomic, []batchOperation{
+			{Op: batchOpCreate, Movie: movie},
+			{Op: batchOpUpdate, ID: 1, Version: 2, Movie: movie},
+			{Op: batchOpDelete, ID: 1, Version: 3},
+		}, nil},
+		{"invalid mode", "sometimes", []batchOperation{{Op: batchOpCreate, Movie: movie}}, []string{"mode"}},
+		{"empty", batchModePartial, nil, []string{"operations"}},
+		{"missing fields", batchModePartial, []batchOperation{
+			{Op: batchOpCreate},
+			{Op: batchOpUpdate, Movie: movie},
+			{Op: "rename"},
+		}, []string{"operations[0].movie", "operations[1].id", "operations[1].version", "operations[2].op"}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			validate := validator.New()
+			validateBatch(validate, test.mode, test.operations)
+
+			if len(validate.Errors) != len(test.wantErrors) {
+				t.Errorf("got errors %v want keys %v", validate.Errors, test.wantErrors)
+			}
+
+			for _, key := range test.wantErrors {
+				if _, found := validate.Errors[validator.Pointer(key)]; !found {
+					t.Errorf("missing error for %q in %v", key, validate.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchResultWithProblem(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/movies/batch", nil)
+	r.Header.Set("Accept-Language", "fr")
+
+	result := batchResult{Op: batchOpUpdate}.withProblem(r, problem{status: http.StatusConflict, code: codeEditConflict})
+
+	want, _ := message("fr", codeEditConflict, nil)
+	if result.Status != http.StatusConflict || result.Error.Code != codeEditConflict || result.Error.Detail != want {
+		t.Errorf("got %+v with error %+v", result, result.Error)
+	}
+
+	fields := validator.Errors{"/title": {validator.Required()}}
+	result = batchResult{Op: batchOpCreate}.withProblem(r, problem{
+		status: http.StatusUnprocessableEntity, code: codeValidationFailed, fields: fields,
+	})
+
+	if len(result.Error.Errors) != 1 || result.Error.Errors[0]["code"] != validator.CodeRequired ||
+		result.Error.Errors[0]["detail"] != "doit être renseigné" {
+		t.Errorf("got errors %+v", result.Error.Errors)
+	}
+}
@@ -9,9 +9,20 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey          = contextKey("user")
+	runtimeFormatContextKey = contextKey("runtimeFormat")
+	requestIDContextKey     = contextKey("requestID")
+	accessLogContextKey     = contextKey("accessLog")
+	routePatternContextKey  = contextKey("routePattern")
+)
 
+// contextSetUser also records the user in the access log entry of the request, if any.
 func (*application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	if entry, ok := r.Context().Value(accessLogContextKey).(*accessLogEntry); ok {
+		entry.user = user
+	}
+
 	return r.WithContext(context.WithValue(r.Context(), userContextKey, user))
 }
 
@@ -23,3 +34,47 @@ func (*application) contextGetUser(r *http.Request) *data.User {
 
 	return user
 }
+
+func (*application) contextSetRuntimeFormat(r *http.Request, format data.RuntimeFormat) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), runtimeFormatContextKey, format))
+}
+
+// contextGetRuntimeFormat returns the format of runtimes requested by the client,
+// or the zero value for the default format.
+func (*application) contextGetRuntimeFormat(r *http.Request) data.RuntimeFormat {
+	format, _ := r.Context().Value(runtimeFormatContextKey).(data.RuntimeFormat)
+
+	return format
+}
+
+func (*application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id))
+}
+
+// contextGetRequestID returns the ID of the request, or an empty string outside of [application.requestID].
+func (*application) contextGetRequestID(r *http.Request) string {
+	return requestIDFromContext(r.Context())
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+
+	return id
+}
+
+// contextSetRoutePattern also records the pattern in the access log entry of the request, if any.
+func contextSetRoutePattern(r *http.Request, pattern string) *http.Request {
+	if entry, ok := r.Context().Value(accessLogContextKey).(*accessLogEntry); ok {
+		entry.route = pattern
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), routePatternContextKey, pattern))
+}
+
+// routePatternFromContext returns the pattern of the route handling the request, like /v1/movies/:id,
+// or an empty string if no route matched.
+func routePatternFromContext(ctx context.Context) string {
+	pattern, _ := ctx.Value(routePatternContextKey).(string)
+
+	return pattern
+}
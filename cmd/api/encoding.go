@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types of the representations sent and read by the API.
+const (
+	mediaTypeJSON        = "application/json"
+	mediaTypeXML         = "application/xml"
+	mediaTypeCSV         = "text/csv"
+	mediaTypeMessagePack = "application/msgpack"
+
+	mediaTypeProblemJSON = "application/problem+json"
+	mediaTypeProblemXML  = "application/problem+xml"
+)
+
+// Errors returned when reading request bodies.
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrMalformedMessagePack = errors.New("body contains malformed MessagePack")
+	ErrMultipleMessagePack  = errors.New("body must only contain a single MessagePack value")
+)
+
+// errNotRepresentable is returned by encoders that can't represent a response, like CSV
+// for responses that aren't lists.
+var errNotRepresentable = errors.New("response can't be represented in this format")
+
+// xmlNameRX matches the keys that can be used as XML element names as is.
+var xmlNameRX = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// A responseEncoder writes response envelopes in a given media type.
+// Problem details are sent with problemMediaType, when the format has one.
+type responseEncoder struct {
+	mediaType        string
+	problemMediaType string
+	aliases          []string
+	encode           func(w io.Writer, env envelope) error
+}
+
+// responseEncoders are listed by order of preference, used when clients accept several
+// media types with the same weight. JSON comes first and is the default.
+var responseEncoders = []responseEncoder{
+	{
+		mediaType:        mediaTypeJSON,
+		problemMediaType: mediaTypeProblemJSON,
+		aliases:          []string{mediaTypeProblemJSON},
+		encode:           encodeJSON,
+	},
+	{
+		mediaType: mediaTypeMessagePack,
+		aliases:   []string{"application/x-msgpack", "application/vnd.msgpack"},
+		encode:    encodeMessagePack,
+	},
+	{
+		mediaType:        mediaTypeXML,
+		problemMediaType: mediaTypeProblemXML,
+		aliases:          []string{"text/xml", mediaTypeProblemXML},
+		encode:           encodeXML,
+	},
+	{mediaType: mediaTypeCSV, encode: encodeCSV},
+}
+
+// requestDecoders map the media types of request bodies to their decoder.
+// Bodies of other media types are read as JSON, see [application.readRequest].
+var requestDecoders = map[string]func(body io.Reader, dst any) error{
+	mediaTypeJSON:             decodeJSON,
+	mediaTypeMessagePack:      decodeMessagePack,
+	"application/x-msgpack":   decodeMessagePack,
+	"application/vnd.msgpack": decodeMessagePack,
+}
+
+// unsupportedRequestTypes are the media types of responses which can't be read in requests:
+// the fields of request bodies are only named for JSON.
+var unsupportedRequestTypes = []string{mediaTypeXML, "text/xml", mediaTypeCSV}
+
+//nolint:gochecknoinits
+func init() {
+	// Dates embed time.Time, whose binary marshaling MessagePack would use
+	// over their text marshaling.
+	msgpack.Register(data.Date{}, encodeMessagePackText, decodeMessagePackText)
+
+	// Movies format their runtime when marshaled to JSON, see [data.Movie.MarshalJSON].
+	msgpack.Register(data.Movie{}, encodeMessagePackJSON, nil)
+	msgpack.Register(data.ScoredMovie{}, encodeMessagePackJSON, nil)
+}
+
+// acceptedEncoders returns the encoders acceptable according to the Accept header,
+// by decreasing preference. Each encoder gets the weight of the most specific media
+// range matching it. All encoders are acceptable when the header is missing.
+func acceptedEncoders(header string) []responseEncoder {
+	if strings.TrimSpace(header) == "" {
+		return responseEncoders
+	}
+
+	type mediaRange struct {
+		mediaType string
+		weight    float64
+	}
+
+	var ranges []mediaRange
+
+	for _, value := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(value)
+		if err != nil {
+			continue
+		}
+
+		weight := 1.0
+
+		if q, found := params["q"]; found {
+			if weight, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, weight: weight})
+	}
+
+	specificity := func(mediaRange, mediaType string) int {
+		mainType, _, _ := strings.Cut(mediaType, "/")
+
+		switch mediaRange {
+		case mediaType:
+			return 2 //nolint:gomnd
+		case mainType + "/*":
+			return 1
+		case "*/*":
+			return 0
+		default:
+			return -1
+		}
+	}
+
+	weights := make(map[string]float64, len(responseEncoders))
+
+	var encoders []responseEncoder
+
+	for _, encoder := range responseEncoders {
+		best, weight := -1, 0.0
+
+		for _, mediaType := range append([]string{encoder.mediaType}, encoder.aliases...) {
+			for _, mediaRange := range ranges {
+				if s := specificity(mediaRange.mediaType, mediaType); s > best {
+					best, weight = s, mediaRange.weight
+				}
+			}
+		}
+
+		if weight > 0 {
+			weights[encoder.mediaType] = weight
+			encoders = append(encoders, encoder)
+		}
+	}
+
+	sort.SliceStable(encoders, func(i, j int) bool {
+		return weights[encoders[i].mediaType] > weights[encoders[j].mediaType]
+	})
+
+	return encoders
+}
+
+// negotiatedMediaType returns the media type preferred by the client, see [acceptedEncoders],
+// or JSON when it accepts none.
+func negotiatedMediaType(r *http.Request) string {
+	encoders := acceptedEncoders(r.Header.Get("Accept"))
+	if len(encoders) == 0 {
+		return mediaTypeJSON
+	}
+
+	return encoders[0].mediaType
+}
+
+func encodeJSON(w io.Writer, env envelope) error {
+	resp, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("encoding to json: %w", err)
+	}
+
+	resp = append(resp, '\n')
+
+	_, err = w.Write(resp)
+
+	return err //nolint:wrapcheck
+}
+
+// encodeMessagePack encodes responses with the same field names as JSON.
+func encodeMessagePack(w io.Writer, env envelope) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+
+	if err := enc.Encode(env); err != nil {
+		return fmt.Errorf("encoding to msgpack: %w", err)
+	}
+
+	return nil
+}
+
+// encodeXML encodes the JSON representation of responses in a <response> element.
+// Object keys become elements, or <entry key="..."> elements when they aren't valid
+// element names, and array values become <item> elements.
+func encodeXML(w io.Writer, env envelope) error {
+	tree, err := decodeOrdered(env)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	enc := xml.NewEncoder(w)
+
+	if err = writeXMLElement(enc, "response", tree); err != nil {
+		return fmt.Errorf("encoding to xml: %w", err)
+	}
+
+	if err = enc.Flush(); err != nil {
+		return fmt.Errorf("encoding to xml: %w", err)
+	}
+
+	_, err = io.WriteString(w, "\n")
+
+	return err //nolint:wrapcheck
+}
+
+func writeXMLElement(enc *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	if !xmlNameRX.MatchString(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	switch value := value.(type) {
+	case object:
+		for _, field := range value {
+			if err := writeXMLElement(enc, field.key, field.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			if err := writeXMLElement(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarText(value))); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return enc.EncodeToken(start.End()) //nolint:wrapcheck
+}
+
+// encodeCSV encodes the list held by a response, with one column per key of the listed
+// objects. Arrays of scalars are joined with commas, and other nested values are written
+// as JSON. Responses holding no list, or several, can't be encoded.
+func encodeCSV(w io.Writer, env envelope) error {
+	tree, err := decodeOrdered(env)
+	if err != nil {
+		return err
+	}
+
+	var (
+		rows  []any
+		lists int
+	)
+
+	for _, field := range tree.(object) { //nolint:forcetypeassert
+		if list, ok := field.value.([]any); ok {
+			rows = list
+			lists++
+		}
+	}
+
+	if lists != 1 {
+		return errNotRepresentable
+	}
+
+	var columns []string
+
+	for _, row := range rows {
+		fields, ok := row.(object)
+		if !ok {
+			return errNotRepresentable
+		}
+
+		for _, field := range fields {
+			if !slices.Contains(columns, field.key) {
+				columns = append(columns, field.key)
+			}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+
+	if len(columns) > 0 {
+		if err = writer.Write(columns); err != nil {
+			return fmt.Errorf("encoding to csv: %w", err)
+		}
+	}
+
+	for _, row := range rows {
+		record := make([]string, len(columns))
+
+		for _, field := range row.(object) { //nolint:forcetypeassert
+			if record[slices.Index(columns, field.key)], err = csvText(field.value); err != nil {
+				return err
+			}
+		}
+
+		if err = writer.Write(record); err != nil {
+			return fmt.Errorf("encoding to csv: %w", err)
+		}
+	}
+
+	writer.Flush()
+
+	if err = writer.Error(); err != nil {
+		return fmt.Errorf("encoding to csv: %w", err)
+	}
+
+	return nil
+}
+
+func csvText(value any) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case object:
+		js, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("encoding to csv: %w", err)
+		}
+
+		return string(js), nil
+	case []any:
+		texts := make([]string, 0, len(value))
+
+		for _, item := range value {
+			switch item.(type) {
+			case object, []any:
+				js, err := json.Marshal(value)
+				if err != nil {
+					return "", fmt.Errorf("encoding to csv: %w", err)
+				}
+
+				return string(js), nil
+			}
+
+			texts = append(texts, scalarText(item))
+		}
+
+		return strings.Join(texts, ","), nil
+	default:
+		return scalarText(value), nil
+	}
+}
+
+// scalarText returns the text of a JSON string, number or boolean.
+func scalarText(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// A field is a member of a JSON object.
+type field struct {
+	key   string
+	value any
+}
+
+// An object is a JSON object whose keys keep their order.
+type object []field
+
+// MarshalJSON implements json.Marshaler.
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, fmt.Errorf("encoding key: %w", err)
+		}
+
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("encoding value: %w", err)
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// decodeOrdered returns the JSON representation of value as generic values:
+// object, []any, string, json.Number, bool or nil.
+func decodeOrdered(value any) (any, error) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encoding to json: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	return readOrdered(dec)
+}
+
+func readOrdered(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("decoding json: %w", err)
+	}
+
+	switch token {
+	case json.Delim('{'):
+		fields := object{}
+
+		for dec.More() {
+			key, keyErr := dec.Token()
+			if keyErr != nil {
+				return nil, fmt.Errorf("decoding json: %w", keyErr)
+			}
+
+			value, valueErr := readOrdered(dec)
+			if valueErr != nil {
+				return nil, valueErr
+			}
+
+			fields = append(fields, field{key: key.(string), value: value}) //nolint:forcetypeassert
+		}
+
+		_, err = dec.Token()
+
+		return fields, err //nolint:wrapcheck
+	case json.Delim('['):
+		items := []any{}
+
+		for dec.More() {
+			item, itemErr := readOrdered(dec)
+			if itemErr != nil {
+				return nil, itemErr
+			}
+
+			items = append(items, item)
+		}
+
+		_, err = dec.Token()
+
+		return items, err //nolint:wrapcheck
+	default:
+		return token, nil
+	}
+}
+
+func decodeJSON(body io.Reader, dst any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return ErrMultipleJSON
+	}
+
+	return nil
+}
+
+// decodeMessagePack decodes request bodies with the same field names as JSON.
+func decodeMessagePack(body io.Reader, dst any) error {
+	dec := msgpack.NewDecoder(body)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+
+	var maxBytesError *http.MaxBytesError
+
+	err := dec.Decode(dst)
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		return fmt.Errorf("%w, max size %d bytes", ErrBodyTooLarge, maxBytes)
+	case errors.Is(err, io.EOF):
+		return ErrEmptyBody
+	case err != nil:
+		return fmt.Errorf("%w: %w", ErrMalformedMessagePack, err)
+	}
+
+	if _, err = dec.DecodeInterface(); !errors.Is(err, io.EOF) {
+		return ErrMultipleMessagePack
+	}
+
+	return nil
+}
+
+func encodeMessagePackText(enc *msgpack.Encoder, value reflect.Value) error {
+	text, err := value.Interface().(encoding.TextMarshaler).MarshalText() //nolint:forcetypeassert
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return enc.EncodeString(string(text)) //nolint:wrapcheck
+}
+
+// encodeMessagePackJSON encodes the JSON representation of values.
+func encodeMessagePackJSON(enc *msgpack.Encoder, value reflect.Value) error {
+	js, err := json.Marshal(value.Interface())
+	if err != nil {
+		return fmt.Errorf("encoding value to json: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var generic any
+
+	if err = dec.Decode(&generic); err != nil {
+		return fmt.Errorf("decoding json value: %w", err)
+	}
+
+	return enc.Encode(numbersToValues(generic)) //nolint:wrapcheck
+}
+
+func decodeMessagePackText(dec *msgpack.Decoder, value reflect.Value) error {
+	text, err := dec.DecodeString()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)) //nolint:forcetypeassert,wrapcheck
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestAcceptedEncoders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{mediaTypeJSON, mediaTypeMessagePack, mediaTypeXML, mediaTypeCSV}},
+		{"text/csv", []string{mediaTypeCSV}},
+		{"text/xml, application/json;q=0.5", []string{mediaTypeXML, mediaTypeJSON}},
+		{"application/*;q=0.8, text/csv", []string{mediaTypeCSV, mediaTypeJSON, mediaTypeMessagePack, mediaTypeXML}},
+		{"*/*, application/xml;q=0", []string{mediaTypeJSON, mediaTypeMessagePack, mediaTypeCSV}},
+		{"image/png", nil},
+	}
+
+	for _, test := range tests {
+		var got []string
+
+		for _, encoder := range acceptedEncoders(test.header) {
+			got = append(got, encoder.mediaType)
+		}
+
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%q: got %v want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	movies := []*data.Movie{
+		{ID: 1, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Drama", "Sci-Fi"}, Version: 1},
+		{ID: 2, Title: "Dune, Part One", Year: 2021, Runtime: 155, Version: 2},
+	}
+
+	tests := []struct {
+		name            string
+		accept          string
+		status          int
+		env             envelope
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "csv list",
+			accept:          "text/csv",
+			status:          http.StatusOK,
+			env:             envelope{"movies": movies, "metadata": data.Metadata{TotalRecords: 2}},
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeCSV,
+			wantBody: "id,title,year,runtime,genres,version\n" +
+				"1,Arrival,2016,116 mins,\"Drama,Sci-Fi\",1\n" +
+				"2,\"Dune, Part One\",2021,155 mins,,2\n",
+		},
+		{
+			name:            "csv single movie",
+			accept:          "text/csv",
+			status:          http.StatusOK,
+			env:             envelope{"movie": movies[0]},
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeJSON,
+		},
+		{
+			name:            "nothing accepted",
+			accept:          "image/png",
+			status:          http.StatusOK,
+			env:             envelope{"movie": movies[0]},
+			wantStatus:      http.StatusNotAcceptable,
+			wantContentType: mediaTypeProblemJSON,
+		},
+		{
+			name:            "csv error",
+			accept:          "text/csv",
+			status:          http.StatusNotFound,
+			env:             envelope{"error": "not found"},
+			wantStatus:      http.StatusNotFound,
+			wantContentType: mediaTypeJSON,
+			wantBody:        `{"error":"not found"}` + "\n",
+		},
+		{
+			name:            "xml",
+			accept:          "application/xml",
+			status:          http.StatusOK,
+			env:             envelope{"movie": movies[0], "errors": map[string]string{"releases[0].date": "invalid"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeXML,
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<response><errors><entry key="releases[0].date">invalid</entry></errors>` +
+				`<movie><id>1</id><title>Arrival</title><year>2016</year><runtime>116 mins</runtime>` +
+				`<genres><item>Drama</item><item>Sci-Fi</item></genres><version>1</version></movie></response>` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", test.accept)
+
+			w := httptest.NewRecorder()
+
+			if err := app.writeResponse(w, r, test.status, test.env, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if w.Code != test.wantStatus {
+				t.Errorf("got status %d want %d", w.Code, test.wantStatus)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("got content type %q want %q", got, test.wantContentType)
+			}
+
+			if test.wantBody != "" && w.Body.String() != test.wantBody {
+				t.Errorf("got body\n%s\nwant\n%s", w.Body.String(), test.wantBody)
+			}
+		})
+	}
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	release := data.Release{
+		Country: "FR",
+		Kind:    data.ReleaseTheatrical,
+		Date:    data.Date{Time: time.Date(2016, 12, 7, 0, 0, 0, 0, time.UTC)},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", mediaTypeMessagePack)
+
+	w := httptest.NewRecorder()
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"movie": &data.Movie{
+		ID: 1, Title: "Arrival", Runtime: 116, Releases: []data.Release{release},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Movie struct {
+			Title    string `msgpack:"title"`
+			Runtime  string `msgpack:"runtime"`
+			Releases []struct {
+				Date string `msgpack:"date"`
+			} `msgpack:"releases"`
+		} `msgpack:"movie"`
+	}
+
+	if err = msgpack.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Movie.Title != "Arrival" || got.Movie.Runtime != "116 mins" ||
+		len(got.Movie.Releases) != 1 || got.Movie.Releases[0].Date != "2016-12-07" {
+		t.Errorf("got %+v", got)
+	}
+
+	body, err := msgpack.Marshal(map[string]any{"runtime": "116 mins", "releases": []any{map[string]any{
+		"country": "FR", "kind": "theatrical", "date": "2016-12-07",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var input struct {
+		Runtime  data.Runtime   `json:"runtime"`
+		Releases []data.Release `json:"releases"`
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", mediaTypeMessagePack)
+
+	if err = app.readRequest(httptest.NewRecorder(), r, &input); err != nil {
+		t.Fatal(err)
+	}
+
+	if input.Runtime != 116 || len(input.Releases) != 1 || !input.Releases[0].Date.Equal(release.Date.Time) {
+		t.Errorf("got %+v", input)
+	}
+}
+
+func TestReadRequestFallsBackToJSON(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	for _, contentType := range []string{"", "application/x-www-form-urlencoded", "text/plain; charset=utf-8"} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"title":"Arrival"}`))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+
+		var input struct {
+			Title string `json:"title"`
+		}
+
+		if err := app.readRequest(httptest.NewRecorder(), r, &input); err != nil || input.Title != "Arrival" {
+			t.Errorf("%q: got %+v, %v", contentType, input, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		method     string
+		accept     string
+		wantStatus int
+		wantCalled bool
+	}{
+		{http.MethodPost, "", http.StatusOK, true},
+		{http.MethodDelete, "text/csv", http.StatusOK, true},
+		{http.MethodPost, "image/png", http.StatusNotAcceptable, false},
+		{http.MethodDelete, "application/xml;q=0", http.StatusNotAcceptable, false},
+		{http.MethodGet, "image/png", http.StatusOK, true},
+	}
+
+	for _, test := range tests {
+		called := false
+
+		handler := app.negotiate(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			called = true
+		}))
+
+		r := httptest.NewRequest(test.method, "/v1/movies/1", nil)
+		r.Header.Set("Accept", test.accept)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.wantStatus || called != test.wantCalled {
+			t.Errorf("%s with %q: got status %d, handled %t want %d, %t",
+				test.method, test.accept, w.Code, called, test.wantStatus, test.wantCalled)
+		}
+	}
+}
+
+func TestReadRequestUnsupportedMediaType(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<movie/>"))
+	r.Header.Set("Content-Type", mediaTypeXML)
+
+	var input struct{}
+
+	if err := app.readRequest(httptest.NewRecorder(), r, &input); !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Errorf("got error %v want %v", err, ErrUnsupportedMediaType)
+	}
+}
@@ -1,82 +1,328 @@
 package main
 
 import (
-	"fmt"
+	"errors"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+// Codes identifying the problems reported by error responses.
+// Clients rely on them, so existing codes must never change.
+const (
+	codeServerError           = "server.error"
+	codeNotFound              = "resource.not_found"
+	codeEditConflict          = "resource.edit_conflict"
+	codeMethodNotAllowed      = "request.method_not_allowed"
+	codeMalformedRequest      = "request.malformed"
+	codeUnsupportedMediaType  = "request.unsupported_media_type"
+	codeNotAcceptable         = "request.not_acceptable"
+	codeValidationFailed      = "request.validation_failed"
+	codePreconditionFailed    = "request.precondition_failed"
+	codePreconditionRequired  = "request.precondition_required"
+	codeRateLimitExceeded     = "request.rate_limit_exceeded"
+	codeMovieNotFound         = "movie.not_found"
+	codeMovieDuplicate        = "movie.duplicate"
+	codeMoviePatchTestFailed  = "movie.patch_test_failed"
+	codeExternalIDTaken       = "movie.external_id_taken"
+	codeGenreInUse            = "genre.in_use"
+	codeListNotCollection     = "list.not_collection"
+	codeInvalidCredentials    = "auth.invalid_credentials"
+	codeTokenInvalid          = "auth.token_invalid"
+	codeAuthenticationMissing = "auth.required"
+	codeAccountInactive       = "auth.account_inactive"
+	codeNotPermitted          = "auth.not_permitted"
 )
 
+// problemTypePrefix prefixes the codes of problems to form their type URI.
+const problemTypePrefix = "urn:greenlight:problem:"
+
+// requestInstancePrefix prefixes the request ID to form the instance URI of problems,
+// so that a reported problem can be traced back to its request in the logs.
+const requestInstancePrefix = "urn:greenlight:request:"
+
+// legacyErrorFormat is the value of the Error-Format header with which clients
+// opt into the {"error": ...} bodies sent before problem details.
+const legacyErrorFormat = "legacy"
+
+// A problem describes an error response, sent as problem details (RFC 9457).
+type problem struct {
+	status int
+	code   string
+
+	// params are substituted in the message of the code, see [message].
+	params map[string]any
+
+	// fields holds the validation errors.
+	fields validator.Errors
+
+	// extensions are additional members of the problem details.
+	extensions envelope
+}
+
+// detail returns the message of the problem in the language, or its code if there's none.
+func (p problem) detail(language string) string {
+	detail, found := message(language, p.code, p.params)
+	if !found {
+		return p.code
+	}
+
+	return detail
+}
+
+// logError logs the error along with the route of the request rather than its URL,
+// which may hold secrets like the tokens of shared lists.
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.Error(err.Error(), "method", r.Method, "url", r.URL.RequestURI())
+	route := routePatternFromContext(r.Context())
+
+	// Middleware which handle errors, like recoverPanic, run before the route is known.
+	if entry, ok := r.Context().Value(accessLogContextKey).(*accessLogEntry); ok && route == "" {
+		route = entry.route
+	}
+
+	app.logger.ErrorContext(r.Context(), err.Error(), "method", r.Method, "route", route)
+}
+
+func (app *application) errorResponse(
+	w http.ResponseWriter, r *http.Request, status int, code string, params map[string]any,
+) {
+	app.problemResponse(w, r, problem{status: status, code: code, params: params})
 }
 
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
-	env := envelope{"error": message}
+// problemResponse sends the problem details, or the legacy error body to clients that opted into it.
+// Messages are sent in the language preferred by the client, see [messageLanguage].
+func (app *application) problemResponse(w http.ResponseWriter, r *http.Request, p problem) {
+	language := messageLanguage(r)
+
+	w.Header().Add("Vary", "Error-Format")
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", language)
+
+	detail := p.detail(language)
+	fields := localizeFieldErrors(language, p.fields)
+
+	requestID := app.contextGetRequestID(r)
+
+	var err error
+
+	if strings.EqualFold(r.Header.Get("Error-Format"), legacyErrorFormat) {
+		env := envelope{"error": detail}
+		if fields != nil {
+			env["error"] = legacyFieldErrors(fields)
+		}
+
+		if requestID != "" {
+			env["requestId"] = requestID
+		}
+
+		maps.Copy(env, p.extensions)
+
+		err = app.writeResponse(w, r, p.status, env, nil)
+	} else {
+		env := envelope{
+			"type":   problemTypePrefix + p.code,
+			"title":  http.StatusText(p.status),
+			"status": p.status,
+			"detail": detail,
+			"code":   p.code,
+		}
+
+		if fields != nil {
+			env["errors"] = fieldErrors(fields)
+		}
+
+		if requestID != "" {
+			env["instance"] = requestInstancePrefix + requestID
+			env["requestId"] = requestID
+		}
+
+		maps.Copy(env, p.extensions)
+
+		err = app.writeProblem(w, r, p.status, env)
+	}
 
-	err := app.writeJSON(w, status, env, nil)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
+// localizeFieldErrors returns the validation errors with their messages in the language.
+// Errors without a message for their code in the language, like the invalid ones, are kept as is.
+func localizeFieldErrors(language string, fields validator.Errors) validator.Errors {
+	if fields == nil {
+		return nil
+	}
+
+	localized := make(validator.Errors, len(fields))
+
+	for pointer, errs := range fields {
+		localized[pointer] = make([]validator.FieldError, 0, len(errs))
+
+		for _, err := range errs {
+			if msg, found := message(language, err.Code, err.Params); found {
+				err.Message = msg
+			}
+
+			localized[pointer] = append(localized[pointer], err)
+		}
+	}
+
+	return localized
+}
+
+// fieldErrors lists the validation errors, ordered by JSON pointer to the invalid field.
+func fieldErrors(fields validator.Errors) []envelope {
+	pointers := make([]string, 0, len(fields))
+	for pointer := range fields {
+		pointers = append(pointers, pointer)
+	}
+
+	slices.Sort(pointers)
+
+	errs := make([]envelope, 0, len(fields))
+
+	for _, pointer := range pointers {
+		for _, err := range fields[pointer] {
+			fieldErr := envelope{"pointer": pointer, "code": err.Code, "detail": err.Message}
+			if err.Params != nil {
+				fieldErr["params"] = err.Params
+			}
+
+			errs = append(errs, fieldErr)
+		}
+	}
+
+	return errs
+}
+
+// legacyFieldErrors returns the first error message of each field, keyed like releases[0].date.
+func legacyFieldErrors(fields validator.Errors) map[string]string {
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	errs := make(map[string]string, len(fields))
+
+	for pointer, fieldErrs := range fields {
+		var key strings.Builder
+
+		for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			if _, err := strconv.Atoi(token); err == nil {
+				key.WriteString("[" + token + "]")
+				continue
+			}
+
+			if key.Len() > 0 {
+				key.WriteString(".")
+			}
+
+			key.WriteString(unescape.Replace(token))
+		}
+
+		errs[key.String()] = fieldErrs[0].Message
+	}
+
+	return errs
+}
+
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.errorResponse(w, r, http.StatusInternalServerError, codeServerError, nil)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, codeNotFound, nil)
+}
+
+func (app *application) movieNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusNotFound, codeMovieNotFound, nil)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, map[string]any{"method": r.Method})
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, err.Error())
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	app.errorResponse(w, r, http.StatusBadRequest, codeMalformedRequest, map[string]any{"error": err.Error()})
 }
 
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType,
+		map[string]any{"contentType": r.Header.Get("Content-Type")})
+}
+
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusNotAcceptable, codeNotAcceptable, map[string]any{"accept": r.Header.Get("Accept")})
+}
+
+func (app *application) patchTestFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusConflict, codeMoviePatchTestFailed, map[string]any{"error": err.Error()})
+}
+
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors validator.Errors) {
+	app.problemResponse(w, r, problem{
+		status: http.StatusUnprocessableEntity,
+		code:   codeValidationFailed,
+		fields: errors,
+	})
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+	app.errorResponse(w, r, http.StatusConflict, codeEditConflict, nil)
+}
+
+// duplicateMovieResponse reports the likely duplicates of a movie that was about to be created.
+func (app *application) duplicateMovieResponse(w http.ResponseWriter, r *http.Request, duplicates []*data.Movie) {
+	app.problemResponse(w, r, problem{
+		status:     http.StatusConflict,
+		code:       codeMovieDuplicate,
+		extensions: envelope{"duplicates": duplicates},
+	})
+}
+
+func (app *application) duplicateExternalIDResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusConflict, codeExternalIDTaken, nil)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusPreconditionFailed, codePreconditionFailed, nil)
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusPreconditionRequired, codePreconditionRequired, nil)
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
-	message := "rate limit exceeded"
-	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeRateLimitExceeded, nil)
 }
 
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
-	message := "invalid authentication credentials"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidCredentials, nil)
 }
 
 func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", "Bearer")
 
-	message := "invalid or missing authentication token"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeTokenInvalid, nil)
 }
 
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
-	message := "you must be authenticated to access this resource"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeAuthenticationMissing, nil)
 }
 
 func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
-	message := "your user account must be activated to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeAccountInactive, nil)
 }
 
 func (app *application) notPermitted(w http.ResponseWriter, r *http.Request) {
-	message := "your user account doesn't have the necessary permissions to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeNotPermitted, nil)
 }
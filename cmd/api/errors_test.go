@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestProblemResponse(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name            string
+		header          string
+		respond         func(w http.ResponseWriter, r *http.Request)
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "not found",
+			respond:         app.movieNotFoundResponse,
+			wantStatus:      http.StatusNotFound,
+			wantContentType: mediaTypeProblemJSON,
+			wantBody: `{"code":"movie.not_found","detail":"the requested movie could not be found",` +
+				`"instance":"urn:greenlight:request:request-1","requestId":"request-1","status":404,"title":"Not Found",` +
+				`"type":"urn:greenlight:problem:movie.not_found"}` + "\n",
+		},
+		{
+			name: "bad request",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				app.badRequestResponse(w, r, errors.New("body must not be empty")) //nolint:goerr113
+			},
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: mediaTypeProblemJSON,
+			wantBody: `{"code":"request.malformed","detail":"body must not be empty",` +
+				`"instance":"urn:greenlight:request:request-1","requestId":"request-1","status":400,"title":"Bad Request",` +
+				`"type":"urn:greenlight:problem:request.malformed"}` + "\n",
+		},
+		{
+			name: "validation",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				validate := validator.New()
+				validate.Add("title", validator.Required())
+				validate.Add("title", validator.MaxLength(500))
+				validate.AddError("releases[0].date", "invalid date")
+				app.failedValidationResponse(w, r, validate.Errors)
+			},
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantContentType: mediaTypeProblemJSON,
+			wantBody: `{"code":"request.validation_failed","detail":"the request contains invalid values",` +
+				`"errors":[{"code":"invalid","detail":"invalid date","pointer":"/releases/0/date"},` +
+				`{"code":"required","detail":"must be provided","pointer":"/title"},` +
+				`{"code":"max_length","detail":"must not be more than 500 bytes long","params":{"max":500},"pointer":"/title"}],` +
+				`"instance":"urn:greenlight:request:request-1","requestId":"request-1","status":422,"title":"Unprocessable Entity",` +
+				`"type":"urn:greenlight:problem:request.validation_failed"}` + "\n",
+		},
+		{
+			name:            "legacy",
+			header:          "legacy",
+			respond:         app.movieNotFoundResponse,
+			wantStatus:      http.StatusNotFound,
+			wantContentType: mediaTypeJSON,
+			wantBody:        `{"error":"the requested movie could not be found","requestId":"request-1"}` + "\n",
+		},
+		{
+			name:   "legacy validation",
+			header: "Legacy",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				validate := validator.New()
+				validate.Add("title", validator.Required())
+				validate.Add("title", validator.MaxLength(500))
+				validate.AddError("releases[0].date", "invalid date")
+				app.failedValidationResponse(w, r, validate.Errors)
+			},
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantContentType: mediaTypeJSON,
+			wantBody:        `{"error":{"releases[0].date":"invalid date","title":"must be provided"},"requestId":"request-1"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := app.contextSetRequestID(httptest.NewRequest(http.MethodGet, "/v1/movies/1?x=y", nil), "request-1")
+			if test.header != "" {
+				r.Header.Set("Error-Format", test.header)
+			}
+
+			w := httptest.NewRecorder()
+			test.respond(w, r)
+
+			if w.Code != test.wantStatus {
+				t.Errorf("got status %d want %d", w.Code, test.wantStatus)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("got content type %q want %q", got, test.wantContentType)
+			}
+
+			if w.Body.String() != test.wantBody {
+				t.Errorf("got body\n%s\nwant\n%s", w.Body.String(), test.wantBody)
+			}
+
+			if !json.Valid(w.Body.Bytes()) {
+				t.Errorf("invalid JSON body")
+			}
+		})
+	}
+}
+
+func TestProblemResponseWithoutRequestID(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/1?x=y", nil)
+	w := httptest.NewRecorder()
+	app.movieNotFoundResponse(w, r)
+
+	var body map[string]any
+
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, member := range []string{"instance", "requestId"} {
+		if _, found := body[member]; found {
+			t.Errorf("got %s in body %s", member, w.Body.String())
+		}
+	}
+}
+
+func TestLogErrorRoute(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := app.logAccess(app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contextSetRoutePattern(r, "/v1/shared-lists/:token")
+		panic("boom")
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/shared-lists/secret", nil))
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("the token was logged: %s", buf.String())
+	}
+
+	var got struct {
+		Route string `json:"route"`
+	}
+
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Route != "/v1/shared-lists/:token" {
+		t.Errorf("got route %q in %s", got.Route, line)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+)
+
+// movieETag returns the entity tag of a movie, which starts with its version.
+// Ratings, the watchlist annotation of the user and translations change the representation
+// without bumping the version, so they're part of the tag too. The tag is weak: it
+// revalidates the representation, while preconditions only compare versions,
+// see [application.preconditionsMet].
+func movieETag(movie *data.Movie) string {
+	return "W/" + strconv.Quote(fmt.Sprintf("%d-%d-%g%s%s",
+		movie.Version, movie.RatingCount, movie.Rating, watchlistMark(movie), localeMark(movie)))
+}
+
+func watchlistMark(movie *data.Movie) string {
+	if movie.InWatchlist != nil && *movie.InWatchlist {
+		return "-w"
+	}
+
+	return ""
+}
+
+func localeMark(movie *data.Movie) string {
+	if movie.Locale != "" {
+		return fmt.Sprintf("-%s.%d", movie.Locale, movie.TranslationVersion)
+	}
+
+	return ""
+}
+
+// moviesETag returns the entity tag of a page of movies.
+// It changes whenever a movie enters, leaves or is updated in the page.
+func moviesETag(movies []*data.Movie, metadata data.Metadata) string {
+	hash := sha256.New()
+
+	fmt.Fprintf(hash, "%d/%d/%d;", metadata.CurrentPage, metadata.PageSize, metadata.TotalRecords)
+
+	for _, movie := range movies {
+		fmt.Fprintf(hash, "%d:%d:%d:%g%s%s;",
+			movie.ID, movie.Version, movie.RatingCount, movie.Rating, watchlistMark(movie), localeMark(movie))
+	}
+
+	const length = 16
+
+	return strconv.Quote(hex.EncodeToString(hash.Sum(nil))[:length])
+}
+
+// etagMatches reports whether etag is listed in the header value, which is
+// either "*" or a comma-separated list of entity tags.
+// Weak comparison ignores the W/ prefix, strong comparison never matches weak tags.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range entityTags(header) {
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// representationETag returns the tag of the representation negotiated for the request, given
+// the tag of the resource, so that representations in different media types or runtime formats
+// get different tags.
+func (app *application) representationETag(r *http.Request, etag string) string {
+	_, subtype, _ := strings.Cut(negotiatedMediaType(r), "/")
+
+	etag = appendETag(etag, subtype)
+
+	if format := app.contextGetRuntimeFormat(r); format != "" {
+		etag = appendETag(etag, string(format))
+	}
+
+	return etag
+}
+
+// appendETag appends the part to the opaque tag of etag, which stays weak if it is.
+func appendETag(etag, part string) string {
+	weak := strings.HasPrefix(etag, "W/")
+
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(etag, "W/"))
+	if err != nil {
+		unquoted = etag
+	}
+
+	etag = strconv.Quote(unquoted + "-" + part)
+	if weak {
+		etag = "W/" + etag
+	}
+
+	return etag
+}
+
+// entityTags splits the value of an If-Match or If-None-Match header into its entity tags.
+// Tags may contain commas, like the ones of sparse views, so only commas outside quotes separate them.
+func entityTags(header string) []string {
+	var (
+		tags   []string
+		start  int
+		quoted bool
+	)
+
+	for i, c := range header {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			tags = append(tags, strings.TrimSpace(header[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(tags, strings.TrimSpace(header[start:]))
+}
+
+// notModified reports whether the client already has the representation
+// identified by etag, according to If-None-Match.
+// When it returns true, a 304 response has been sent.
+func (*application) notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, etag, true) {
+		return false
+	}
+
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// preconditionsMet checks If-Match against the version of the resource before it's modified.
+// The entity tags sent with resources start with their version, which is all that's compared:
+// any tag the client received for the current version is accepted, whatever the representation.
+// When it returns false, an error response has been sent.
+func (app *application) preconditionsMet(w http.ResponseWriter, r *http.Request, version int32) bool {
+	header := r.Header.Get("If-Match")
+
+	switch {
+	case header == "" && app.config.requireIfMatch:
+		app.preconditionRequiredResponse(w, r)
+		return false
+	case header != "" && !versionMatches(header, version):
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+// versionMatches reports whether one of the entity tags listed in the If-Match header value
+// was sent for the version, or whether the header is "*".
+func versionMatches(header string, version int32) bool {
+	want := strconv.FormatInt(int64(version), 10) //nolint:gomnd
+
+	for _, candidate := range entityTags(header) {
+		if candidate == "*" {
+			return true
+		}
+
+		unquoted, err := strconv.Unquote(strings.TrimPrefix(candidate, "W/"))
+		if err != nil {
+			continue
+		}
+
+		if tagVersion, _, _ := strings.Cut(unquoted, "-"); tagVersion == want {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+)
+
+func TestETagMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{"exact", `"3"`, `"3"`, false, true},
+		{"mismatch", `"2"`, `"3"`, false, false},
+		{"wildcard", `*`, `"3"`, false, true},
+		{"list", `"1", "3"`, `"3"`, false, true},
+		{"comma in tag", `W/"1-title,year", W/"3-title,year"`, `W/"3-title,year"`, true, true},
+		{"comma in other tag", `W/"3-title,year"`, `"year"`, true, false},
+		{"weak tag strong comparison", `W/"3"`, `"3"`, false, false},
+		{"weak tag weak comparison", `W/"3"`, `"3"`, true, true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := etagMatches(test.header, test.etag, test.weak)
+			if got != test.want {
+				t.Errorf("etagMatches(%q, %q, %t) = %t, want %t", test.header, test.etag, test.weak, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPreconditionsMet(t *testing.T) {
+	t.Parallel()
+
+	movie := &data.Movie{ID: 1, Version: 3}
+	received := movieETag(movie)
+
+	// Ratings don't bump the version.
+	movie.RatingCount++
+	movie.Rating = 7
+
+	tests := []struct {
+		name       string
+		header     string
+		require    bool
+		want       bool
+		wantStatus int
+	}{
+		{name: "received tag", header: received, want: true},
+		{name: "current tag", header: movieETag(movie), want: true},
+		{name: "version tag", header: `"3"`, want: true},
+		{name: "list", header: `W/"2-0-0", ` + received, want: true},
+		{name: "wildcard", header: "*", want: true},
+		{name: "stale", header: `W/"2-1-7"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "malformed", header: `3`, wantStatus: http.StatusPreconditionFailed},
+		{name: "optional", want: true},
+		{name: "required", require: true, wantStatus: http.StatusPreconditionRequired},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			app := newTestApplication(t)
+			app.config.requireIfMatch = test.require
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/movies/1", nil)
+			if test.header != "" {
+				r.Header.Set("If-Match", test.header)
+			}
+
+			w := httptest.NewRecorder()
+
+			if got := app.preconditionsMet(w, r, movie.Version); got != test.want {
+				t.Errorf("got %t want %t", got, test.want)
+			}
+
+			if !test.want && w.Code != test.wantStatus {
+				t.Errorf("got status %d want %d", w.Code, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMoviesETag(t *testing.T) {
+	t.Parallel()
+
+	metadata := data.Metadata{CurrentPage: 1, PageSize: 20, TotalRecords: 2}
+	movies := []*data.Movie{{ID: 1, Version: 1}, {ID: 2, Version: 1}}
+
+	before := moviesETag(movies, metadata)
+
+	if got := moviesETag(movies, metadata); got != before {
+		t.Errorf("got etag %s for the same page, want %s", got, before)
+	}
+
+	movies[1].Version++
+
+	if got := moviesETag(movies, metadata); got == before {
+		t.Errorf("got unchanged etag %s after an update", got)
+	}
+}
+
+func TestMovieETagChangesWithRatings(t *testing.T) {
+	t.Parallel()
+
+	movie := &data.Movie{ID: 1, Version: 1}
+
+	before := movieETag(movie)
+
+	movie.RatingCount++
+	movie.Rating = 7
+
+	if got := movieETag(movie); got == before {
+		t.Errorf("got unchanged etag %s after a rating", got)
+	}
+}
+
+func TestMovieETagChangesWithWatchlist(t *testing.T) {
+	t.Parallel()
+
+	inWatchlist := false
+	movie := &data.Movie{ID: 1, Version: 1, InWatchlist: &inWatchlist}
+
+	before := movieETag(movie)
+
+	inWatchlist = true
+
+	if got := movieETag(movie); got == before {
+		t.Errorf("got unchanged etag %s after adding to the watchlist", got)
+	}
+
+	if !versionMatches(before, movie.Version) {
+		t.Errorf("etag %s sent before adding to the watchlist doesn't match version %d", before, movie.Version)
+	}
+}
+
+func TestMovieETagLocalized(t *testing.T) {
+	t.Parallel()
+
+	movie := &data.Movie{ID: 1, Version: 1, Title: "Spirited Away"}
+	original := movieETag(movie)
+
+	movie.Localize(&data.MovieTranslation{MovieID: 1, Locale: "fr", Title: "Le Voyage de Chihiro", Version: 1})
+
+	localized := movieETag(movie)
+	if !strings.HasPrefix(localized, "W/") {
+		t.Errorf("got strong etag %s for a localized movie", localized)
+	}
+
+	if etagMatches(localized, original, true) {
+		t.Errorf("localized etag %s matches the original %s", localized, original)
+	}
+
+	movie.TranslationVersion++
+
+	if got := movieETag(movie); got == localized {
+		t.Errorf("got unchanged etag %s after a translation update", got)
+	}
+}
+
+func TestLocalizedETagIfMatch(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	movie := &data.Movie{ID: 1, Version: 2, Title: "Spirited Away"}
+	movie.Localize(&data.MovieTranslation{MovieID: 1, Locale: "fr", Title: "Le Voyage de Chihiro", Version: 1})
+
+	// Tags of a GET sent with Accept-Language: fr, in full and sparse views.
+	for _, etag := range []string{
+		movieETag(movie),
+		movieView{fields: []string{"title", "year"}}.etag(movieETag(movie)),
+	} {
+		r := httptest.NewRequest(http.MethodPatch, "/v1/movies/1", nil)
+		r.Header.Set("If-Match", etag)
+
+		w := httptest.NewRecorder()
+
+		if !app.preconditionsMet(w, r, movie.Version) {
+			t.Errorf("etag %s of the localized movie rejected with status %d", etag, w.Code)
+		}
+	}
+}
+
+func TestRepresentationETag(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	etag := movieETag(&data.Movie{ID: 1, Version: 2})
+
+	tags := map[string]string{}
+
+	for _, accept := range []string{"", mediaTypeJSON, mediaTypeXML, mediaTypeMessagePack, mediaTypeCSV} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+		r.Header.Set("Accept", accept)
+
+		tags[accept] = app.representationETag(r, etag)
+
+		if !versionMatches(tags[accept], 2) {
+			t.Errorf("%q: etag %s doesn't match the version", accept, tags[accept])
+		}
+	}
+
+	if tags[""] != tags[mediaTypeJSON] {
+		t.Errorf("got etag %s by default want the JSON one %s", tags[""], tags[mediaTypeJSON])
+	}
+
+	distinct := map[string]bool{}
+	for _, tag := range tags {
+		distinct[tag] = true
+	}
+
+	if len(distinct) != len(responseEncoders) {
+		t.Errorf("got etags %v, want one per media type", tags)
+	}
+}
+
+func TestRepresentationETagRuntimeFormat(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	etag := movieETag(&data.Movie{ID: 1, Version: 2})
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+
+	tags := map[string]bool{app.representationETag(r, etag): true}
+
+	for _, format := range data.RuntimeFormats {
+		tags[app.representationETag(app.contextSetRuntimeFormat(r, format), etag)] = true
+	}
+
+	if len(tags) != len(data.RuntimeFormats)+1 {
+		t.Errorf("got etags %v, want one per runtime format", tags)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+const exportBatchSize = 500
+
+// movieExporter writes exported movies in a given format.
+// begin is called before the first movie and end after the last one.
+type movieExporter interface {
+	contentType() string
+	begin(w io.Writer) error
+	write(w io.Writer, movie *data.Movie) error
+	end(w io.Writer) error
+}
+
+func newMovieExporter(format string) movieExporter {
+	switch format {
+	case "csv":
+		return &csvMovieExporter{}
+	case "ndjson":
+		return ndjsonMovieExporter{}
+	default:
+		return &jsonMovieExporter{}
+	}
+}
+
+// csvMovieExporter writes movies in the format accepted by the import endpoint.
+type csvMovieExporter struct {
+	writer *csv.Writer
+}
+
+func (*csvMovieExporter) contentType() string { return "text/csv" }
+
+func (c *csvMovieExporter) begin(w io.Writer) error {
+	c.writer = csv.NewWriter(w)
+
+	return c.flush(c.writer.Write([]string{"id", "title", "year", "runtime", "genres"}))
+}
+
+func (c *csvMovieExporter) write(_ io.Writer, movie *data.Movie) error {
+	const base = 10
+
+	return c.flush(c.writer.Write([]string{
+		strconv.FormatInt(movie.ID, base),
+		movie.Title,
+		strconv.Itoa(int(movie.Year)),
+		strconv.Itoa(int(movie.Runtime)),
+		strings.Join(movie.Genres, ","),
+	}))
+}
+
+func (*csvMovieExporter) end(io.Writer) error { return nil }
+
+// flush pushes the buffered record to the underlying writer so that
+// it's part of the next chunk sent to the client.
+func (c *csvMovieExporter) flush(err error) error {
+	if err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	c.writer.Flush()
+
+	if err = c.writer.Error(); err != nil {
+		return fmt.Errorf("flushing csv: %w", err)
+	}
+
+	return nil
+}
+
+// ndjsonMovieExporter writes one JSON movie per line.
+type ndjsonMovieExporter struct{}
+
+func (ndjsonMovieExporter) contentType() string { return "application/x-ndjson" }
+
+func (ndjsonMovieExporter) begin(io.Writer) error { return nil }
+
+func (ndjsonMovieExporter) write(w io.Writer, movie *data.Movie) error {
+	if err := json.NewEncoder(w).Encode(movie); err != nil {
+		return fmt.Errorf("encoding movie: %w", err)
+	}
+
+	return nil
+}
+
+func (ndjsonMovieExporter) end(io.Writer) error { return nil }
+
+// jsonMovieExporter writes a single {"movies": [...]} document.
+type jsonMovieExporter struct {
+	written bool
+}
+
+func (*jsonMovieExporter) contentType() string { return "application/json" }
+
+func (*jsonMovieExporter) begin(w io.Writer) error {
+	_, err := io.WriteString(w, `{"movies":[`)
+
+	return err //nolint:wrapcheck
+}
+
+func (j *jsonMovieExporter) write(w io.Writer, movie *data.Movie) error {
+	if j.written {
+		if _, err := io.WriteString(w, ","); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	j.written = true
+
+	resp, err := json.Marshal(movie)
+	if err != nil {
+		return fmt.Errorf("encoding movie: %w", err)
+	}
+
+	_, err = w.Write(resp)
+
+	return err //nolint:wrapcheck
+}
+
+func (*jsonMovieExporter) end(w io.Writer) error {
+	_, err := io.WriteString(w, "]}\n")
+
+	return err //nolint:wrapcheck
+}
+
+//nolint:funlen,cyclop
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	validate := validator.New()
+
+	urlValues := r.URL.Query()
+
+	criteria, err := app.readMovieCriteria(urlValues, validate)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	format := app.readString(urlValues, "format", "json")
+	filters := data.Filters{
+		Sort:         app.readString(urlValues, "sort", "id"),
+		SortSafelist: movieSortSafelist(),
+	}
+
+	validate.CheckError(validator.PermittedValue(format, "csv", "ndjson", "json"), "format",
+		validator.OneOf("csv", "ndjson", "json"))
+	validate.CheckError(validator.PermittedValue(filters.Sort, filters.SortSafelist...), "sort",
+		validator.OneOf(filters.SortSafelist...))
+
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	exporter := newMovieExporter(format)
+	controller := http.NewResponseController(w)
+	buffer := bufio.NewWriter(w)
+	started := false
+
+	// The response is only started once the first batch is available, so that
+	// errors happening before can still be reported properly.
+	start := func() error {
+		started = true
+
+		w.Header().Set("Content-Type", exporter.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="movies.%s"`, format))
+		w.WriteHeader(http.StatusOK)
+
+		return exporter.begin(buffer)
+	}
+
+	err = app.models.Movies.Export(r.Context(), criteria, filters, exportBatchSize, func(movies []*data.Movie) error {
+		if !started {
+			if startErr := start(); startErr != nil {
+				return startErr
+			}
+		}
+
+		for _, movie := range movies {
+			if writeErr := exporter.write(buffer, movie); writeErr != nil {
+				return writeErr
+			}
+		}
+
+		return app.flushChunk(controller, buffer)
+	})
+
+	switch {
+	case err != nil && !started:
+		app.serverErrorResponse(w, r, err)
+		return
+	case err != nil:
+		// The response has already started: the client will get a truncated export.
+		app.logError(r, err)
+		return
+	case !started:
+		err = start()
+	}
+
+	if err == nil {
+		err = exporter.end(buffer)
+	}
+
+	if err == nil {
+		err = app.flushChunk(controller, buffer)
+	}
+
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// flushChunk sends the buffered data to the client and gives the next chunk
+// a full WriteTimeout to be written.
+func (*application) flushChunk(controller *http.ResponseController, buffer *bufio.Writer) error {
+	if err := buffer.Flush(); err != nil {
+		return fmt.Errorf("writing chunk: %w", err)
+	}
+
+	if err := controller.Flush(); err != nil {
+		return fmt.Errorf("flushing chunk: %w", err)
+	}
+
+	if err := controller.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return fmt.Errorf("extending write deadline: %w", err)
+	}
+
+	return nil
+}
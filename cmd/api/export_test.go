@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+)
+
+func TestMovieExporters(t *testing.T) {
+	t.Parallel()
+
+	movies := []*data.Movie{
+		{ID: 1, Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation", "adventure"}, Version: 1},
+		{ID: 2, Title: "Black Panther", Year: 2018, Runtime: 134, Genres: []string{"action"}, Version: 2},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "id,title,year,runtime,genres\n1,Moana,2016,107,\"animation,adventure\"\n2,Black Panther,2018,134,action\n"},
+		{"ndjson", `{"id":1,"title":"Moana","year":2016,"runtime":"107 mins","genres":["animation","adventure"],"version":1}
+{"id":2,"title":"Black Panther","year":2018,"runtime":"134 mins","genres":["action"],"version":2}
+`},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.format, func(t *testing.T) {
+			t.Parallel()
+
+			var buffer bytes.Buffer
+
+			exporter := newMovieExporter(test.format)
+
+			if err := exporter.begin(&buffer); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, movie := range movies {
+				if err := exporter.write(&buffer, movie); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := exporter.end(&buffer); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buffer.String(); got != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		for _, count := range []int{0, 2} {
+			var buffer bytes.Buffer
+
+			exporter := newMovieExporter("json")
+			exporter.begin(&buffer) //nolint:errcheck
+
+			for _, movie := range movies[:count] {
+				exporter.write(&buffer, movie) //nolint:errcheck
+			}
+
+			exporter.end(&buffer) //nolint:errcheck
+
+			var got struct {
+				Movies []data.Movie `json:"movies"`
+			}
+
+			if err := json.Unmarshal(buffer.Bytes(), &got); err != nil {
+				t.Fatalf("invalid json %q: %v", buffer.String(), err)
+			}
+
+			if len(got.Movies) != count {
+				t.Errorf("got %d movies want %d", len(got.Movies), count)
+			}
+		}
+	})
+}
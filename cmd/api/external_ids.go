@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func (app *application) showMovieExternalIDsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.embedExternalIDs(movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"externalIds": movie.ExternalIDs}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) replaceMovieExternalIDsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	var input data.ExternalIDs
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateExternalIDs(v, "externalIds", input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Movies.SetExternalIDs(movie.ID, input)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.movieNotFoundResponse(w, r)
+		return
+	case errors.Is(err, data.ErrDuplicateExternalID):
+		app.duplicateExternalIDResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"externalIds": input}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// embedExternalIDs sets the external identifiers of the given movies.
+func (app *application) embedExternalIDs(movies ...*data.Movie) error {
+	ids := make([]int64, 0, len(movies))
+
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	externalIDs, err := app.models.Movies.GetExternalIDs(ids...)
+	if err != nil {
+		return fmt.Errorf("getting external ids: %w", err)
+	}
+
+	for _, movie := range movies {
+		movie.ExternalIDs = externalIDs[movie.ID]
+		if movie.ExternalIDs == nil {
+			movie.ExternalIDs = &data.ExternalIDs{}
+		}
+	}
+
+	return nil
+}
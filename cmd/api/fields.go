@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+// includeKeys maps the values of the include parameter to the key of the embedded resource.
+var includeKeys = map[string]string{
+	"credits":      "credits",
+	"releases":     "releases",
+	"external_ids": "externalIds",
+}
+
+// movieView is the representation of movies requested by the client:
+// the fields to send, all of them if empty, and the related resources to embed.
+type movieView struct {
+	fields  []string
+	include []string
+}
+
+// readMovieView reads the fields and include parameters.
+func (app *application) readMovieView(qs url.Values, validate *validator.Validator) movieView {
+	view := movieView{
+		fields:  app.readCSV(qs, "fields", []string{}),
+		include: app.readCSV(qs, "include", []string{}),
+	}
+
+	for _, field := range view.fields {
+		validate.CheckError(validator.PermittedValue(field, data.MovieFields...), "fields", validator.OneOf(data.MovieFields...))
+	}
+
+	validateMovieIncludes(validate, view.include)
+
+	return view
+}
+
+// etag returns the entity tag of the view of a resource, given the tag of its full representation.
+// Sparse representations get a weak tag, which can't be used as a precondition to update the resource.
+func (view movieView) etag(etag string) string {
+	if len(view.fields) == 0 {
+		return etag
+	}
+
+	fields := slices.Clone(view.fields)
+	slices.Sort(fields)
+
+	return "W/" + strings.TrimPrefix(appendETag(etag, strings.Join(slices.Compact(fields), ",")), "W/")
+}
+
+// keys returns the keys of the movies to send, or nil to send all of them.
+func (view movieView) keys() []string {
+	if len(view.fields) == 0 {
+		return nil
+	}
+
+	keys := slices.Clone(view.fields)
+
+	for _, include := range view.include {
+		keys = append(keys, includeKeys[include])
+	}
+
+	return keys
+}
+
+// writeMovies embeds the related resources requested by the view in the movies, then sends
+// the envelope with the movie or movies held under key reduced to the requested fields.
+func (app *application) writeMovies(
+	w http.ResponseWriter, r *http.Request, status int, env envelope, key string, view movieView, headers http.Header, movies ...*data.Movie,
+) error {
+	if err := app.embedIncludes(view.include, movies...); err != nil {
+		return err
+	}
+
+	if keys := view.keys(); keys != nil {
+		app.formatRuntimes(r, env)
+
+		projected, err := projectFields(env[key], keys)
+		if err != nil {
+			return err
+		}
+
+		env[key] = projected
+	}
+
+	return app.writeResponse(w, r, status, env, headers)
+}
+
+// projectFields returns the JSON representation of value, an object or an array of objects,
+// as generic values holding only the given keys.
+func projectFields(value any, keys []string) (any, error) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encoding value to project: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var generic any
+
+	if err = dec.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("decoding value to project: %w", err)
+	}
+
+	project := func(object map[string]any) {
+		for key, value := range object {
+			if !slices.Contains(keys, key) {
+				delete(object, key)
+				continue
+			}
+
+			object[key] = numbersToValues(value)
+		}
+	}
+
+	switch generic := generic.(type) {
+	case map[string]any:
+		project(generic)
+	case []any:
+		for _, element := range generic {
+			if object, ok := element.(map[string]any); ok {
+				project(object)
+			}
+		}
+	}
+
+	return generic, nil
+}
+
+// numbersToValues replaces the JSON numbers in value with integers, or floats for
+// numbers that aren't integers, so that they're encoded as numbers in any format.
+func numbersToValues(value any) any {
+	switch value := value.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := value.Float64()
+
+		return f
+	case map[string]any:
+		for k, v := range value {
+			value[k] = numbersToValues(v)
+		}
+	case []any:
+		for i, v := range value {
+			value[i] = numbersToValues(v)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestProjectFields(t *testing.T) {
+	t.Parallel()
+
+	movies := []*data.Movie{
+		{ID: 1, Title: "Arrival", Year: 2016, Runtime: 116, Version: 3, Credits: []data.Credit{}},
+		{ID: 2, Title: "Dune", Year: 2021, Version: 1},
+	}
+
+	view := movieView{fields: []string{"id", "title"}, include: []string{"releases"}}
+
+	projected, err := projectFields(movies, view.keys())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, err := json.Marshal(projected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[{"id":1,"title":"Arrival"},{"id":2,"title":"Dune"}]`
+	if string(js) != want {
+		t.Errorf("got %s want %s", js, want)
+	}
+
+	projected, err = projectFields(movies[0], []string{"runtime", "version"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if js, err = json.Marshal(projected); err != nil {
+		t.Fatal(err)
+	}
+
+	want = `{"runtime":"116 mins","version":3}`
+	if string(js) != want {
+		t.Errorf("got %s want %s", js, want)
+	}
+}
+
+func TestMovieViewETag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fields []string
+		etag   string
+		want   string
+	}{
+		{nil, `"3-0-0"`, `"3-0-0"`},
+		{[]string{"title", "id"}, `"3-0-0"`, `W/"3-0-0-id,title"`},
+		{[]string{"id", "title", "id"}, `W/"3-0-0-fr.1"`, `W/"3-0-0-fr.1-id,title"`},
+	}
+
+	for _, test := range tests {
+		if got := (movieView{fields: test.fields}).etag(test.etag); got != test.want {
+			t.Errorf("%v %s: got %s want %s", test.fields, test.etag, got, test.want)
+		}
+	}
+}
+
+func TestReadMovieView(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		query     string
+		wantError string
+	}{
+		{"fields=id,title,ratingCount&include=credits", ""},
+		{"fields=id,created_at", "fields"},
+		{"include=reviews", "include"},
+	}
+
+	for _, test := range tests {
+		qs, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		validate := validator.New()
+		app.readMovieView(qs, validate)
+
+		if test.wantError == "" && !validate.Valid() {
+			t.Errorf("%q: got errors %v", test.query, validate.Errors)
+		}
+
+		if _, found := validate.Errors[validator.Pointer(test.wantError)]; test.wantError != "" && !found {
+			t.Errorf("%q: got errors %v want %s error", test.query, validate.Errors, test.wantError)
+		}
+	}
+}
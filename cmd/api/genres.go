@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func (app *application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{Name: input.Name, Aliases: input.Aliases}
+	v := validator.New()
+
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Insert(genre)
+
+	switch {
+	case errors.Is(err, data.ErrDuplicateGenre):
+		v.Add("name", validator.FieldError{
+			Code:    data.CodeGenreClash,
+			Message: "must not clash with the name or aliases of another genre",
+		})
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/genres/%d", genre.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"genre": genre}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showGenreHandler(w http.ResponseWriter, r *http.Request) {
+	genre, ok := app.getGenreOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//nolint:cyclop
+func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Request) {
+	genre, ok := app.getGenreOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name    *string  `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		genre.Name = *input.Name
+	}
+
+	if input.Aliases != nil {
+		genre.Aliases = input.Aliases
+	}
+
+	v := validator.New()
+
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(genre, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrDuplicateGenre):
+		v.Add("name", validator.FieldError{
+			Code:    data.CodeGenreClash,
+			Message: "must not clash with the name or aliases of another genre",
+		})
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//nolint:cyclop
+func (app *application) mergeGenreHandler(w http.ResponseWriter, r *http.Request) {
+	source, ok := app.getGenreOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Into int64 `json:"into"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.CheckError(input.Into > 0, "into", validator.Required())
+	v.CheckError(input.Into != source.ID, "into", validator.FieldError{
+		Code:    data.CodeMergeIntoSelf,
+		Message: "must not be the merged genre",
+	})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	target, err := app.models.Genres.Get(input.Into)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		v.Add("into", validator.FieldError{Code: data.CodeUnknownGenre, Message: "must be an existing genre"})
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	rewritten, err := app.models.Genres.Merge(source, target, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	target.Movies += source.Movies
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"genre": target, "rewrittenMovies": rewritten}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Genres.Delete(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case errors.Is(err, data.ErrGenreInUse):
+		app.errorResponse(w, r, http.StatusConflict, codeGenreInUse, nil)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "genre successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getGenreOrRespond returns the genre identified by the route.
+// When it returns false, an error response has been sent.
+func (app *application) getGenreOrRespond(w http.ResponseWriter, r *http.Request) (*data.Genre, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	genre, err := app.models.Genres.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return nil, false
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	return genre, true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestGenreSlug(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Sci-Fi", "sci-fi"},
+		{" sci  fi ", "sci-fi"},
+		{"SCI/FI!", "sci-fi"},
+		{"Comédie", "comédie"},
+		{"--", ""},
+	}
+
+	for _, test := range tests {
+		if got := data.GenreSlug(test.name); got != test.want {
+			t.Errorf("GenreSlug(%q) = %q want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidateMovieGenres(t *testing.T) {
+	t.Parallel()
+
+	vocabulary := data.GenreVocabulary{
+		"science-fiction": "Science Fiction",
+		"sci-fi":          "Science Fiction",
+		"drama":           "Drama",
+	}
+
+	tests := []struct {
+		name       string
+		genres     []string
+		wantGenres []string
+		wantError  string
+	}{
+		{"canonical", []string{"Drama", "Science Fiction"}, []string{"Drama", "Science Fiction"}, ""},
+		{"aliases", []string{"drama", "sci fi"}, []string{"Drama", "Science Fiction"}, ""},
+		{"unknown", []string{"Drama", "Western"}, nil, "/genres/1"},
+		{"duplicate aliases", []string{"Sci-Fi", "Science Fiction"}, nil, "/genres"},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			movie := &data.Movie{Title: "Arrival", Year: 2016, Runtime: 116, Genres: test.genres}
+			validate := validator.New()
+			data.ValidateMovie(validate, movie, vocabulary)
+
+			if test.wantError != "" {
+				if _, found := validate.Errors[test.wantError]; !found {
+					t.Fatalf("got errors %v want %q error", validate.Errors, test.wantError)
+				}
+
+				return
+			}
+
+			if !validate.Valid() {
+				t.Fatalf("got errors %v", validate.Errors)
+			}
+
+			if !slices.Equal(movie.Genres, test.wantGenres) {
+				t.Errorf("got genres %v want %v", movie.Genres, test.wantGenres)
+			}
+		})
+	}
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/Crocmagnon/greenlight/internal/data"
 	"github.com/Crocmagnon/greenlight/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -21,6 +24,7 @@ const (
 // Errors used during validation and returned to the consumer.
 var (
 	ErrInvalidID         = errors.New("invalid id parameter")
+	ErrInvalidVersion    = errors.New("invalid version parameter")
 	ErrMalformedJSON     = errors.New("body contains malformed JSON")
 	ErrIncorrectJSONType = errors.New("body contains incorrect JSON type")
 	ErrEmptyBody         = errors.New("body is empty")
@@ -29,7 +33,13 @@ var (
 	ErrMultipleJSON      = errors.New("body must only contain a single JSON value")
 )
 
-func (*application) readIDParam(r *http.Request) (int64, error) {
+func (app *application) readIDParam(r *http.Request) (int64, error) {
+	return app.readNamedIDParam(r, "id")
+}
+
+// readNamedIDParam reads the ID held by the given route parameter,
+// for routes identifying several resources.
+func (*application) readNamedIDParam(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
 	const (
@@ -37,7 +47,7 @@ func (*application) readIDParam(r *http.Request) (int64, error) {
 		bitSize = 64
 	)
 
-	id, err := strconv.ParseInt(params.ByName("id"), base, bitSize)
+	id, err := strconv.ParseInt(params.ByName(name), base, bitSize)
 	if err != nil || id < 1 {
 		return 0, ErrInvalidID
 	}
@@ -45,44 +55,168 @@ func (*application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+func (*application) readVersionParam(r *http.Request) (int32, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	const (
+		base    = 10
+		bitSize = 32
+	)
+
+	version, err := strconv.ParseInt(params.ByName("version"), base, bitSize)
+	if err != nil || version < 1 {
+		return 0, ErrInvalidVersion
+	}
+
+	return int32(version), nil
+}
+
 type envelope map[string]any
 
-func (*application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	resp, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("encoding to json: %w", err)
+// writeResponse sends the envelope in the preferred media type the client accepts,
+// see [acceptedEncoders]. Responses the accepted media types can't represent, like
+// single resources in CSV, are sent as JSON. Clients accepting no media type at all
+// get 406 Not Acceptable, unless the response is an error, which is sent as JSON.
+// Requests modifying resources are rejected before being handled, see [application.negotiate].
+func (app *application) writeResponse(
+	w http.ResponseWriter, r *http.Request, status int, env envelope, headers http.Header,
+) error {
+	return app.encodeResponse(w, r, status, env, headers, false)
+}
+
+// writeProblem sends problem details like [application.writeResponse], with the
+// problem media type of the chosen format when it has one.
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, status int, env envelope) error {
+	return app.encodeResponse(w, r, status, env, nil, true)
+}
+
+func (app *application) encodeResponse(
+	w http.ResponseWriter, r *http.Request, status int, env envelope, headers http.Header, problem bool,
+) error {
+	app.formatRuntimes(r, env)
+
+	encoders := acceptedEncoders(r.Header.Get("Accept"))
+	if len(encoders) > 0 || status >= http.StatusBadRequest {
+		encoders = append(encoders, responseEncoders[0])
 	}
 
-	resp = append(resp, '\n')
+	var resp bytes.Buffer
+
+	for _, encoder := range encoders {
+		err := encoder.encode(&resp, env)
+		if errors.Is(err, errNotRepresentable) {
+			resp.Reset()
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		for k, v := range headers {
+			w.Header()[k] = v
+		}
+
+		mediaType := encoder.mediaType
+		if problem && encoder.problemMediaType != "" {
+			mediaType = encoder.problemMediaType
+		}
 
-	for k, v := range headers {
-		w.Header()[k] = v
+		w.Header().Add("Vary", "Accept")
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(status)
+		w.Write(resp.Bytes()) //nolint:errcheck
+
+		return nil
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	w.Write(resp) //nolint:errcheck
+	app.notAcceptableResponse(w, r)
 
 	return nil
 }
 
-func (*application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
-	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+// formatRuntimes sets the runtime format requested by the client on the values of the envelope
+// holding runtimes. Cached statistics are copied rather than modified.
+//
+//nolint:cyclop
+func (app *application) formatRuntimes(r *http.Request, env envelope) {
+	format := app.contextGetRuntimeFormat(r)
+
+	for key, value := range env {
+		switch value := value.(type) {
+		case *data.Movie:
+			value.RuntimeFormat = format
+		case []*data.Movie:
+			formatMovieRuntimes(value, format)
+		case []*data.ScoredMovie:
+			for _, movie := range value {
+				movie.RuntimeFormat = format
+			}
+		case []*data.MovieRevision:
+			for _, revision := range value {
+				revision.RuntimeFormat = format
+			}
+		case []data.FieldChange:
+			for i, change := range value {
+				if from, ok := change.From.(data.Runtime); ok {
+					value[i].From = from.Formatted(format)
+				}
+
+				if to, ok := change.To.(data.Runtime); ok {
+					value[i].To = to.Formatted(format)
+				}
+			}
+		case *data.MovieStats:
+			stats := *value
+			stats.Runtime.RuntimeFormat = format
+			env[key] = &stats
+		case *data.ListItem:
+			value.RuntimeFormat = format
+		case []*data.ListItem:
+			for _, item := range value {
+				item.RuntimeFormat = format
+			}
+		case []batchResult:
+			for _, result := range value {
+				if result.Movie != nil {
+					result.Movie.RuntimeFormat = format
+				}
+
+				formatMovieRuntimes(result.Duplicates, format)
+			}
+		}
+	}
+}
 
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
+func formatMovieRuntimes(movies []*data.Movie, format data.RuntimeFormat) {
+	for _, movie := range movies {
+		movie.RuntimeFormat = format
+	}
+}
 
-	err := dec.Decode(dst)
-	if err != nil {
-		return wrapError(err)
+// readRequest decodes the request body according to its Content-Type, see [requestDecoders].
+// Bodies of other media types are read as JSON, like form bodies sent by curl -d, except the
+// ones of [unsupportedRequestTypes] for which ErrUnsupportedMediaType is returned.
+func (*application) readRequest(w http.ResponseWriter, r *http.Request, dst any) error {
+	if slices.Contains(unsupportedRequestTypes, mediaType(r)) {
+		return fmt.Errorf("%w %q", ErrUnsupportedMediaType, r.Header.Get("Content-Type"))
 	}
 
-	err = dec.Decode(&struct{}{})
-	if !errors.Is(err, io.EOF) {
-		return ErrMultipleJSON
+	decode, found := requestDecoders[mediaType(r)]
+	if !found {
+		decode = decodeJSON
 	}
 
-	return nil
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	return decode(r.Body, dst)
+}
+
+// readJSON decodes a JSON request body, regardless of its Content-Type.
+func (*application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	return decodeJSON(r.Body, dst)
 }
 
 func wrapError(err error) error {
@@ -156,13 +290,29 @@ func (*application) readInt(qs url.Values, key string, defaultValue int, validat
 
 	i, err := strconv.Atoi(s)
 	if err != nil {
-		validate.AddError(key, "must be an integer value")
+		validate.Add(key, validator.Integer())
 		return defaultValue
 	}
 
 	return i
 }
 
+func (*application) readBool(qs url.Values, key string, defaultValue bool, validate *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		validate.Add(key, validator.Boolean())
+		return defaultValue
+	}
+
+	return b
+}
+
 func (app *application) background(callback func()) {
 	app.wg.Add(1)
 
@@ -178,3 +328,19 @@ func (app *application) background(callback func()) {
 		callback()
 	}()
 }
+
+// userHasPermission reports whether the user of the request has the permission,
+// for handlers whose behaviour depends on it beyond requirePermission.
+func (app *application) userHasPermission(r *http.Request, code string) (bool, error) {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return false, nil
+	}
+
+	perms, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return false, fmt.Errorf("getting permissions: %w", err)
+	}
+
+	return perms.Include(code), nil
+}
@@ -20,11 +20,12 @@ func newTestApplication(tb testing.TB) *application {
 	db := sqlx.DB{}
 
 	return &application{
-		config: config{},
-		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
-		models: data.NewModels(&db),
-		mailer: mailer.Mailer{},
-		wg:     sync.WaitGroup{},
+		config:   config{},
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		logLevel: new(slog.LevelVar),
+		models:   data.NewModels(&db),
+		mailer:   mailer.Mailer{},
+		wg:       sync.WaitGroup{},
 	}
 }
 
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	importBatchSize = 500
+
+	importModeAtomic     = "atomic"
+	importModeBestEffort = "best_effort"
+
+	importStatusAccepted   = "accepted"
+	importStatusRejected   = "rejected"
+	importStatusSkipped    = "skipped"
+	importStatusRolledBack = "rolled_back"
+)
+
+// Codes of the errors reported for records that can't be read.
+const (
+	codeRecordFieldCount = "record_field_count"
+	codeRecordMalformed  = "record_malformed"
+)
+
+// Errors returned when the import body can't be read any further.
+var (
+	ErrMissingCSVColumn = errors.New("csv header is missing a column")
+	ErrMalformedCSV     = errors.New("body contains malformed CSV")
+)
+
+// importRow reports the outcome of a single imported record.
+// Rows are numbered from 1, not counting the CSV header.
+// Errors lists why the row was rejected, like the errors of problem details,
+// once localized from fields. Duplicates holds the IDs of existing movies the row
+// is likely a duplicate of, and DuplicateRows the earlier rows of the same import.
+type importRow struct {
+	Row           int        `json:"row"`
+	Status        string     `json:"status"`
+	ID            int64      `json:"id,omitempty"`
+	Errors        []envelope `json:"errors,omitempty"`
+	Duplicates    []int64    `json:"duplicates,omitempty"`
+	DuplicateRows []int      `json:"duplicateRows,omitempty"`
+
+	fields validator.Errors
+}
+
+// importReport is returned to the client once the import is done.
+// In atomic mode, once a row has been rejected the following rows are skipped,
+// and the rows that were inserted are rolled back.
+type importReport struct {
+	Mode       string      `json:"mode"`
+	Committed  bool        `json:"committed"`
+	Total      int         `json:"total"`
+	Accepted   int         `json:"accepted"`
+	Rejected   int         `json:"rejected"`
+	Skipped    int         `json:"skipped"`
+	RolledBack int         `json:"rolledBack"`
+	Rows       []importRow `json:"rows"`
+}
+
+func (report *importReport) accept(row int, id int64) {
+	report.Total++
+	report.Accepted++
+	report.Rows = append(report.Rows, importRow{Row: row, Status: importStatusAccepted, ID: id})
+}
+
+func (report *importReport) reject(row int, rowErrors validator.Errors) {
+	report.Total++
+	report.Rejected++
+	report.Rows = append(report.Rows, importRow{Row: row, Status: importStatusRejected, fields: rowErrors})
+}
+
+func (report *importReport) skip(row int) {
+	report.Total++
+	report.Skipped++
+	report.Rows = append(report.Rows, importRow{Row: row, Status: importStatusSkipped})
+}
+
+// localize lists the errors of the rejected rows in the language.
+func (report *importReport) localize(language string) {
+	for i, row := range report.Rows {
+		if row.fields != nil {
+			report.Rows[i].Errors = fieldErrors(localizeFieldErrors(language, row.fields))
+		}
+	}
+}
+
+// rollBack marks the accepted rows as rolled back, once the import won't be committed.
+func (report *importReport) rollBack() {
+	for i, row := range report.Rows {
+		if row.Status == importStatusAccepted {
+			report.Rows[i].Status = importStatusRolledBack
+			report.Rows[i].ID = 0
+			report.Accepted--
+			report.RolledBack++
+		}
+	}
+}
+
+// recordErrors reports an error about a whole record.
+func recordErrors(err validator.FieldError) validator.Errors {
+	return validator.Errors{"/record": {err}}
+}
+
+// movieRecordReader reads movies one record at a time from an import body.
+// next returns io.EOF when there are no more records. Errors wrapped in a
+// recordError only affect the current record, any other error stops the import.
+type movieRecordReader interface {
+	next() (*movieDocument, error)
+}
+
+// recordError reports why the field at pointer, or the whole record if empty, is invalid.
+type recordError struct {
+	pointer string
+	err     validator.FieldError
+}
+
+func (e recordError) Error() string { return e.err.Message }
+
+func (e recordError) errors() validator.Errors {
+	if e.pointer == "" {
+		return recordErrors(e.err)
+	}
+
+	return validator.Errors{e.pointer: {e.err}}
+}
+
+// malformedRecord reports a record that can't be decoded.
+func malformedRecord(err error) recordError {
+	return recordError{err: validator.FieldError{
+		Code:    codeRecordMalformed,
+		Message: err.Error(),
+		Params:  map[string]any{"error": err.Error()},
+	}}
+}
+
+func newMovieRecordReader(body io.Reader, mediaType string) (movieRecordReader, bool) {
+	switch mediaType {
+	case "text/csv":
+		return &csvMovieReader{reader: csv.NewReader(body)}, true
+	case "application/x-ndjson", "application/jsonl":
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(nil, maxBytes)
+
+		return &ndjsonMovieReader{scanner: scanner}, true
+	default:
+		return nil, false
+	}
+}
+
+// csvMovieReader reads CSV records with a title, year, runtime and genres header.
+// Genres are comma separated, runtimes are either minutes or "<n> mins".
+type csvMovieReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func (c *csvMovieReader) next() (*movieDocument, error) {
+	if c.columns == nil {
+		err := c.readHeader()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	record, err := c.reader.Read()
+
+	var parseError *csv.ParseError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, io.EOF
+	case errors.As(err, &parseError) && errors.Is(err, csv.ErrFieldCount):
+		return nil, recordError{err: validator.FieldError{
+			Code:    codeRecordFieldCount,
+			Message: "must have as many fields as the header",
+		}}
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrMalformedCSV, err)
+	}
+
+	field := func(name string) string {
+		return strings.TrimSpace(record[c.columns[name]])
+	}
+
+	movie := &movieDocument{Title: field("title")}
+
+	year, err := strconv.ParseInt(field("year"), 10, 32) //nolint:gomnd
+	if err != nil {
+		return nil, recordError{pointer: "/year", err: validator.Integer()}
+	}
+
+	movie.Year = int32(year)
+
+	if movie.Runtime, err = data.ParseRuntime(field("runtime")); err != nil {
+		return nil, recordError{pointer: "/runtime", err: data.InvalidRuntime()}
+	}
+
+	for _, genre := range strings.Split(field("genres"), ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			movie.Genres = append(movie.Genres, genre)
+		}
+	}
+
+	return movie, nil
+}
+
+func (c *csvMovieReader) readHeader() error {
+	header, err := c.reader.Read()
+	if err != nil {
+		return fmt.Errorf("%w: reading header: %w", ErrMalformedCSV, err)
+	}
+
+	c.columns = make(map[string]int, len(header))
+
+	for i, name := range header {
+		c.columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, found := c.columns[name]; !found {
+			return fmt.Errorf("%w %q", ErrMissingCSVColumn, name)
+		}
+	}
+
+	return nil
+}
+
+// ndjsonMovieReader reads one JSON movie per line. Blank lines are ignored.
+type ndjsonMovieReader struct {
+	scanner *bufio.Scanner
+}
+
+func (n *ndjsonMovieReader) next() (*movieDocument, error) {
+	for n.scanner.Scan() {
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+
+		var movie movieDocument
+
+		if err := dec.Decode(&movie); err != nil {
+			return nil, malformedRecord(wrapError(err))
+		}
+
+		return &movie, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+
+	return nil, io.EOF
+}
+
+// movieImport holds the state of an import while records are read and inserted.
+// Likely duplicates of existing movies, or of earlier rows, are rejected unless force is set.
+type movieImport struct {
+	tx     *sqlx.Tx
+	report importReport
+	batch  []*data.Movie
+	rows   []int
+	seen   map[importedTitle][]importedMovie
+	force  bool
+	failed bool
+}
+
+// importedTitle groups the rows of an import that may be duplicates of each other.
+type importedTitle struct {
+	key  string
+	year int32
+}
+
+// importedMovie is a valid row of an import.
+type importedMovie struct {
+	row   int
+	movie *data.Movie
+}
+
+//nolint:funlen,cyclop
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	validate := validator.New()
+
+	mode := app.readString(r.URL.Query(), "mode", importModeAtomic)
+	force := app.readBool(r.URL.Query(), "force", false, validate)
+	validate.CheckError(validator.PermittedValue(mode, importModeAtomic, importModeBestEffort), "mode",
+		validator.OneOf(importModeAtomic, importModeBestEffort))
+
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, app.config.importer.maxBytes)
+
+	reader, ok := newMovieRecordReader(body, mediaType(r))
+	if !ok {
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	// Imports are allowed to take longer than the server timeouts.
+	deadline := time.Now().Add(app.config.importer.timeout)
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(deadline)
+	_ = controller.SetWriteDeadline(deadline)
+
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+
+	tx, err := app.models.Movies.BeginTx(ctx)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	state := &movieImport{
+		tx:     tx,
+		report: importReport{Mode: mode, Rows: []importRow{}},
+		seen:   make(map[importedTitle][]importedMovie),
+		force:  force,
+	}
+
+	for row := 1; ; row++ {
+		var (
+			doc       *movieDocument
+			recordErr recordError
+		)
+
+		doc, err = reader.next()
+
+		switch {
+		case errors.Is(err, io.EOF):
+			app.flushImport(ctx, r, state)
+			app.finishImport(w, r, state)
+
+			return
+		case errors.As(err, &recordErr):
+			state.reject(row, recordErr.errors())
+			continue
+		case err != nil:
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				err = fmt.Errorf("%w, max size %d bytes", ErrBodyTooLarge, app.config.importer.maxBytes)
+			}
+
+			app.badRequestResponse(w, r, err)
+
+			return
+		}
+
+		movie := &data.Movie{Title: doc.Title, Year: doc.Year, Runtime: doc.Runtime, Genres: doc.Genres}
+
+		movieValidator := validator.New()
+		if data.ValidateMovie(movieValidator, movie, genres); !movieValidator.Valid() {
+			state.reject(row, movieValidator.Errors)
+			continue
+		}
+
+		if !state.force && state.rejectImported(row, movie) {
+			continue
+		}
+
+		state.batch = append(state.batch, movie)
+		state.rows = append(state.rows, row)
+
+		if len(state.batch) == importBatchSize {
+			app.flushImport(ctx, r, state)
+		}
+	}
+}
+
+// finishImport commits the import unless a row was rejected in atomic mode,
+// and writes the report.
+func (app *application) finishImport(w http.ResponseWriter, r *http.Request, state *movieImport) {
+	report := &state.report
+
+	slices.SortFunc(report.Rows, func(a, b importRow) int {
+		return cmp.Compare(a.Row, b.Row)
+	})
+
+	language := messageLanguage(r)
+	report.localize(language)
+
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", language)
+
+	if report.Mode == importModeAtomic && report.Rejected > 0 {
+		report.rollBack()
+
+		err := app.writeResponse(w, r, http.StatusUnprocessableEntity, envelope{"import": report}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if err := state.tx.Commit(); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	report.Committed = true
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"import": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reject records a rejected row. Once a row has been rejected in atomic mode,
+// the import is bound to be rolled back so no further batches are inserted.
+func (state *movieImport) reject(row int, rowErrors validator.Errors) {
+	state.report.reject(row, rowErrors)
+	state.failed = true
+}
+
+// rejectImported rejects the row if it holds a likely duplicate of an earlier row of the import.
+// Otherwise, the movie is remembered to check the following rows.
+func (state *movieImport) rejectImported(row int, movie *data.Movie) bool {
+	title := importedTitle{key: data.TitleKey(movie.Title), year: movie.Year}
+
+	var duplicateRows []int
+
+	for _, imported := range state.seen[title] {
+		if data.LikelyDuplicates(movie, imported.movie) {
+			duplicateRows = append(duplicateRows, imported.row)
+		}
+	}
+
+	if len(duplicateRows) == 0 {
+		state.seen[title] = append(state.seen[title], importedMovie{row: row, movie: movie})
+		return false
+	}
+
+	state.reject(row, recordErrors(validator.FieldError{
+		Code:    codeMovieDuplicate,
+		Message: "is likely a duplicate of an earlier row",
+	}))
+	state.report.Rows[len(state.report.Rows)-1].DuplicateRows = duplicateRows
+
+	return true
+}
+
+// rejectDuplicates rejects the rows of the current batch holding likely duplicates
+// of existing movies, and removes them from the batch. The batch is left untouched on error.
+func (app *application) rejectDuplicates(ctx context.Context, state *movieImport) error {
+	duplicates, err := app.models.Movies.FindDuplicatesTx(ctx, state.tx, state.batch)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	batch, rows := state.batch[:0], state.rows[:0]
+
+	for i, movie := range state.batch {
+		if len(duplicates[i]) == 0 {
+			batch = append(batch, movie)
+			rows = append(rows, state.rows[i])
+
+			continue
+		}
+
+		ids := make([]int64, 0, len(duplicates[i]))
+
+		for _, duplicate := range duplicates[i] {
+			ids = append(ids, duplicate.ID)
+		}
+
+		state.reject(state.rows[i], recordErrors(validator.FieldError{
+			Code:    codeMovieDuplicate,
+			Message: "is likely a duplicate of an existing movie",
+		}))
+		state.report.Rows[len(state.report.Rows)-1].Duplicates = ids
+	}
+
+	state.batch, state.rows = batch, rows
+
+	return nil
+}
+
+// flushImport inserts the current batch and reports its rows.
+func (app *application) flushImport(ctx context.Context, r *http.Request, state *movieImport) {
+	defer func() {
+		state.batch = state.batch[:0]
+		state.rows = state.rows[:0]
+	}()
+
+	if len(state.batch) == 0 {
+		return
+	}
+
+	if state.report.Mode == importModeAtomic && state.failed {
+		state.skipBatch()
+		return
+	}
+
+	if !state.force {
+		// Like the inserts, the lookup runs under a savepoint so that its failure
+		// doesn't abort the transaction of the whole import.
+		err := data.WithSavepoint(ctx, state.tx, func() error {
+			return app.rejectDuplicates(ctx, state)
+		})
+		if err != nil {
+			app.logError(r, err)
+
+			for _, row := range state.rows {
+				state.reject(row, recordErrors(validator.FieldError{
+					Code:    codeServerError,
+					Message: "could not be checked for duplicates",
+				}))
+			}
+
+			return
+		}
+
+		if state.report.Mode == importModeAtomic && state.failed {
+			state.skipBatch()
+			return
+		}
+	}
+
+	userID := app.contextGetUser(r).ID
+
+	err := app.models.Movies.InsertBatch(ctx, state.tx, state.batch, userID)
+	if err == nil {
+		for i, row := range state.rows {
+			state.report.accept(row, state.batch[i].ID)
+		}
+
+		return
+	}
+
+	// The batch was rolled back to its savepoint: insert its movies one at a time
+	// so that only the offending rows are rejected.
+	for i, movie := range state.batch {
+		if state.report.Mode == importModeAtomic && state.failed {
+			state.report.skip(state.rows[i])
+			continue
+		}
+
+		if err = app.models.Movies.InsertBatch(ctx, state.tx, []*data.Movie{movie}, userID); err != nil {
+			app.logError(r, err)
+			state.reject(state.rows[i], recordErrors(validator.FieldError{Code: codeServerError, Message: "could not be inserted"}))
+
+			continue
+		}
+
+		state.report.accept(state.rows[i], movie.ID)
+	}
+}
+
+// skipBatch reports the rows of the current batch as skipped, once an atomic
+// import is bound to be rolled back.
+func (state *movieImport) skipBatch() {
+	for _, row := range state.rows {
+		state.report.skip(row)
+	}
+}
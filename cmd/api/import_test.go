@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func readAllRecords(t *testing.T, reader movieRecordReader) ([]*movieDocument, int) {
+	t.Helper()
+
+	var (
+		movies   []*movieDocument
+		rejected int
+	)
+
+	for {
+		movie, err := reader.next()
+
+		var recordErr recordError
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return movies, rejected
+		case errors.As(err, &recordErr):
+			rejected++
+		case err != nil:
+			t.Fatal(err)
+		default:
+			movies = append(movies, movie)
+		}
+	}
+}
+
+func TestCSVMovieReader(t *testing.T) {
+	t.Parallel()
+
+	body := `Title,Year,Runtime,Genres
+Moana,2016,107,"animation,adventure"
+Black Panther,2018,134 mins,action
+Deadpool,not a year,108,action
+Too,many,fields,in,this,row
+`
+
+	reader, ok := newMovieRecordReader(strings.NewReader(body), "text/csv")
+	if !ok {
+		t.Fatal("text/csv not supported")
+	}
+
+	movies, rejected := readAllRecords(t, reader)
+
+	if len(movies) != 2 || rejected != 2 {
+		t.Fatalf("got %d movies and %d rejected records, want 2 and 2", len(movies), rejected)
+	}
+
+	if movies[0].Title != "Moana" || movies[0].Runtime != 107 ||
+		!slices.Equal(movies[0].Genres, []string{"animation", "adventure"}) {
+		t.Errorf("got %+v", movies[0])
+	}
+
+	if movies[1].Runtime != 134 {
+		t.Errorf("got runtime %d want 134", movies[1].Runtime)
+	}
+}
+
+func TestCSVMovieReaderMissingColumn(t *testing.T) {
+	t.Parallel()
+
+	reader, _ := newMovieRecordReader(strings.NewReader("title,year,genres\n"), "text/csv")
+
+	_, err := reader.next()
+	if !errors.Is(err, ErrMissingCSVColumn) {
+		t.Errorf("got error %v want %v", err, ErrMissingCSVColumn)
+	}
+}
+
+func TestNDJSONMovieReader(t *testing.T) {
+	t.Parallel()
+
+	body := `{"title": "Moana", "year": 2016, "runtime": "107 mins", "genres": ["animation"]}
+
+{"title": "Black Panther", "director": "Ryan Coogler"}
+{"title":
+`
+
+	reader, ok := newMovieRecordReader(strings.NewReader(body), "application/x-ndjson")
+	if !ok {
+		t.Fatal("application/x-ndjson not supported")
+	}
+
+	movies, rejected := readAllRecords(t, reader)
+
+	if len(movies) != 1 || rejected != 2 {
+		t.Fatalf("got %d movies and %d rejected records, want 1 and 2", len(movies), rejected)
+	}
+}
+
+func TestMovieImportRejectImported(t *testing.T) {
+	t.Parallel()
+
+	state := &movieImport{report: importReport{Mode: importModeBestEffort}, seen: make(map[importedTitle][]importedMovie)}
+
+	movies := []*data.Movie{
+		{Title: "The Matrix", Year: 1999, Runtime: 136},
+		{Title: "Moana", Year: 2016, Runtime: 107},
+		{Title: "the matrix!", Year: 1999, Runtime: 138},
+		{Title: "The Matrix", Year: 2021, Runtime: 148},
+	}
+
+	var rejected []int
+
+	for i, movie := range movies {
+		if state.rejectImported(i+1, movie) {
+			rejected = append(rejected, i+1)
+		}
+	}
+
+	if !slices.Equal(rejected, []int{3}) {
+		t.Fatalf("got rejected rows %v want [3]", rejected)
+	}
+
+	row := state.report.Rows[0]
+	if row.Row != 3 || row.Status != importStatusRejected || !slices.Equal(row.DuplicateRows, []int{1}) {
+		t.Errorf("got %+v", row)
+	}
+}
+
+func TestImportReportRollBack(t *testing.T) {
+	t.Parallel()
+
+	var report importReport
+
+	report.accept(1, 10)
+	report.reject(2, recordErrors(validator.FieldError{Code: codeServerError, Message: "could not be inserted"}))
+	report.accept(3, 11)
+	report.skip(4)
+	report.rollBack()
+
+	if report.Total != 4 || report.Accepted != 0 || report.Rejected != 1 || report.Skipped != 1 || report.RolledBack != 2 {
+		t.Errorf("got %+v", report)
+	}
+
+	want := []string{importStatusRolledBack, importStatusRejected, importStatusRolledBack, importStatusSkipped}
+
+	for i, row := range report.Rows {
+		if row.Status != want[i] || row.ID != 0 {
+			t.Errorf("got row %+v want status %s and no id", row, want[i])
+		}
+	}
+}
+
+func TestImportReportLocalize(t *testing.T) {
+	t.Parallel()
+
+	var report importReport
+
+	report.accept(1, 10)
+	report.reject(2, recordErrors(validator.FieldError{Code: codeMovieDuplicate, Message: "is likely a duplicate"}))
+	report.localize("en")
+
+	want, _ := message("en", codeMovieDuplicate, nil)
+
+	if report.Rows[0].Errors != nil {
+		t.Errorf("got errors %+v for an accepted row", report.Rows[0].Errors)
+	}
+
+	if errs := report.Rows[1].Errors; len(errs) != 1 || errs[0]["pointer"] != "/record" ||
+		errs[0]["code"] != codeMovieDuplicate || errs[0]["detail"] != want {
+		t.Errorf("got errors %+v", errs)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// startJobs starts the periodic background jobs.
+// They stop when ctx is cancelled.
+func (app *application) startJobs(ctx context.Context) {
+	if app.config.trash.retention > 0 && app.config.trash.purgeInterval > 0 {
+		app.every(ctx, app.config.trash.purgeInterval, app.purgeTrash)
+	}
+
+	if app.config.recommendations.refreshInterval > 0 {
+		// Refresh right away: recommendations may be missing or stale after downtime.
+		app.background(app.refreshRecommendations)
+		app.every(ctx, app.config.recommendations.refreshInterval, app.refreshRecommendations)
+	}
+}
+
+// every runs job in the background each interval until ctx is cancelled.
+func (app *application) every(ctx context.Context, interval time.Duration, job func()) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				job()
+			}
+		}
+	})
+}
+
+func (app *application) purgeTrash() {
+	purged, err := app.models.Movies.Purge(app.config.trash.retention)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	if purged > 0 {
+		app.logger.Info("purged movies from trash", "count", purged)
+	}
+}
+
+func (app *application) refreshRecommendations() {
+	start := time.Now()
+
+	refreshed, err := app.models.Recommendations.Refresh()
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	if refreshed {
+		app.logger.Info("refreshed recommendations", "duration", time.Since(start))
+	}
+}
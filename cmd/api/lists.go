@@ -0,0 +1,404 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (app *application) listUserListsHandler(w http.ResponseWriter, r *http.Request) {
+	lists, err := app.models.Lists.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"lists": lists}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createUserListHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string `json:"name"`
+		Public bool   `json:"public"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	list := &data.List{UserID: app.contextGetUser(r).ID, Kind: data.ListCollection, Name: input.Name, Public: input.Public}
+	v := validator.New()
+
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Insert(list)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/users/me/lists/%d", list.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"list": list}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showUserListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getUserListOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeList(w, r, list)
+}
+
+//nolint:cyclop
+func (app *application) updateUserListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getUserListOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name   *string `json:"name"`
+		Public *bool   `json:"public"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if input.Name != nil {
+		data.RenameList(v, list, *input.Name)
+	}
+
+	if input.Public != nil {
+		list.Public = *input.Public
+	}
+
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Update(list)
+
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteUserListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getUserListOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	if list.Kind != data.ListCollection {
+		app.errorResponse(w, r, http.StatusConflict, codeListNotCollection, nil)
+		return
+	}
+
+	err := app.models.Lists.Delete(list.ID)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "list successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putUserListItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getUserListOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// The body is optional: the movie is appended when no position is given.
+	var input struct {
+		Position *int32 `json:"position"`
+	}
+
+	err = app.readRequest(w, r, &input)
+	if err != nil && !errors.Is(err, ErrEmptyBody) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if v.CheckError(input.Position == nil || *input.Position >= 0, "position", validator.Min[int32](0)); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	item, err := app.models.Lists.PutItem(list.ID, movieID, input.Position)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"item": item}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteUserListItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getUserListOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(r, "movie_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Lists.DeleteItem(list.ID, movieID)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "movie successfully removed from list"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) shareUserListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getUserListOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := app.models.Lists.Share(list)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	share := envelope{"token": token, "url": "/v1/shared-lists/" + token}
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"list": list, "share": share}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) unshareUserListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.getUserListOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Lists.Unshare(list)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPublicListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	list, err := app.models.Lists.GetPublic(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeList(w, r, list)
+}
+
+func (app *application) showSharedListHandler(w http.ResponseWriter, r *http.Request) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	list, err := app.models.Lists.GetShared(token)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeList(w, r, list)
+}
+
+// writeList writes a list along with a page of its movies.
+func (app *application) writeList(w http.ResponseWriter, r *http.Request, list *data.List) {
+	validate := validator.New()
+
+	urlValues := r.URL.Query()
+
+	const (
+		defaultPageSize = 20
+		defaultPage     = 1
+	)
+
+	filters := data.Filters{
+		Page:         app.readInt(urlValues, "page", defaultPage, validate),
+		PageSize:     app.readInt(urlValues, "page_size", defaultPageSize, validate),
+		Sort:         "position",
+		SortSafelist: []string{"position"},
+	}
+
+	if data.ValidateFilters(validate, filters); !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Lists.GetItems(list.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"list": list, "items": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getUserListOrRespond returns the list of the authenticated user identified by the route,
+// either by ID or by kind for the watchlist and watched list.
+// When it returns false, an error response has been sent.
+func (app *application) getUserListOrRespond(w http.ResponseWriter, r *http.Request) (*data.List, bool) {
+	userID := app.contextGetUser(r).ID
+
+	var (
+		list *data.List
+		err  error
+	)
+
+	switch kind := httprouter.ParamsFromContext(r.Context()).ByName("list"); kind {
+	case data.ListWatchlist, data.ListWatched:
+		list, err = app.models.Lists.GetDefault(userID, kind)
+	default:
+		var id int64
+
+		id, err = app.readNamedIDParam(r, "list")
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return nil, false
+		}
+
+		list, err = app.models.Lists.GetForUser(userID, id)
+	}
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return nil, false
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	return list, true
+}
+
+// annotateMovies sets the user-specific fields of the movies served to the authenticated user.
+func (app *application) annotateMovies(r *http.Request, movies ...*data.Movie) error {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	inWatchlist, err := app.models.Lists.InWatchlist(user.ID, ids...)
+	if err != nil {
+		return fmt.Errorf("getting watchlist: %w", err)
+	}
+
+	for _, movie := range movies {
+		movie.InWatchlist = new(bool)
+		*movie.InWatchlist = inWatchlist[movie.ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+// Formats of the logs, see the -log-format flag.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var errUnknownLogFormat = errors.New("unknown log format")
+
+// redacted replaces the values of sensitive attributes in the logs.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the fragments of the keys of attributes whose values are never logged.
+var sensitiveKeys = []string{"password", "token", "email", "secret", "authorization", "dsn"}
+
+// emailRX matches email addresses in logged strings, like error messages.
+var emailRX = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+
+// newLogger returns a logger writing records of the level or above to w, in the format.
+// Sensitive attributes are redacted, see [redactAttr], and records are tagged with their request ID.
+func newLogger(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+
+	switch format {
+	case logFormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("%w %q", errUnknownLogFormat, format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// redactAttr hides the values of attributes with a sensitive key, and the email addresses of strings.
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	if attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, emailRX.ReplaceAllString(attr.Value.String(), redacted))
+	}
+
+	return attr
+}
+
+// A contextHandler adds the ID of the request found in the context to the records it handles,
+// so that every log of a request can be correlated with its access log.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	return h.Handler.Handle(ctx, record) //nolint:wrapcheck
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// A samplingHandler handles the first records of each level and message in every interval,
+// and drops the others. The next record handled after some were dropped reports how many.
+// It's meant for logs repeated periodically, like the ones of the rate limiter.
+type samplingHandler struct {
+	slog.Handler
+	sampler *sampler
+}
+
+// newSamplingHandler returns handler as is if interval isn't positive.
+func newSamplingHandler(handler slog.Handler, interval time.Duration, first int) slog.Handler {
+	if interval <= 0 {
+		return handler
+	}
+
+	return samplingHandler{
+		Handler: handler,
+		sampler: &sampler{interval: interval, first: first, samples: make(map[string]*sample)},
+	}
+}
+
+func (h samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	handle, dropped := h.sampler.sample(record)
+	if !handle {
+		return nil
+	}
+
+	if dropped > 0 {
+		record.AddAttrs(slog.Int("dropped", dropped))
+	}
+
+	return h.Handler.Handle(ctx, record) //nolint:wrapcheck
+}
+
+func (h samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return samplingHandler{h.Handler.WithAttrs(attrs), h.sampler}
+}
+
+func (h samplingHandler) WithGroup(name string) slog.Handler {
+	return samplingHandler{h.Handler.WithGroup(name), h.sampler}
+}
+
+type sampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	first    int
+	samples  map[string]*sample
+}
+
+// A sample counts the records of a level and message since the start of the current interval.
+type sample struct {
+	start   time.Time
+	count   int
+	dropped int
+}
+
+// sample reports whether the record must be handled and, if so, how many similar ones were dropped before.
+func (s *sampler) sample(record slog.Record) (bool, int) {
+	key := record.Level.String() + " " + record.Message
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, found := s.samples[key]
+
+	if !found || record.Time.Sub(current.start) >= s.interval {
+		dropped := 0
+		if found {
+			dropped = current.dropped
+		}
+
+		s.samples[key] = &sample{start: record.Time, count: 1}
+
+		return true, dropped
+	}
+
+	if current.count < s.first {
+		current.count++
+		return true, 0
+	}
+
+	current.dropped++
+
+	return false, 0
+}
+
+// showLogLevelHandler sends the current minimum level of the logs.
+func (app *application) showLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"level": app.logLevel.Level().String()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateLogLevelHandler changes the minimum level of the logs until the next change or restart.
+func (app *application) updateLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Level string `json:"level"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var level slog.Level
+
+	v := validator.New()
+	v.CheckError(input.Level != "", "level", validator.Required())
+
+	if v.Valid() {
+		v.CheckError(level.UnmarshalText([]byte(input.Level)) == nil, "level",
+			validator.OneOf("debug", "info", "warn", "error"),
+		)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	previous := app.logLevel.Level()
+	app.logLevel.Set(level)
+
+	app.logger.WarnContext(r.Context(), "changed log level", "from", previous, "to", level)
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"level": level.String()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
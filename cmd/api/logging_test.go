@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	level := new(slog.LevelVar)
+
+	logger, err := newLogger(&buf, logFormatJSON, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Debug("hidden")
+	logger.Info("sending mail to alice@example.com failed",
+		"token", "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU", "user_email", "alice@example.com", "Password", "pa55word",
+		"error", "550 unknown user bob@example.org", "movie_id", 12,
+	)
+
+	want := `"msg":"sending mail to [REDACTED] failed","token":"[REDACTED]","user_email":"[REDACTED]",` +
+		`"Password":"[REDACTED]","error":"550 unknown user [REDACTED]","movie_id":12}` + "\n"
+
+	if got := buf.String(); strings.Count(got, "\n") != 1 || !strings.HasSuffix(got, want) {
+		t.Errorf("got logs\n%s\nwant suffix\n%s", got, want)
+	}
+
+	buf.Reset()
+	level.Set(slog.LevelDebug)
+	logger.Debug("shown")
+
+	if !strings.Contains(buf.String(), `"msg":"shown"`) {
+		t.Errorf("got logs %s", buf.String())
+	}
+
+	if _, err = newLogger(&buf, "xml", level); !errors.Is(err, errUnknownLogFormat) {
+		t.Errorf("got error %v want %v", err, errUnknownLogFormat)
+	}
+}
+
+func TestSamplingHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	handler := newSamplingHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return attr
+		},
+	}), 10*time.Minute, 2)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 12; i++ {
+		for _, msg := range []string{"ticking", "deleting ip"} {
+			record := slog.NewRecord(start.Add(time.Duration(i)*time.Minute), slog.LevelInfo, msg, 0)
+			if err := handler.Handle(context.Background(), record); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	want := strings.Repeat("level=INFO msg=ticking\nlevel=INFO msg=\"deleting ip\"\n", 2) +
+		"level=INFO msg=ticking dropped=8\nlevel=INFO msg=\"deleting ip\" dropped=8\n" +
+		"level=INFO msg=ticking\nlevel=INFO msg=\"deleting ip\"\n"
+
+	if got := buf.String(); got != want {
+		t.Errorf("got logs\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdateLogLevelHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       slog.Level
+	}{
+		{name: "debug", body: `{"level":"debug"}`, wantStatus: http.StatusOK, want: slog.LevelDebug},
+		{name: "upper case", body: `{"level":"ERROR"}`, wantStatus: http.StatusOK, want: slog.LevelError},
+		{name: "missing", body: `{}`, wantStatus: http.StatusUnprocessableEntity, want: slog.LevelInfo},
+		{name: "unknown", body: `{"level":"verbose"}`, wantStatus: http.StatusUnprocessableEntity, want: slog.LevelInfo},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodPut, "/v1/admin/log-level", strings.NewReader(test.body))
+			r.Header.Set("Content-Type", mediaTypeJSON)
+
+			w := httptest.NewRecorder()
+			app.updateLogLevelHandler(w, r)
+
+			if w.Code != test.wantStatus {
+				t.Errorf("got status %d want %d: %s", w.Code, test.wantStatus, w.Body.String())
+			}
+
+			if got := app.logLevel.Level(); got != test.want {
+				t.Errorf("got level %v want %v", got, test.want)
+			}
+		})
+	}
+}
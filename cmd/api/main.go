@@ -2,15 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"expvar"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"runtime"
 	"sync"
 	"time"
 
+	"github.com/Crocmagnon/greenlight/internal/blob"
 	"github.com/Crocmagnon/greenlight/internal/data"
 	"github.com/Crocmagnon/greenlight/internal/mailer"
 	"github.com/Crocmagnon/greenlight/internal/vcs"
@@ -42,15 +45,50 @@ type config struct {
 		password string
 		sender   string
 	}
+	importer struct {
+		maxBytes int64
+		timeout  time.Duration
+	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	posters struct {
+		maxBytes     int64
+		maxDimension int
+		timeout      time.Duration
+	}
+	blob struct {
+		store string
+		dir   string
+		s3    blob.S3Config
+	}
+	stats struct {
+		cacheTTL time.Duration
+	}
+	recommendations struct {
+		refreshInterval time.Duration
+	}
+	log struct {
+		format         string
+		level          slog.Level
+		sampleInterval time.Duration
+		sampleFirst    int
+	}
 	metricsEnabled bool
+	requireIfMatch bool
 }
 
 type application struct {
 	config config
 	logger *slog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	// logLevel is the minimum level of the logs, which can be changed while running.
+	logLevel *slog.LevelVar
+	models   data.Models
+	mailer   mailer.Mailer
+	blobs    blob.Store
+	stats    statsCache
+	wg       sync.WaitGroup
 }
 
 func main() {
@@ -77,7 +115,47 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.augendre.info>", "SMTP sender")
 
+	flag.Int64Var(&cfg.importer.maxBytes, "import-max-bytes", 100<<20, "Maximum size of a movie import body")
+	flag.DurationVar(&cfg.importer.timeout, "import-timeout", 5*time.Minute, "Maximum duration of a movie import")
+
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour,
+		"Duration deleted movies are kept in the trash before being purged (0 to keep forever)",
+	)
+	flag.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour,
+		"Interval between trash purges (0 to disable purges)",
+	)
+
+	flag.Int64Var(&cfg.posters.maxBytes, "poster-max-bytes", 10<<20, "Maximum size of a poster upload")
+	flag.IntVar(&cfg.posters.maxDimension, "poster-max-dimension", 6000, "Maximum width and height of a poster in pixels")
+	flag.DurationVar(&cfg.posters.timeout, "poster-timeout", time.Minute, "Maximum duration of a poster upload")
+
+	flag.StringVar(&cfg.blob.store, "blob-store", "local", "Store for uploaded images (local|s3)")
+	flag.StringVar(&cfg.blob.dir, "blob-dir", "./uploads", "Directory of the local blob store")
+	flag.StringVar(&cfg.blob.s3.Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL")
+	flag.StringVar(&cfg.blob.s3.Region, "s3-region", "us-east-1", "S3 region")
+	flag.StringVar(&cfg.blob.s3.Bucket, "s3-bucket", "", "S3 bucket")
+	flag.StringVar(&cfg.blob.s3.AccessKey, "s3-access-key", "", "S3 access key")
+	flag.StringVar(&cfg.blob.s3.SecretKey, "s3-secret-key", "", "S3 secret key")
+
+	flag.DurationVar(&cfg.stats.cacheTTL, "stats-cache-ttl", 5*time.Minute,
+		"Duration movie statistics are cached (0 to disable caching)",
+	)
+
+	flag.DurationVar(&cfg.recommendations.refreshInterval, "recommendations-refresh-interval", time.Hour,
+		"Interval between refreshes of similar movies and recommendations (0 to disable)",
+	)
+
+	flag.StringVar(&cfg.log.format, "log-format", logFormatText, "Log format (text|json)")
+	flag.TextVar(&cfg.log.level, "log-level", slog.LevelInfo, "Minimum log level (debug|info|warn|error)")
+	flag.DurationVar(&cfg.log.sampleInterval, "log-sample-interval", 10*time.Minute,
+		"Interval in which repetitive logs are sampled (0 to disable sampling)",
+	)
+	flag.IntVar(&cfg.log.sampleFirst, "log-sample-first", 1, "Number of identical repetitive logs kept per sampling interval")
+
 	flag.BoolVar(&cfg.metricsEnabled, "metrics-enabled", true, "Enable metrics endpoint")
+	flag.BoolVar(&cfg.requireIfMatch, "require-if-match", true,
+		"Reject movie updates and deletions without an If-Match header (set to false to allow blind writes)",
+	)
 
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
@@ -88,7 +166,14 @@ func main() {
 		os.Exit(0)
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.log.level)
+
+	logger, err := newLogger(os.Stdout, cfg.log.format, logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	db, err := openDB(cfg)
 	if err != nil {
@@ -100,11 +185,19 @@ func main() {
 
 	logger.Info("database connection established")
 
+	blobs, err := openBlobStore(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		config:   cfg,
+		logger:   logger,
+		logLevel: logLevel,
+		models:   data.NewModels(db),
+		mailer:   mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		blobs:    blobs,
 	}
 	app.setupMetrics()
 
@@ -157,3 +250,22 @@ func openDB(cfg config) (*sqlx.DB, error) {
 
 	return db, nil
 }
+
+// ErrUnknownBlobStore is returned when the configured blob store doesn't exist.
+var ErrUnknownBlobStore = errors.New("unknown blob store")
+
+func openBlobStore(cfg config) (blob.Store, error) {
+	switch cfg.blob.store {
+	case "local":
+		store, err := blob.NewLocalStore(cfg.blob.dir)
+		if err != nil {
+			return nil, fmt.Errorf("opening local blob store: %w", err)
+		}
+
+		return store, nil
+	case "s3":
+		return blob.NewS3Store(cfg.blob.s3, &http.Client{Timeout: time.Minute}), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownBlobStore, cfg.blob.store)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// defaultLanguage is the language of messages sent to clients accepting none of the shipped ones.
+const defaultLanguage = "en"
+
+//go:embed "messages"
+var messageFS embed.FS
+
+// catalogues holds the messages of every shipped language, see [loadCatalogues].
+var catalogues = mustLoadCatalogues(messageFS)
+
+// A catalogue maps error codes to the message templates of a language.
+// Templates reference the parameters of errors between braces, e.g. {max}.
+type catalogue map[string]string
+
+// loadCatalogues reads the catalogues of the messages directory, one JSON file per language.
+func loadCatalogues(fsys fs.FS) (map[string]catalogue, error) {
+	files, err := fs.Glob(fsys, "messages/*.json")
+	if err != nil {
+		return nil, fmt.Errorf("listing message catalogues: %w", err)
+	}
+
+	loaded := make(map[string]catalogue, len(files))
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("reading message catalogue %s: %w", file, err)
+		}
+
+		var messages catalogue
+
+		if err = json.Unmarshal(content, &messages); err != nil {
+			return nil, fmt.Errorf("decoding message catalogue %s: %w", file, err)
+		}
+
+		loaded[strings.TrimSuffix(path.Base(file), ".json")] = messages
+	}
+
+	if _, found := loaded[defaultLanguage]; !found {
+		return nil, fmt.Errorf("missing %s message catalogue", defaultLanguage) //nolint:goerr113
+	}
+
+	return loaded, nil
+}
+
+func mustLoadCatalogues(fsys fs.FS) map[string]catalogue {
+	loaded, err := loadCatalogues(fsys)
+	if err != nil {
+		panic(err)
+	}
+
+	return loaded
+}
+
+// messageLanguage returns the shipped language preferred by the client, according to Accept-Language.
+func messageLanguage(r *http.Request) string {
+	for _, locale := range acceptedLocales(r.Header.Get("Accept-Language")) {
+		if _, found := catalogues[locale]; found {
+			return locale
+		}
+	}
+
+	return defaultLanguage
+}
+
+// message returns the message of the code in the language, with the parameters substituted.
+// It reports false when the language has no message for the code.
+func message(language, code string, params map[string]any) (string, bool) {
+	template, found := catalogues[language][code]
+	if !found {
+		return "", false
+	}
+
+	replacements := make([]string, 0, 2*len(params)) //nolint:gomnd
+
+	for name, value := range params {
+		text := fmt.Sprint(value)
+		if values, ok := value.([]string); ok {
+			text = strings.Join(values, ", ")
+		}
+
+		replacements = append(replacements, "{"+name+"}", text)
+	}
+
+	return strings.NewReplacer(replacements...).Replace(template), true
+}
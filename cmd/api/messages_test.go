@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+// messageCodes lists every code which must have a message in every shipped language.
+var messageCodes = []string{
+	codeServerError, codeNotFound, codeEditConflict, codeMethodNotAllowed, codeMalformedRequest,
+	codeUnsupportedMediaType, codeNotAcceptable, codeValidationFailed, codePreconditionFailed,
+	codePreconditionRequired, codeRateLimitExceeded, codeMovieNotFound, codeMovieDuplicate,
+	codeMoviePatchTestFailed, codeExternalIDTaken, codeGenreInUse, codeListNotCollection,
+	codeInvalidCredentials, codeTokenInvalid, codeAuthenticationMissing, codeAccountInactive, codeNotPermitted,
+	codeRecordFieldCount, codeRecordMalformed,
+
+	validator.CodeRequired, validator.CodeMinLength, validator.CodeMaxLength, validator.CodeLength,
+	validator.CodeGreaterThan,
+	validator.CodeMin, validator.CodeMax, validator.CodeMinItems, validator.CodeMaxItems, validator.CodeUnique,
+	validator.CodeOneOf, validator.CodeUnknownValue, validator.CodeEmail, validator.CodeInteger, validator.CodeBoolean,
+
+	data.CodeYearInFuture, data.CodeDateFormat, data.CodeCountryCode, data.CodeReleaseTooEarly,
+	data.CodeNoCertifications, data.CodeDuplicateRelease, data.CodeIMDbID, data.CodeTMDBID, data.CodeWikidataID,
+	data.CodeDuplicateEmail, data.CodeAlreadyReviewed, data.CodeNotCollection, data.CodeSurroundingSpaces,
+	data.CodeLettersOrDigits, data.CodeGenreClash, data.CodeMergeIntoSelf, data.CodeUnknownGenre,
+	data.CodeBirthYearInFuture, data.CodeActorsOnly, data.CodeDuplicateCredit, data.CodeUnknownPerson,
+	data.CodeLanguageTag, data.CodeTokenInvalid, data.CodeRuntime, data.CodeImageFormat, data.CodeImageInvalid,
+	data.CodeImageTooSmall, data.CodeImageTooLarge,
+}
+
+func TestCataloguesComplete(t *testing.T) {
+	t.Parallel()
+
+	placeholderRX := regexp.MustCompile(`\{\w+\}`)
+
+	placeholders := func(template string) []string {
+		found := placeholderRX.FindAllString(template, -1)
+		slices.Sort(found)
+
+		return found
+	}
+
+	if len(catalogues) < 2 { //nolint:gomnd
+		t.Fatalf("got %d catalogues, want English and French at least", len(catalogues))
+	}
+
+	for language, messages := range catalogues {
+		for _, code := range messageCodes {
+			template, found := messages[code]
+			if !found {
+				t.Errorf("%s: missing message for %q", language, code)
+				continue
+			}
+
+			want := placeholders(catalogues[defaultLanguage][code])
+			if got := placeholders(template); !slices.Equal(got, want) {
+				t.Errorf("%s: %q has placeholders %v want %v", language, code, got, want)
+			}
+		}
+
+		for code := range messages {
+			if !slices.Contains(messageCodes, code) {
+				t.Errorf("%s: unknown code %q", language, code)
+			}
+		}
+	}
+}
+
+// TestProducedCodesHaveMessages checks that every error code declared by the API and
+// the packages it validates with has a message, and that no code falls back to the
+// generic invalid code, which has none.
+func TestProducedCodesHaveMessages(t *testing.T) {
+	t.Parallel()
+
+	codeRX := regexp.MustCompile(`^[cC]ode[A-Z]`)
+	fset := token.NewFileSet()
+
+	for _, dir := range []string{".", "../../internal/data", "../../internal/validator"} {
+		packages, err := parser.ParseDir(fset, dir, func(info fs.FileInfo) bool {
+			return !strings.HasSuffix(info.Name(), "_test.go")
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, pkg := range packages {
+			ast.Inspect(pkg, func(node ast.Node) bool {
+				switch node := node.(type) {
+				case *ast.ValueSpec:
+					for i, name := range node.Names {
+						if !codeRX.MatchString(name.Name) || name.Name == "CodeInvalid" || i >= len(node.Values) {
+							continue
+						}
+
+						if literal, ok := node.Values[i].(*ast.BasicLit); ok && literal.Kind == token.STRING {
+							code, _ := strconv.Unquote(literal.Value)
+
+							for language, messages := range catalogues {
+								if _, found := messages[code]; !found {
+									t.Errorf("%s: %s = %q has no %s message", fset.Position(name.Pos()), name.Name, code, language)
+								}
+							}
+						}
+					}
+				case *ast.SelectorExpr:
+					if pkg.Name != "validator" && slices.Contains([]string{"Check", "AddError", "Invalid"}, node.Sel.Name) {
+						t.Errorf("%s: %s reports the generic invalid code", fset.Position(node.Pos()), node.Sel.Name)
+					}
+				}
+
+				return true
+			})
+		}
+	}
+}
+
+func TestDefaultMessages(t *testing.T) {
+	t.Parallel()
+
+	for _, err := range []validator.FieldError{
+		validator.Required(), validator.MinLength(8), validator.MaxLength(500), validator.GreaterThan(0),
+		validator.Min(1), validator.Max(100), validator.MinItems(1), validator.MaxItems(5), validator.NotUnique(),
+		validator.OneOf("director", "actor"), validator.UnknownValue("Western"), validator.InvalidEmail(),
+		validator.Integer(), validator.Boolean(), validator.Length(26), data.YearInFuture(), data.InvalidDate(),
+		data.InvalidCountryCode(), data.InvalidRuntime(),
+	} {
+		if got, _ := message(defaultLanguage, err.Code, err.Params); got != err.Message {
+			t.Errorf("%q: got message %q want %q", err.Code, got, err.Message)
+		}
+	}
+}
+
+func TestMessageLanguage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"fr-CA, en;q=0.5", "fr"},
+		{"de, fr;q=0.8", "fr"},
+		{"de", "en"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Language", test.header)
+
+		if got := messageLanguage(r); got != test.want {
+			t.Errorf("%q: got %q want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestLocalizedProblemResponse(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "fr-FR")
+
+	validate := validator.New()
+	validate.Add("title", validator.MaxLength(500))
+	validate.AddError("fields", "invalid fields value")
+
+	w := httptest.NewRecorder()
+	app.failedValidationResponse(w, r, validate.Errors)
+
+	if got := w.Header().Get("Content-Language"); got != "fr" {
+		t.Errorf("got Content-Language %q want fr", got)
+	}
+
+	var got struct {
+		Detail string `json:"detail"`
+		Errors []struct {
+			Pointer string `json:"pointer"`
+			Detail  string `json:"detail"`
+		} `json:"errors"`
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Detail != "la requête contient des valeurs invalides" || len(got.Errors) != 2 ||
+		got.Errors[0].Detail != "invalid fields value" ||
+		got.Errors[1].Detail != "ne doit pas faire plus de 500 octets" {
+		t.Errorf("got %+v", got)
+	}
+}
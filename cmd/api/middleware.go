@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"expvar"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -45,13 +50,16 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 
 	ticker := time.NewTicker(time.Minute)
 
+	// The cleanup logs the same lines every minute.
+	logger := slog.New(newSamplingHandler(app.logger.Handler(), app.config.log.sampleInterval, app.config.log.sampleFirst))
+
 	go func() {
 		for range ticker.C {
-			app.logger.Info("ticking")
+			logger.Info("ticking")
 			mu.Lock()
 			for ip, client := range clients {
 				if time.Since(client.lastSeen) > time.Duration(app.config.limiter.lastSeenMinutes)*time.Minute {
-					app.logger.Info("deleting ip", "ip", ip)
+					logger.Info("deleting ip", "ip", ip)
 					delete(clients, ip)
 				}
 			}
@@ -82,6 +90,66 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// runtimeFormat reads the format of runtimes requested with the runtime_format parameter
+// or the Runtime-Format header, the parameter taking precedence.
+func (app *application) runtimeFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Runtime-Format")
+
+		value := r.URL.Query().Get("runtime_format")
+		if value == "" {
+			value = r.Header.Get("Runtime-Format")
+		}
+
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		format := data.RuntimeFormat(strings.ToLower(strings.TrimSpace(value)))
+
+		validate := validator.New()
+		validate.CheckError(validator.PermittedValue(format, data.RuntimeFormats...), "runtime_format",
+			validator.OneOf(runtimeFormatNames()...))
+
+		if !validate.Valid() {
+			app.failedValidationResponse(w, r, validate.Errors)
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetRuntimeFormat(r, format))
+	})
+}
+
+// runtimeFormatNames returns the names of the known runtime formats.
+func runtimeFormatNames() []string {
+	names := make([]string, 0, len(data.RuntimeFormats))
+
+	for _, format := range data.RuntimeFormats {
+		names = append(names, string(format))
+	}
+
+	return names
+}
+
+// negotiate rejects the requests which could modify resources before they're handled,
+// when the client accepts none of the media types of responses.
+// Other requests get 406 Not Acceptable when their response is written.
+func (app *application) negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+
+		if !safe && len(acceptedEncoders(r.Header.Get("Accept"))) == 0 {
+			w.Header().Add("Vary", "Accept")
+			app.notAcceptableResponse(w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -193,3 +261,92 @@ func (app *application) metrics(next http.Handler) http.Handler {
 		totalProcessingTimeMicroseconds.Add(duration)
 	})
 }
+
+// requestIDRX matches the request IDs accepted from clients, others being replaced by generated ones.
+var requestIDRX = regexp.MustCompile(`^[\w.:-]{1,128}$`)
+
+// requestID identifies the request with the X-Request-ID header sent by the client, or a random ID.
+// The ID is echoed in the X-Request-ID header of the response and added to the logs of the request.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+
+		if !requestIDRX.MatchString(id) {
+			b := make([]byte, 16) //nolint:gomnd
+
+			if _, err := rand.Read(b); err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
+// An accessLogEntry collects the values of the access log known only deeper in the middleware chain.
+type accessLogEntry struct {
+	user  *data.User
+	route string
+}
+
+// logAccess logs a line per request once it has been handled.
+// The route is the pattern of the route which handled the request, see [patternRouter].
+func (app *application) logAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), accessLogContextKey, entry)))
+
+		attrs := []any{
+			"method", r.Method,
+			"route", entry.route,
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"duration", time.Since(start),
+		}
+
+		if entry.user != nil && !entry.user.IsAnonymous() {
+			attrs = append(attrs, "user_id", entry.user.ID)
+		}
+
+		app.logger.InfoContext(r.Context(), "request", attrs...)
+	})
+}
+
+// A responseRecorder records the status and size of the response written through it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+
+	return n, err //nolint:wrapcheck
+}
+
+// Unwrap lets [http.ResponseController] reach the underlying writer, to flush streamed responses.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"regexp"
 	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/julienschmidt/httprouter"
 )
 
 func TestRateLimit(t *testing.T) {
@@ -58,3 +66,148 @@ func TestRateLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	generatedRX := regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	tests := []struct {
+		name      string
+		header    string
+		want      string
+		generated bool
+	}{
+		{name: "accepted", header: "abc-123.def_456", want: "abc-123.def_456"},
+		{name: "missing", generated: true},
+		{name: "invalid", header: "abc 123\n", generated: true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got string
+
+			handler := app.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = app.contextGetRequestID(r)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				r.Header.Set("X-Request-ID", test.header)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if test.generated && !generatedRX.MatchString(got) || !test.generated && got != test.want {
+				t.Errorf("got request ID %q", got)
+			}
+
+			if header := w.Header().Get("X-Request-ID"); header != got {
+				t.Errorf("got X-Request-ID %q want %q", header, got)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	app := newTestApplication(t)
+	app.logger = slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+
+	router := patternRouter{httprouter.New()}
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews/:review_id", func(w http.ResponseWriter, r *http.Request) {
+		r = app.contextSetUser(r, &data.User{ID: 7})
+		app.movieNotFoundResponse(w, r)
+	})
+
+	handler := app.requestID(app.logAccess(router))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/12/reviews/12", nil)
+	r.Header.Set("X-Request-ID", "request-1")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	type line struct {
+		Msg       string `json:"msg"`
+		RequestID string `json:"request_id"`
+		Method    string `json:"method"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+		Bytes     int    `json:"bytes"`
+		UserID    int64  `json:"user_id"`
+	}
+
+	var got line
+
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := line{"request", "request-1", http.MethodGet, "/v1/movies/:id/reviews/:review_id", http.StatusNotFound, w.Body.Len(), 7}
+
+	if got != want {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+
+	var body struct {
+		RequestID string `json:"requestId"`
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.RequestID != "request-1" {
+		t.Errorf("got body %s", w.Body.String())
+	}
+}
+
+func TestAccessLogRoute(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/v1/genres/v1", "/v1/genres/:id"},
+		{"/v1/movies/1/reviews/1", "/v1/movies/:id/reviews/:review_id"},
+		{"/v1/movies/trash", "/v1/movies/trash"},
+		{"/v1/movies/12", "/v1/movies/:id"},
+		{"/v1/unknown", ""},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			app := newTestApplication(t)
+			app.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			app.routes().ServeHTTP(httptest.NewRecorder(), r)
+
+			var got struct {
+				Route string `json:"route"`
+			}
+
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Route != test.want {
+				t.Errorf("got route %q want %q", got.Route, test.want)
+			}
+		})
+	}
+}
@@ -4,40 +4,74 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 
 	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/jsonpatch"
 	"github.com/Crocmagnon/greenlight/internal/validator"
 )
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title       string            `json:"title"`
+		Year        int32             `json:"year"`
+		Runtime     data.Runtime      `json:"runtime"`
+		Genres      []string          `json:"genres"`
+		Releases    []data.Release    `json:"releases"`
+		ExternalIDs *data.ExternalIDs `json:"externalIds"`
 	}
 
-	err := app.readJSON(w, r, &input)
+	err := app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:       input.Title,
+		Year:        input.Year,
+		Runtime:     input.Runtime,
+		Genres:      input.Genres,
+		Releases:    input.Releases,
+		ExternalIDs: input.ExternalIDs,
+	}
+
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
+
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	force := app.readBool(r.URL.Query(), "force", false, v)
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Insert(movie)
-	if err != nil {
+	if !force {
+		duplicates, findErr := app.models.Movies.FindDuplicates(movie)
+		if findErr != nil {
+			app.serverErrorResponse(w, r, findErr)
+			return
+		}
+
+		if len(duplicates) > 0 {
+			app.duplicateMovieResponse(w, r, duplicates)
+			return
+		}
+	}
+
+	err = app.models.Movies.Insert(movie, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrDuplicateExternalID):
+		app.duplicateExternalIDResponse(w, r)
+		return
+	case err != nil:
 		app.serverErrorResponse(w, r, err)
 		return
 	}
@@ -45,7 +79,7 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -54,32 +88,67 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.movieNotFoundResponse(w, r)
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	validate := validator.New()
+
+	view := app.readMovieView(r.URL.Query(), validate)
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id, view.fields...)
 
 	switch {
 	case errors.Is(err, data.ErrRecordNotFound):
-		app.notFoundResponse(w, r)
+		app.movieNotFoundResponse(w, r)
 		return
 	case err != nil:
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err = app.annotateMovies(r, movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err = app.localizeMovies(r, movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	headers := make(http.Header)
+
+	if movie.Locale != "" {
+		headers.Set("Content-Language", movie.Locale)
+	}
+
+	// Embedded resources aren't versioned with the movie.
+	if len(view.include) == 0 {
+		etag := view.etag(app.representationETag(r, movieETag(movie)))
+		if app.notModified(w, r, etag) {
+			return
+		}
+
+		headers.Set("ETag", etag)
+	}
+
+	err = app.writeMovies(w, r, http.StatusOK, envelope{"movie": movie}, "movie", view, headers, movie)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-//nolint:funlen,cyclop
 func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.movieNotFoundResponse(w, r)
 		return
 	}
 
@@ -87,52 +156,120 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	switch {
 	case errors.Is(err, data.ErrRecordNotFound):
-		app.notFoundResponse(w, r)
+		app.movieNotFoundResponse(w, r)
 		return
 	case err != nil:
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	var input struct {
-		Title   *string       `json:"title"`
-		Year    *int32        `json:"year"`
-		Runtime *data.Runtime `json:"runtime"`
-		Genres  []string      `json:"genres"`
+	if !app.preconditionsMet(w, r, movie.Version) {
+		return
 	}
 
-	err = app.readJSON(w, r, &input)
-	if err != nil {
+	// The watchlist annotation is only sent back, it's never part of the precondition.
+	if err = app.annotateMovies(r, movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	switch mediaType(r) {
+	case mediaTypeMergePatch:
+		err = app.readMovieMergePatch(w, r, movie)
+	case mediaTypeJSONPatch:
+		err = app.readMovieJSONPatch(w, r, movie)
+	default:
+		err = app.readMovieUpdate(w, r, movie)
+	}
+
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		app.patchTestFailedResponse(w, r, err)
+		return
+	case err != nil:
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	if input.Year != nil {
-		movie.Year = *input.Year
+	app.saveMovie(w, r, movie)
+}
+
+func (app *application) replaceMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.movieNotFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.movieNotFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.preconditionsMet(w, r, movie.Version) {
+		return
+	}
+
+	// The watchlist annotation is only sent back, it's never part of the precondition.
+	if err = app.annotateMovies(r, movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Title   string       `json:"title"`
+		Year    int32        `json:"year"`
+		Runtime data.Runtime `json:"runtime"`
+		Genres  []string     `json:"genres"`
 	}
 
-	if input.Title != nil {
-		movie.Title = *input.Title
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
+	movie.Title = input.Title
+	movie.Year = input.Year
+	movie.Runtime = input.Runtime
+	movie.Genres = input.Genres
+
+	app.saveMovie(w, r, movie)
+}
+
+// saveMovie validates and updates a movie modified by the request,
+// then writes the response.
+func (app *application) saveMovie(w http.ResponseWriter, r *http.Request, movie *data.Movie) {
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
-	if input.Genres != nil {
-		movie.Genres = input.Genres
+	if err = app.embedUpcomingReleases(movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	v := validator.New()
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.Update(movie, app.contextGetUser(r).ID)
 
 	switch {
+	case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+		app.preconditionFailedResponse(w, r)
+		return
 	case errors.Is(err, data.ErrEditConflict):
 		app.editConflictResponse(w, r)
 		return
@@ -141,7 +278,10 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", app.representationETag(r, movieETag(movie)))
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -150,22 +290,49 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
-		app.notFoundResponse(w, r)
+		app.movieNotFoundResponse(w, r)
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	// The version is only checked when the request is conditional.
+	var version int32
+
+	if r.Header.Get("If-Match") != "" || app.config.requireIfMatch {
+		var movie *data.Movie
+
+		movie, err = app.models.Movies.Get(id)
+
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.movieNotFoundResponse(w, r)
+			return
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !app.preconditionsMet(w, r, movie.Version) {
+			return
+		}
+
+		version = movie.Version
+	}
+
+	err = app.models.Movies.Delete(id, version)
 
 	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		app.preconditionFailedResponse(w, r)
+		return
 	case errors.Is(err, data.ErrRecordNotFound):
-		app.notFoundResponse(w, r)
+		app.movieNotFoundResponse(w, r)
 		return
 	case err != nil:
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -173,9 +340,9 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieCriteria
 		data.Filters
+		movieView
 	}
 
 	validate := validator.New()
@@ -187,26 +354,225 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		defaultPage     = 1
 	)
 
-	input.Title = app.readString(urlValues, "title", "")
-	input.Genres = app.readCSV(urlValues, "genres", []string{})
+	criteria, err := app.readMovieCriteria(urlValues, validate)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	input.MovieCriteria = criteria
+	input.movieView = app.readMovieView(urlValues, validate)
 	input.Filters.Page = app.readInt(urlValues, "page", defaultPage, validate)
 	input.Filters.PageSize = app.readInt(urlValues, "page_size", defaultPageSize, validate)
 	input.Filters.Sort = app.readString(urlValues, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.SortSafelist = movieSortSafelist()
 
 	if data.ValidateFilters(validate, input.Filters); !validate.Valid() {
 		app.failedValidationResponse(w, r, validate.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieCriteria, input.Filters, input.fields...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err = app.annotateMovies(r, movies...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err = app.localizeMovies(r, movies...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	headers := make(http.Header)
+
+	// Embedded resources aren't versioned with the movies.
+	if len(input.include) == 0 {
+		etag := input.etag(app.representationETag(r, moviesETag(movies, metadata)))
+		if app.notModified(w, r, etag) {
+			return
+		}
+
+		headers.Set("ETag", etag)
+	}
+
+	err = app.writeMovies(w, r, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, "movies",
+		input.movieView, headers, movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listDeletedMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	validate := validator.New()
+
+	urlValues := r.URL.Query()
+
+	const (
+		defaultPageSize = 20
+		defaultPage     = 1
+	)
+
+	filters := data.Filters{
+		Page:         app.readInt(urlValues, "page", defaultPage, validate),
+		PageSize:     app.readInt(urlValues, "page_size", defaultPageSize, validate),
+		Sort:         app.readString(urlValues, "sort", "-deleted_at"),
+		SortSafelist: []string{"id", "title", "deleted_at", "-id", "-title", "-deleted_at"},
+	}
+
+	if data.ValidateFilters(validate, filters); !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAllDeleted(filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.movieNotFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.GetDeleted(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.movieNotFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.preconditionsMet(w, r, movie.Version) {
+		return
+	}
+
+	movie, err = app.models.Movies.Restore(id, movie.Version, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+		app.preconditionFailedResponse(w, r)
+		return
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", app.representationETag(r, movieETag(movie)))
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// movieSortSafelist returns the values accepted for the sort parameter of movie lists.
+func movieSortSafelist() []string {
+	return []string{"id", "title", "year", "runtime", "rating", "-id", "-title", "-year", "-runtime", "-rating"}
+}
+
+// readMovieCriteria reads the criteria used to search movies from the query string.
+// Genres are searched by their canonical names, so aliases are accepted.
+func (app *application) readMovieCriteria(qs url.Values, validate *validator.Validator) (data.MovieCriteria, error) {
+	criteria := data.MovieCriteria{
+		Title:          app.readString(qs, "title", ""),
+		Genres:         app.readCSV(qs, "genres", []string{}),
+		Director:       app.readString(qs, "director", ""),
+		Actor:          app.readString(qs, "actor", ""),
+		ReleasedIn:     app.readString(qs, "released_in", ""),
+		ReleasedAfter:  app.readDate(qs, "released_after", validate),
+		ReleasedBefore: app.readDate(qs, "released_before", validate),
+		ExternalIDs: data.ExternalIDs{
+			IMDb:     app.readString(qs, "imdb_id", ""),
+			TMDB:     app.readString(qs, "tmdb_id", ""),
+			Wikidata: app.readString(qs, "wikidata_id", ""),
+		},
+	}
+
+	validate.CheckError(criteria.ReleasedIn == "" || validator.Matches(criteria.ReleasedIn, data.CountryRX),
+		"released_in", data.InvalidCountryCode())
+
+	if len(criteria.Genres) == 0 {
+		return criteria, nil
+	}
+
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		return criteria, fmt.Errorf("getting genres: %w", err)
+	}
+
+	criteria.Genres = genres.Normalize(criteria.Genres)
+
+	return criteria, nil
+}
+
+// readDate reads a date formatted as YYYY-MM-DD. The zero date is returned when the key is absent.
+func (*application) readDate(qs url.Values, key string, validate *validator.Validator) data.Date {
+	s := qs.Get(key)
+
+	if s == "" {
+		return data.Date{}
+	}
+
+	date, err := data.ParseDate(s)
+	if err != nil {
+		validate.Add(key, data.InvalidDate())
+		return data.Date{}
+	}
+
+	return date
+}
+
+// validateMovieIncludes checks the related resources requested with the include parameter.
+func validateMovieIncludes(validate *validator.Validator, include []string) {
+	includes := []string{"credits", "releases", "external_ids"}
+
+	for _, value := range include {
+		validate.CheckError(validator.PermittedValue(value, includes...), "include", validator.OneOf(includes...))
+	}
+}
+
+// embedIncludes embeds the related resources requested with the include parameter in the movies.
+func (app *application) embedIncludes(include []string, movies ...*data.Movie) error {
+	if slices.Contains(include, "credits") {
+		if err := app.embedCredits(movies...); err != nil {
+			return err
+		}
+	}
+
+	if slices.Contains(include, "releases") {
+		if err := app.embedReleases(movies...); err != nil {
+			return err
+		}
+	}
+
+	if slices.Contains(include, "external_ids") {
+		if err := app.embedExternalIDs(movies...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/jsonpatch"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// mediaType returns the media type of the request body, without parameters.
+// It returns an empty string when the Content-Type header is missing or malformed.
+func mediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
+}
+
+// movieDocument holds the fields of a movie that clients can edit.
+// It's the document patches are applied to.
+type movieDocument struct {
+	Title   string       `json:"title"`
+	Year    int32        `json:"year"`
+	Runtime data.Runtime `json:"runtime"`
+	Genres  []string     `json:"genres"`
+}
+
+// movieUpdate holds the fields of a plain JSON update.
+// Only the fields present in the body are updated.
+type movieUpdate struct {
+	Title   *string       `json:"title"`
+	Year    *int32        `json:"year"`
+	Runtime *data.Runtime `json:"runtime"`
+	Genres  []string      `json:"genres"`
+}
+
+func (input movieUpdate) apply(movie *data.Movie) {
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+
+	if input.Genres != nil {
+		movie.Genres = input.Genres
+	}
+}
+
+// readMovieUpdate applies a plain body, such as JSON, to the movie.
+func (app *application) readMovieUpdate(w http.ResponseWriter, r *http.Request, movie *data.Movie) error {
+	var input movieUpdate
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		return err
+	}
+
+	input.apply(movie)
+
+	return nil
+}
+
+// readMovieMergePatch applies an RFC 7396 JSON Merge Patch body to the movie.
+func (app *application) readMovieMergePatch(w http.ResponseWriter, r *http.Request, movie *data.Movie) error {
+	var patch any
+
+	err := app.readJSON(w, r, &patch)
+	if err != nil {
+		return err
+	}
+
+	return patchMovie(movie, func(doc any) (any, error) {
+		return jsonpatch.MergePatch(doc, patch), nil
+	})
+}
+
+// readMovieJSONPatch applies an RFC 6902 JSON Patch body to the movie.
+func (app *application) readMovieJSONPatch(w http.ResponseWriter, r *http.Request, movie *data.Movie) error {
+	var patch []jsonpatch.Operation
+
+	err := app.readJSON(w, r, &patch)
+	if err != nil {
+		return err
+	}
+
+	return patchMovie(movie, func(doc any) (any, error) {
+		return jsonpatch.Apply(doc, patch) //nolint:wrapcheck
+	})
+}
+
+// patchMovie converts the movie to a generic JSON document, applies the patch to it
+// and copies the result back to the movie.
+func patchMovie(movie *data.Movie, patch func(doc any) (any, error)) error {
+	original, err := json.Marshal(movieDocument{
+		Title:   movie.Title,
+		Year:    movie.Year,
+		Runtime: movie.Runtime,
+		Genres:  movie.Genres,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding movie: %w", err)
+	}
+
+	var doc any
+
+	if err = json.Unmarshal(original, &doc); err != nil {
+		return fmt.Errorf("decoding movie: %w", err)
+	}
+
+	doc, err = patch(doc)
+	if err != nil {
+		return fmt.Errorf("applying patch: %w", err)
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encoding patched movie: %w", err)
+	}
+
+	var result movieDocument
+
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+
+	if err = dec.Decode(&result); err != nil {
+		return wrapError(err)
+	}
+
+	movie.Title = result.Title
+	movie.Year = result.Year
+	movie.Runtime = result.Runtime
+	movie.Genres = result.Genres
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/jsonpatch"
+)
+
+func TestPatchMovie(t *testing.T) {
+	t.Parallel()
+
+	newMovie := func() *data.Movie {
+		return &data.Movie{ID: 1, Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation", "adventure"}}
+	}
+
+	t.Run("merge patch", func(t *testing.T) {
+		t.Parallel()
+
+		movie := newMovie()
+		patch := map[string]any{"title": "Moana 2", "genres": nil}
+
+		err := patchMovie(movie, func(doc any) (any, error) {
+			return jsonpatch.MergePatch(doc, patch), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if movie.Title != "Moana 2" || movie.Genres != nil || movie.Year != 2016 {
+			t.Errorf("got %+v", movie)
+		}
+	})
+
+	t.Run("json patch on genres", func(t *testing.T) {
+		t.Parallel()
+
+		movie := newMovie()
+
+		var patch []jsonpatch.Operation
+
+		err := json.Unmarshal([]byte(`[
+			{"op": "test", "path": "/genres/0", "value": "animation"},
+			{"op": "remove", "path": "/genres/0"},
+			{"op": "add", "path": "/genres/-", "value": "musical"},
+			{"op": "replace", "path": "/runtime", "value": "103 mins"}
+		]`), &patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = patchMovie(movie, func(doc any) (any, error) {
+			return jsonpatch.Apply(doc, patch)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []string{"adventure", "musical"}; !slices.Equal(movie.Genres, want) {
+			t.Errorf("got genres %v want %v", movie.Genres, want)
+		}
+
+		if movie.Runtime != 103 {
+			t.Errorf("got runtime %d want 103", movie.Runtime)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		t.Parallel()
+
+		err := patchMovie(newMovie(), func(doc any) (any, error) {
+			return jsonpatch.MergePatch(doc, map[string]any{"director": "Ron Clements"}), nil
+		})
+		if !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("got error %v want %v", err, ErrUnknownKey)
+		}
+	})
+}
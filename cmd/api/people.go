@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func (app *application) listPeopleHandler(w http.ResponseWriter, r *http.Request) {
+	validate := validator.New()
+
+	urlValues := r.URL.Query()
+
+	const (
+		defaultPageSize = 20
+		defaultPage     = 1
+	)
+
+	name := app.readString(urlValues, "name", "")
+	filters := data.Filters{
+		Page:         app.readInt(urlValues, "page", defaultPage, validate),
+		PageSize:     app.readInt(urlValues, "page_size", defaultPageSize, validate),
+		Sort:         app.readString(urlValues, "sort", "name"),
+		SortSafelist: []string{"id", "name", "-id", "-name"},
+	}
+
+	if data.ValidateFilters(validate, filters); !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	people, metadata, err := app.models.People.GetAll(name, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"people": people, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createPersonHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string `json:"name"`
+		BirthYear *int32 `json:"birthYear"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	person := &data.Person{Name: input.Name, BirthYear: input.BirthYear}
+	v := validator.New()
+
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.People.Insert(person)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/people/%d", person.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"person": person}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	person, err := app.models.People.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"person": person}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//nolint:cyclop
+func (app *application) updatePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	person, err := app.models.People.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Name      *string `json:"name"`
+		BirthYear *int32  `json:"birthYear"`
+	}
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		person.Name = *input.Name
+	}
+
+	if input.BirthYear != nil {
+		person.BirthYear = input.BirthYear
+	}
+
+	v := validator.New()
+
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.People.Update(person)
+
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"person": person}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deletePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.People.Delete(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "person successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listPersonCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	person, err := app.models.People.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	credits, err := app.models.People.GetCreditsForPerson(person.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"person": person, "credits": credits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listMovieCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err = app.embedCredits(movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"credits": movie.Credits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//nolint:cyclop
+func (app *application) replaceMovieCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Credits []data.Credit `json:"credits"`
+	}
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateCredits(v, input.Credits); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.People.SetCredits(movie.ID, input.Credits)
+
+	switch {
+	case errors.Is(err, data.ErrUnknownPerson):
+		v.Add("credits", validator.FieldError{Code: data.CodeUnknownPerson, Message: "must only reference existing people"})
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err = app.embedCredits(movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"credits": movie.Credits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// embedCredits sets the credits of the given movies.
+func (app *application) embedCredits(movies ...*data.Movie) error {
+	ids := make([]int64, 0, len(movies))
+
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	credits, err := app.models.People.GetCreditsForMovies(ids...)
+	if err != nil {
+		return fmt.Errorf("getting credits: %w", err)
+	}
+
+	for _, movie := range movies {
+		movie.Credits = credits[movie.ID]
+		if movie.Credits == nil {
+			movie.Credits = []data.Credit{}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestValidateCredits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		credits    []data.Credit
+		wantErrors []string
+	}{
+		{"valid", []data.Credit{
+			{PersonID: 1, Role: data.RoleDirector},
+			{PersonID: 1, Role: data.RoleWriter},
+			{PersonID: 2, Role: data.RoleActor, Character: "Moana", BillingOrder: 1},
+		}, nil},
+		{"empty", nil, nil},
+		{"invalid fields", []data.Credit{
+			{Role: "producer"},
+			{PersonID: 2, Role: data.RoleDirector, Character: "Maui", BillingOrder: -1},
+		}, []string{"credits[0].personId", "credits[0].role", "credits[1].character", "credits[1].billingOrder"}},
+		{"duplicate", []data.Credit{
+			{PersonID: 1, Role: data.RoleActor},
+			{PersonID: 1, Role: data.RoleActor, Character: "Maui"},
+		}, []string{"credits[1]"}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			validate := validator.New()
+			data.ValidateCredits(validate, test.credits)
+
+			if len(validate.Errors) != len(test.wantErrors) {
+				t.Errorf("got errors %v want keys %v", validate.Errors, test.wantErrors)
+			}
+
+			for _, key := range test.wantErrors {
+				if _, found := validate.Errors[validator.Pointer(key)]; !found {
+					t.Errorf("missing error for %q in %v", key, validate.Errors)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Registers the GIF decoder.
+	"image/jpeg"
+	_ "image/png" // Registers the PNG decoder.
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+const (
+	minPosterDimension = 100
+	thumbnailWidth     = 300
+	thumbnailQuality   = 85
+	posterJobTimeout   = time.Minute
+)
+
+// posterExtensions maps the accepted poster content types to the extension of their key.
+var posterExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+//nolint:funlen,cyclop
+func (app *application) uploadMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	// Posters are larger than JSON bodies and have their own limits,
+	// uploads are allowed to take longer than the server timeouts.
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.posters.maxBytes)
+
+	deadline := time.Now().Add(app.config.posters.timeout)
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(deadline)
+	_ = controller.SetWriteDeadline(deadline)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	var content []byte
+
+	for {
+		part, partErr := reader.NextPart()
+		if errors.Is(partErr, io.EOF) {
+			break
+		}
+
+		if partErr != nil {
+			app.badRequestResponse(w, r, posterReadError(partErr, app.config.posters.maxBytes))
+			return
+		}
+
+		if part.FormName() != "poster" {
+			continue
+		}
+
+		content, err = io.ReadAll(part)
+		if err != nil {
+			app.badRequestResponse(w, r, posterReadError(err, app.config.posters.maxBytes))
+			return
+		}
+
+		break
+	}
+
+	v := validator.New()
+
+	contentType, config := validatePoster(v, content, app.config.posters.maxDimension)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	key, err := newPosterKey(movie.ID, posterExtensions[contentType])
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.blobs.Put(r.Context(), key, bytes.NewReader(content), int64(len(content)), contentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	poster := &data.Poster{
+		MovieID:     movie.ID,
+		Key:         key,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		Width:       int32(config.Width),
+		Height:      int32(config.Height),
+	}
+
+	previous, err := app.models.Posters.Set(poster)
+	if err != nil {
+		app.deletePosterBlobs(&data.Poster{Key: key})
+		app.serverErrorResponse(w, r, err)
+
+		return
+	}
+
+	app.background(func() {
+		app.deletePosterBlobs(previous)
+		app.generateThumbnail(movie.ID, key, content)
+	})
+
+	setPosterURLs(poster)
+
+	headers := make(http.Header)
+	headers.Set("Location", poster.URL)
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"poster": poster}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//nolint:cyclop
+func (app *application) showMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	validate := validator.New()
+
+	size := app.readString(r.URL.Query(), "size", "original")
+	validate.CheckError(validator.PermittedValue(size, "original", "thumbnail"), "size", validator.OneOf("original", "thumbnail"))
+
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	poster, err := app.models.Posters.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The original is served until the thumbnail has been generated.
+	key := poster.Key
+	if size == "thumbnail" && poster.ThumbnailKey != nil {
+		key = *poster.ThumbnailKey
+	}
+
+	// Keys are never reused, so they identify the image.
+	etag := strconv.Quote(key)
+	if app.notModified(w, r, etag) {
+		return
+	}
+
+	object, err := app.blobs.Get(r.Context(), key)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	defer object.Body.Close()
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", object.ContentType)
+
+	if object.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = io.Copy(w, object.Body); err != nil {
+		app.logError(r, err)
+	}
+}
+
+func (app *application) deleteMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	poster, err := app.models.Posters.Delete(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		app.deletePosterBlobs(poster)
+	})
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "poster successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// validatePoster checks that content is a poster image of acceptable dimensions,
+// and returns its sniffed content type and dimensions.
+func validatePoster(v *validator.Validator, content []byte, maxDimension int) (string, image.Config) {
+	if len(content) == 0 {
+		v.Add("poster", validator.Required())
+		return "", image.Config{}
+	}
+
+	contentType := http.DetectContentType(content)
+
+	if _, found := posterExtensions[contentType]; !found {
+		v.Add("poster", validator.FieldError{Code: data.CodeImageFormat, Message: "must be a JPEG, PNG or GIF image"})
+		return "", image.Config{}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		v.Add("poster", validator.FieldError{Code: data.CodeImageInvalid, Message: "must be a valid image"})
+		return "", image.Config{}
+	}
+
+	v.CheckError(config.Width >= minPosterDimension && config.Height >= minPosterDimension, "poster",
+		validator.FieldError{
+			Code:    data.CodeImageTooSmall,
+			Message: fmt.Sprintf("must be at least %d pixels wide and high", minPosterDimension),
+			Params:  map[string]any{"min": minPosterDimension},
+		})
+	v.CheckError(config.Width <= maxDimension && config.Height <= maxDimension, "poster",
+		validator.FieldError{
+			Code:    data.CodeImageTooLarge,
+			Message: fmt.Sprintf("must not be more than %d pixels wide or high", maxDimension),
+			Params:  map[string]any{"max": maxDimension},
+		})
+
+	return contentType, config
+}
+
+func posterReadError(err error, maxBytes int64) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return fmt.Errorf("%w, max size %d bytes", ErrBodyTooLarge, maxBytes)
+	}
+
+	return fmt.Errorf("reading poster: %w", err)
+}
+
+// newPosterKey returns a new blob key for a poster of the movie.
+// Keys are random so that a replaced poster never shares its key with the new one.
+func newPosterKey(movieID int64, extension string) (string, error) {
+	name := make([]byte, 8) //nolint:gomnd
+
+	if _, err := rand.Read(name); err != nil {
+		return "", fmt.Errorf("generating poster name: %w", err)
+	}
+
+	return fmt.Sprintf("posters/%d/%s%s", movieID, hex.EncodeToString(name), extension), nil
+}
+
+func setPosterURLs(poster *data.Poster) {
+	poster.URL = fmt.Sprintf("/v1/movies/%d/poster", poster.MovieID)
+
+	if poster.ThumbnailKey != nil {
+		poster.ThumbnailURL = poster.URL + "?size=thumbnail"
+	}
+}
+
+// generateThumbnail stores a JPEG thumbnail of the poster stored under posterKey, and records it.
+// It's meant to run in the background.
+func (app *application) generateThumbnail(movieID int64, posterKey string, content []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), posterJobTimeout)
+	defer cancel()
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		app.logger.Error("decoding poster", "movie_id", movieID, "error", err.Error())
+		return
+	}
+
+	var thumbnail bytes.Buffer
+
+	err = jpeg.Encode(&thumbnail, scaleToWidth(img, thumbnailWidth), &jpeg.Options{Quality: thumbnailQuality})
+	if err != nil {
+		app.logger.Error("encoding thumbnail", "movie_id", movieID, "error", err.Error())
+		return
+	}
+
+	key, err := newPosterKey(movieID, ".jpg")
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	err = app.blobs.Put(ctx, key, &thumbnail, int64(thumbnail.Len()), "image/jpeg")
+	if err != nil {
+		app.logger.Error("storing thumbnail", "movie_id", movieID, "error", err.Error())
+		return
+	}
+
+	err = app.models.Posters.SetThumbnail(movieID, posterKey, key)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		// The poster was replaced or deleted while the thumbnail was generated.
+		app.deletePosterBlobs(&data.Poster{Key: key})
+	case err != nil:
+		app.logger.Error("recording thumbnail", "movie_id", movieID, "error", err.Error())
+	}
+}
+
+// deletePosterBlobs deletes the images of a poster that's no longer referenced.
+// Failures are logged, leaving orphan blobs behind.
+func (app *application) deletePosterBlobs(poster *data.Poster) {
+	if poster == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), posterJobTimeout)
+	defer cancel()
+
+	keys := []string{poster.Key}
+	if poster.ThumbnailKey != nil {
+		keys = append(keys, *poster.ThumbnailKey)
+	}
+
+	for _, key := range keys {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			app.logger.Error("deleting poster blob", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// scaleToWidth returns a copy of img scaled down to the given width, keeping its aspect ratio.
+// Each pixel is the average of the source pixels it covers. Images narrower
+// than width are returned unchanged.
+func scaleToWidth(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img
+	}
+
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			scaled.Set(x, y, averageColor(img, image.Rect(x0, y0, x1, y1)))
+		}
+	}
+
+	return scaled
+}
+
+func averageColor(img image.Image, area image.Rectangle) color.Color {
+	var red, green, blue, alpha, count uint64
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			red += uint64(r)
+			green += uint64(g)
+			blue += uint64(b)
+			alpha += uint64(a)
+			count++
+		}
+	}
+
+	return color.RGBA64{
+		R: uint16(red / count),
+		G: uint16(green / count),
+		B: uint16(blue / count),
+		A: uint16(alpha / count),
+	}
+}
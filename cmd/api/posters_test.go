@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestValidatePoster(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		content   []byte
+		wantType  string
+		wantValid bool
+	}{
+		{"valid", encodePNG(t, 200, 300), "image/png", true},
+		{"empty", nil, "", false},
+		{"not an image", []byte("%PDF-1.7 not a poster"), "", false},
+		{"truncated", encodePNG(t, 200, 300)[:20], "", false},
+		{"too small", encodePNG(t, 50, 300), "image/png", false},
+		{"too large", encodePNG(t, 200, 1200), "image/png", false},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			validate := validator.New()
+
+			contentType, _ := validatePoster(validate, test.content, 1000)
+			if contentType != test.wantType || validate.Valid() != test.wantValid {
+				t.Errorf("got %q valid=%t (%v), want %q valid=%t",
+					contentType, validate.Valid(), validate.Errors, test.wantType, test.wantValid)
+			}
+		})
+	}
+}
+
+func TestScaleToWidth(t *testing.T) {
+	t.Parallel()
+
+	src := image.NewRGBA(image.Rect(0, 0, 400, 600))
+
+	// Left half black, right half white.
+	for y := 0; y < 600; y++ {
+		for x := 200; x < 400; x++ {
+			src.Set(x, y, color.White)
+		}
+	}
+
+	scaled := scaleToWidth(src, 100)
+
+	if got := scaled.Bounds(); got.Dx() != 100 || got.Dy() != 150 {
+		t.Fatalf("got bounds %v want 100x150", got)
+	}
+
+	if r, _, _, _ := scaled.At(10, 10).RGBA(); r != 0 {
+		t.Errorf("got red %d on the left, want 0", r)
+	}
+
+	if r, _, _, _ := scaled.At(90, 10).RGBA(); r != 0xffff {
+		t.Errorf("got red %d on the right, want %d", r, 0xffff)
+	}
+
+	if small := scaleToWidth(src, 1000); small != image.Image(src) {
+		t.Errorf("narrow images must not be scaled up")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+// defaultRecommendations is the number of movies returned when the limit parameter is absent.
+const defaultRecommendations = 10
+
+func (app *application) listSimilarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	validate := validator.New()
+
+	limit := app.readLimit(r.URL.Query(), data.MaxSimilarMovies, validate)
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	movies, err := app.models.Recommendations.Similar(movie.ID, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeScoredMovies(w, r, movies)
+}
+
+func (app *application) listUserRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	validate := validator.New()
+
+	limit := app.readLimit(r.URL.Query(), data.MaxRecommendations, validate)
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	movies, err := app.models.Recommendations.ForUser(app.contextGetUser(r).ID, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeScoredMovies(w, r, movies)
+}
+
+// readLimit reads the number of movies requested with the limit parameter, between 1 and maxLimit.
+func (app *application) readLimit(qs url.Values, maxLimit int, validate *validator.Validator) int {
+	limit := app.readInt(qs, "limit", defaultRecommendations, validate)
+
+	validate.CheckError(limit >= 1, "limit", validator.GreaterThan(0))
+	validate.CheckError(limit <= maxLimit, "limit", validator.Max(maxLimit))
+
+	return limit
+}
+
+// writeScoredMovies sends the movies annotated and localized for the user, like movie listings.
+func (app *application) writeScoredMovies(w http.ResponseWriter, r *http.Request, scored []*data.ScoredMovie) {
+	movies := make([]*data.Movie, 0, len(scored))
+
+	for _, movie := range scored {
+		movies = append(movies, &movie.Movie)
+	}
+
+	if err := app.annotateMovies(r, movies...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.localizeMovies(r, movies...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"movies": scored}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestReadLimit(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		query     string
+		want      int
+		wantError bool
+	}{
+		{"", defaultRecommendations, false},
+		{"limit=1", 1, false},
+		{"limit=20", 20, false},
+		{"limit=0", 0, true},
+		{"limit=21", 21, true},
+		{"limit=ten", defaultRecommendations, true},
+	}
+
+	for _, test := range tests {
+		qs, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		validate := validator.New()
+
+		if got := app.readLimit(qs, 20, validate); got != test.want {
+			t.Errorf("%q: got limit %d want %d", test.query, got, test.want)
+		}
+
+		if _, found := validate.Errors["/limit"]; found != test.wantError {
+			t.Errorf("%q: got errors %v want limit error: %v", test.query, validate.Errors, test.wantError)
+		}
+	}
+}
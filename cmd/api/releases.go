@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func (app *application) listMovieReleasesHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.embedReleases(movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"releases": movie.Releases}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) replaceMovieReleasesHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.preconditionsMet(w, r, movie.Version) {
+		return
+	}
+
+	var input struct {
+		Releases []data.Release `json:"releases"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Releases == nil {
+		input.Releases = []data.Release{}
+	}
+
+	v := validator.New()
+
+	if data.ValidateReleases(v, input.Releases); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The year of the movie is checked against the releases once the movie is locked.
+	err = app.models.Releases.Set(movie, input.Releases, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.movieNotFoundResponse(w, r)
+		return
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case errors.Is(err, data.ErrYearInFuture):
+		v.Add("year", data.YearInFuture())
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err = app.embedReleases(movie); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"releases": movie.Releases}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// embedReleases sets the releases of the given movies.
+func (app *application) embedReleases(movies ...*data.Movie) error {
+	ids := make([]int64, 0, len(movies))
+
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	releases, err := app.models.Releases.GetForMovies(ids...)
+	if err != nil {
+		return fmt.Errorf("getting releases: %w", err)
+	}
+
+	for _, movie := range movies {
+		movie.Releases = releases[movie.ID]
+		if movie.Releases == nil {
+			movie.Releases = []data.Release{}
+		}
+	}
+
+	return nil
+}
+
+// embedUpcomingReleases sets the releases of a movie dated in the future before
+// it's validated, as they determine whether its year is acceptable.
+func (app *application) embedUpcomingReleases(movie *data.Movie) error {
+	if movie.Year <= int32(time.Now().Year()) {
+		return nil
+	}
+
+	return app.embedReleases(movie)
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+const reviewStatusAll = "all"
+
+func (app *application) showMovieRatingHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	summary := envelope{"average": movie.Rating, "count": movie.RatingCount}
+
+	rating, err := app.models.Ratings.Get(movie.ID, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	default:
+		summary["userRating"] = rating
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"rating": summary}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) setMovieRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Rating int32 `json:"rating"`
+	}
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rating := &data.Rating{MovieID: id, UserID: app.contextGetUser(r).ID, Rating: input.Rating}
+	v := validator.New()
+
+	if data.ValidateRating(v, rating); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Ratings.Set(rating)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"rating": rating}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Ratings.Delete(id, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "rating successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+//nolint:cyclop
+func (app *application) listMovieReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	validate := validator.New()
+
+	urlValues := r.URL.Query()
+
+	const (
+		defaultPageSize = 20
+		defaultPage     = 1
+	)
+
+	status := app.readString(urlValues, "status", data.ReviewPublished)
+	filters := data.Filters{
+		Page:         app.readInt(urlValues, "page", defaultPage, validate),
+		PageSize:     app.readInt(urlValues, "page_size", defaultPageSize, validate),
+		Sort:         app.readString(urlValues, "sort", "-created_at"),
+		SortSafelist: []string{"created_at", "updated_at", "-created_at", "-updated_at"},
+	}
+
+	validate.CheckError(validator.PermittedValue(status, data.ReviewPublished, data.ReviewHidden, reviewStatusAll),
+		"status", validator.OneOf(data.ReviewPublished, data.ReviewHidden, reviewStatusAll))
+
+	if data.ValidateFilters(validate, filters); !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	moderator := false
+
+	if status != data.ReviewPublished {
+		var err error
+
+		if moderator, err = app.userHasPermission(r, "reviews:moderate"); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	statusFilter, permitted := reviewStatusFilter(status, moderator)
+	if !permitted {
+		app.notPermitted(w, r)
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllForMovie(movie.ID, statusFilter, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createMovieReviewHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	review := &data.Review{
+		MovieID: movie.ID,
+		UserID:  app.contextGetUser(r).ID,
+		Body:    input.Body,
+		Status:  data.ReviewPublished,
+	}
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Insert(review)
+
+	switch {
+	case errors.Is(err, data.ErrDuplicateReview):
+		v.Add("body", validator.FieldError{Code: data.CodeAlreadyReviewed, Message: "you already reviewed this movie"})
+		app.failedValidationResponse(w, r, v.Errors)
+
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/reviews/%d", movie.ID, review.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"review": review}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMovieReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.getReviewOrRespond(w, r, false)
+	if !ok {
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateMovieReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.getReviewOrRespond(w, r, false)
+	if !ok {
+		return
+	}
+
+	if review.UserID != app.contextGetUser(r).ID {
+		app.notPermitted(w, r)
+		return
+	}
+
+	var input struct {
+		Body *string `json:"body"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Body != nil {
+		review.Body = *input.Body
+	}
+
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Update(review)
+
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) moderateMovieReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.getReviewOrRespond(w, r, false)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	review.Status = input.Status
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Moderate(review, app.contextGetUser(r).ID)
+
+	switch {
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.getReviewOrRespond(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := app.models.Reviews.Delete(review.ID)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "review successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listMovieReviewRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.getReviewOrRespond(w, r, true)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.models.Reviews.GetRevisions(review.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"revisions": revisions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getMovieOrRespond returns the movie identified by the route.
+// When it returns false, an error response has been sent.
+func (app *application) getMovieOrRespond(w http.ResponseWriter, r *http.Request) (*data.Movie, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.movieNotFoundResponse(w, r)
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.movieNotFoundResponse(w, r)
+		return nil, false
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	return movie, true
+}
+
+// getReviewOrRespond returns the review identified by the route.
+// Hidden reviews are only returned to their author and moderators, and when
+// ownerOrModerator is set, so are published reviews.
+// When it returns false, an error response has been sent.
+func (app *application) getReviewOrRespond(
+	w http.ResponseWriter, r *http.Request, ownerOrModerator bool,
+) (*data.Review, bool) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	id, err := app.readNamedIDParam(r, "review_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	review, err := app.models.Reviews.Get(movieID, id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return nil, false
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	status, err := reviewAccess(review, app.contextGetUser(r).ID, ownerOrModerator, func() (bool, error) {
+		return app.userHasPermission(r, "reviews:moderate")
+	})
+
+	switch {
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	case status == http.StatusNotFound:
+		app.notFoundResponse(w, r)
+		return nil, false
+	case status == http.StatusForbidden:
+		app.notPermitted(w, r)
+		return nil, false
+	}
+
+	return review, true
+}
+
+// reviewStatusFilter returns the status reviews are listed with, empty for all of them,
+// and whether the user may list them: only moderators may list hidden reviews.
+func reviewStatusFilter(status string, moderator bool) (string, bool) {
+	switch {
+	case status == data.ReviewPublished:
+		return status, true
+	case !moderator:
+		return "", false
+	case status == reviewStatusAll:
+		return "", true
+	default:
+		return status, true
+	}
+}
+
+// reviewAccess returns the status of the response to the user accessing the review,
+// http.StatusOK when allowed. Hidden reviews are only visible to their author and moderators,
+// and so are published reviews when ownerOrModerator is set. Others get 404 for hidden reviews,
+// whose existence they mustn't learn, and 403 otherwise.
+// isModerator is only called when the outcome depends on it.
+func reviewAccess(
+	review *data.Review, userID int64, ownerOrModerator bool, isModerator func() (bool, error),
+) (int, error) {
+	if review.UserID == userID || (review.Status == data.ReviewPublished && !ownerOrModerator) {
+		return http.StatusOK, nil
+	}
+
+	moderator, err := isModerator()
+
+	switch {
+	case err != nil:
+		return 0, err
+	case moderator:
+		return http.StatusOK, nil
+	case review.Status == data.ReviewHidden:
+		return http.StatusNotFound, nil
+	default:
+		return http.StatusForbidden, nil
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+)
+
+func TestReviewStatusFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status        string
+		moderator     bool
+		want          string
+		wantPermitted bool
+	}{
+		{data.ReviewPublished, false, data.ReviewPublished, true},
+		{data.ReviewPublished, true, data.ReviewPublished, true},
+		{data.ReviewHidden, false, "", false},
+		{data.ReviewHidden, true, data.ReviewHidden, true},
+		{reviewStatusAll, false, "", false},
+		{reviewStatusAll, true, "", true},
+	}
+
+	for _, test := range tests {
+		got, permitted := reviewStatusFilter(test.status, test.moderator)
+		if got != test.want || permitted != test.wantPermitted {
+			t.Errorf("%q as moderator %t: got %q, %t want %q, %t",
+				test.status, test.moderator, got, permitted, test.want, test.wantPermitted)
+		}
+	}
+}
+
+func TestReviewAccess(t *testing.T) {
+	t.Parallel()
+
+	const (
+		author = 1
+		other  = 2
+	)
+
+	errPermissions := errors.New("permissions unavailable") //nolint:goerr113
+
+	tests := []struct {
+		name             string
+		status           string
+		userID           int64
+		ownerOrModerator bool
+		moderator        bool
+		moderatorErr     error
+		want             int
+		wantErr          error
+		wantChecked      bool
+	}{
+		{name: "published", status: data.ReviewPublished, userID: other, want: http.StatusOK},
+		{name: "published to anonymous", status: data.ReviewPublished, userID: 0, want: http.StatusOK},
+		{name: "hidden to author", status: data.ReviewHidden, userID: author, want: http.StatusOK},
+		{
+			name: "hidden to moderator", status: data.ReviewHidden, userID: other, moderator: true,
+			want: http.StatusOK, wantChecked: true,
+		},
+		{
+			name: "hidden to other", status: data.ReviewHidden, userID: other,
+			want: http.StatusNotFound, wantChecked: true,
+		},
+		{
+			name: "published owned by author", status: data.ReviewPublished, userID: author, ownerOrModerator: true,
+			want: http.StatusOK,
+		},
+		{
+			name: "published owned by moderator", status: data.ReviewPublished, userID: other, ownerOrModerator: true,
+			moderator: true, want: http.StatusOK, wantChecked: true,
+		},
+		{
+			name: "published owned by other", status: data.ReviewPublished, userID: other, ownerOrModerator: true,
+			want: http.StatusForbidden, wantChecked: true,
+		},
+		{
+			name: "permissions error", status: data.ReviewHidden, userID: other, moderatorErr: errPermissions,
+			wantErr: errPermissions, wantChecked: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			checked := false
+			review := &data.Review{ID: 1, MovieID: 1, UserID: author, Status: test.status}
+
+			got, err := reviewAccess(review, test.userID, test.ownerOrModerator, func() (bool, error) {
+				checked = true
+				return test.moderator, test.moderatorErr
+			})
+
+			if got != test.want || !errors.Is(err, test.wantErr) {
+				t.Errorf("got %d, %v want %d, %v", got, err, test.want, test.wantErr)
+			}
+
+			if checked != test.wantChecked {
+				t.Errorf("got moderator checked %t want %t", checked, test.wantChecked)
+			}
+		})
+	}
+}
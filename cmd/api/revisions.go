@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func (app *application) listMovieRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	validate := validator.New()
+
+	urlValues := r.URL.Query()
+
+	const (
+		defaultPageSize = 20
+		defaultPage     = 1
+	)
+
+	filters := data.Filters{
+		Page:         app.readInt(urlValues, "page", defaultPage, validate),
+		PageSize:     app.readInt(urlValues, "page_size", defaultPageSize, validate),
+		Sort:         app.readString(urlValues, "sort", "-version"),
+		SortSafelist: []string{"version", "-version"},
+	}
+
+	if data.ValidateFilters(validate, filters); !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.MovieRevisions.GetAllForMovie(id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(revisions) == 0 && filters.Page == defaultPage {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) diffMovieRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	validate := validator.New()
+
+	urlValues := r.URL.Query()
+
+	from := app.readInt(urlValues, "from", 0, validate)
+	to := app.readInt(urlValues, "to", 0, validate)
+
+	validate.CheckError(from > 0, "from", validator.GreaterThan(0))
+	validate.CheckError(to > 0, "to", validator.GreaterThan(0))
+
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	fromRevision, err := app.models.MovieRevisions.Get(id, int32(from))
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	toRevision, err := app.models.MovieRevisions.Get(id, int32(to))
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"from":    fromRevision.Version,
+		"to":      toRevision.Version,
+		"changes": data.DiffRevisions(fromRevision, toRevision),
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreMovieRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	version, err := app.readVersionParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.preconditionsMet(w, r, movie.Version) {
+		return
+	}
+
+	revision, err := app.models.MovieRevisions.Get(id, version)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	revision.Apply(movie)
+
+	app.saveMovie(w, r, movie)
+}
@@ -3,12 +3,13 @@ package main
 import (
 	"expvar"
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 func (app *application) routes() http.Handler {
-	router := httprouter.New()
+	router := patternRouter{httprouter.New()}
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
@@ -17,16 +18,155 @@ func (app *application) routes() http.Handler {
 
 	router.Handler(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
 	router.Handler(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
-	router.Handler(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id", staticSegments{
+		"trash":  app.requirePermission("movies:write", app.listDeletedMoviesHandler),
+		"export": app.requirePermission("movies:read", app.exportMoviesHandler),
+	}.or("id", app.requirePermission("movies:read", app.showMovieHandler)))
+	router.Handler(http.MethodPost, "/v1/movies/:id", staticSegments{
+		"import": app.requirePermission("movies:write", app.importMoviesHandler),
+		"batch":  app.requirePermission("movies:write", app.batchMoviesHandler),
+	}.or("id", http.HandlerFunc(app.methodNotAllowedResponse)))
+	router.Handler(http.MethodPut, "/v1/movies/:id", app.requirePermission("movies:write", app.replaceMovieHandler))
 	router.Handler(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
 	router.Handler(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	router.Handler(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission("movies:write", app.restoreMovieHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/revisions",
+		app.requirePermission("movies:read", app.listMovieRevisionsHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/revisions/diff",
+		app.requirePermission("movies:read", app.diffMovieRevisionsHandler))
+	router.Handler(http.MethodPost, "/v1/movies/:id/revisions/:version/restore",
+		app.requirePermission("movies:write", app.restoreMovieRevisionHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/translations",
+		app.requirePermission("movies:read", app.listMovieTranslationsHandler))
+	router.Handler(http.MethodPut, "/v1/movies/:id/translations/:locale",
+		app.requirePermission("movies:write", app.putMovieTranslationHandler))
+	router.Handler(http.MethodDelete, "/v1/movies/:id/translations/:locale",
+		app.requirePermission("movies:write", app.deleteMovieTranslationHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/releases",
+		app.requirePermission("movies:read", app.listMovieReleasesHandler))
+	router.Handler(http.MethodPut, "/v1/movies/:id/releases",
+		app.requirePermission("movies:write", app.replaceMovieReleasesHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/external-ids",
+		app.requirePermission("movies:read", app.showMovieExternalIDsHandler))
+	router.Handler(http.MethodPut, "/v1/movies/:id/external-ids",
+		app.requirePermission("movies:write", app.replaceMovieExternalIDsHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/credits", app.requirePermission("movies:read", app.listMovieCreditsHandler))
+	router.Handler(http.MethodPut, "/v1/movies/:id/credits",
+		app.requirePermission("movies:write", app.replaceMovieCreditsHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/poster", app.requirePermission("movies:read", app.showMoviePosterHandler))
+	router.Handler(http.MethodPost, "/v1/movies/:id/poster",
+		app.requirePermission("movies:write", app.uploadMoviePosterHandler))
+	router.Handler(http.MethodDelete, "/v1/movies/:id/poster",
+		app.requirePermission("movies:write", app.deleteMoviePosterHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/similar",
+		app.requirePermission("movies:read", app.listSimilarMoviesHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/ratings", app.requirePermission("movies:read", app.showMovieRatingHandler))
+	router.Handler(http.MethodPut, "/v1/movies/:id/ratings", app.requirePermission("reviews:write", app.setMovieRatingHandler))
+	router.Handler(http.MethodDelete, "/v1/movies/:id/ratings",
+		app.requirePermission("reviews:write", app.deleteMovieRatingHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.listMovieReviewsHandler))
+	router.Handler(http.MethodPost, "/v1/movies/:id/reviews",
+		app.requirePermission("reviews:write", app.createMovieReviewHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/reviews/:review_id",
+		app.requirePermission("movies:read", app.showMovieReviewHandler))
+	router.Handler(http.MethodPatch, "/v1/movies/:id/reviews/:review_id",
+		app.requirePermission("reviews:write", app.updateMovieReviewHandler))
+	router.Handler(http.MethodDelete, "/v1/movies/:id/reviews/:review_id",
+		app.requirePermission("reviews:write", app.deleteMovieReviewHandler))
+	router.Handler(http.MethodGet, "/v1/movies/:id/reviews/:review_id/revisions",
+		app.requirePermission("reviews:write", app.listMovieReviewRevisionsHandler))
+	router.Handler(http.MethodPut, "/v1/movies/:id/reviews/:review_id/moderation",
+		app.requirePermission("reviews:moderate", app.moderateMovieReviewHandler))
+
+	router.Handler(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	router.Handler(http.MethodPost, "/v1/genres", app.requirePermission("genres:admin", app.createGenreHandler))
+	router.Handler(http.MethodGet, "/v1/genres/:id", app.requirePermission("movies:read", app.showGenreHandler))
+	router.Handler(http.MethodPatch, "/v1/genres/:id", app.requirePermission("genres:admin", app.updateGenreHandler))
+	router.Handler(http.MethodDelete, "/v1/genres/:id", app.requirePermission("genres:admin", app.deleteGenreHandler))
+	router.Handler(http.MethodPost, "/v1/genres/:id/merge", app.requirePermission("genres:admin", app.mergeGenreHandler))
+
+	router.Handler(http.MethodGet, "/v1/people", app.requirePermission("movies:read", app.listPeopleHandler))
+	router.Handler(http.MethodPost, "/v1/people", app.requirePermission("movies:write", app.createPersonHandler))
+	router.Handler(http.MethodGet, "/v1/people/:id", app.requirePermission("movies:read", app.showPersonHandler))
+	router.Handler(http.MethodPatch, "/v1/people/:id", app.requirePermission("movies:write", app.updatePersonHandler))
+	router.Handler(http.MethodDelete, "/v1/people/:id", app.requirePermission("movies:write", app.deletePersonHandler))
+	router.Handler(http.MethodGet, "/v1/people/:id/credits",
+		app.requirePermission("movies:read", app.listPersonCreditsHandler))
+
+	router.Handler(http.MethodGet, "/v1/stats/movies", app.requirePermission("movies:read", app.movieStatsHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 
+	router.Handler(http.MethodGet, "/v1/users/me/recommendations",
+		app.requirePermission("movies:read", app.listUserRecommendationsHandler))
+	router.Handler(http.MethodGet, "/v1/users/me/lists", app.requirePermission("movies:read", app.listUserListsHandler))
+	router.Handler(http.MethodPost, "/v1/users/me/lists", app.requirePermission("movies:read", app.createUserListHandler))
+	router.Handler(http.MethodGet, "/v1/users/me/lists/:list", app.requirePermission("movies:read", app.showUserListHandler))
+	router.Handler(http.MethodPatch, "/v1/users/me/lists/:list",
+		app.requirePermission("movies:read", app.updateUserListHandler))
+	router.Handler(http.MethodDelete, "/v1/users/me/lists/:list",
+		app.requirePermission("movies:read", app.deleteUserListHandler))
+	router.Handler(http.MethodPut, "/v1/users/me/lists/:list/items/:movie_id",
+		app.requirePermission("movies:read", app.putUserListItemHandler))
+	router.Handler(http.MethodDelete, "/v1/users/me/lists/:list/items/:movie_id",
+		app.requirePermission("movies:read", app.deleteUserListItemHandler))
+	router.Handler(http.MethodPost, "/v1/users/me/lists/:list/share",
+		app.requirePermission("movies:read", app.shareUserListHandler))
+	router.Handler(http.MethodDelete, "/v1/users/me/lists/:list/share",
+		app.requirePermission("movies:read", app.unshareUserListHandler))
+
+	router.Handler(http.MethodGet, "/v1/lists/:id", app.requirePermission("movies:read", app.showPublicListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/shared-lists/:token", app.showSharedListHandler)
+
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 
+	router.Handler(http.MethodGet, "/v1/admin/log-level", app.requirePermission("logs:admin", app.showLogLevelHandler))
+	router.Handler(http.MethodPut, "/v1/admin/log-level", app.requirePermission("logs:admin", app.updateLogLevelHandler))
+
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
-	return app.metrics(app.recoverPanic(app.rateLimit(app.authenticate(router))))
+	return app.requestID(app.logAccess(
+		app.metrics(app.recoverPanic(app.rateLimit(app.negotiate(app.authenticate(app.runtimeFormat(router)))))),
+	))
+}
+
+// patternRouter registers routes on an httprouter.Router, attaching the pattern
+// of the route to the context of the requests it handles.
+type patternRouter struct {
+	*httprouter.Router
+}
+
+func (router patternRouter) Handler(method, path string, handler http.Handler) {
+	router.Router.Handler(method, path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, contextSetRoutePattern(r, path))
+	}))
+}
+
+func (router patternRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	router.Handler(method, path, handler)
+}
+
+// staticSegments maps static path segments to their handler.
+// httprouter doesn't allow a static segment to live next to a named parameter
+// (e.g. /v1/movies/trash and /v1/movies/:id), so static routes are registered
+// under the parameter and dispatched here instead.
+type staticSegments map[string]http.Handler
+
+// or returns a handler serving the static segment matching the value of
+// the named parameter, and falling back to next for any other value.
+// The static segment replaces the parameter in the route pattern.
+func (s staticSegments) or(param string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		segment := httprouter.ParamsFromContext(r.Context()).ByName(param)
+
+		if handler, found := s[segment]; found {
+			pattern := strings.Replace(routePatternFromContext(r.Context()), ":"+param, segment, 1)
+			handler.ServeHTTP(w, contextSetRoutePattern(r, pattern))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
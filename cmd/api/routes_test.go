@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestStaticSegments(t *testing.T) {
+	t.Parallel()
+
+	handler := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			io.WriteString(w, body) //nolint:errcheck
+		})
+	}
+
+	router := httprouter.New()
+	router.Handler(http.MethodGet, "/movies/:id", staticSegments{
+		"trash": handler("trash"),
+	}.or("id", handler("movie")))
+
+	server := newTestServer(t, router)
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/movies/trash", "trash"},
+		{"/movies/1", "movie"},
+		{"/movies/trashcan", "movie"},
+	}
+
+	for _, test := range tests {
+		code, body := server.get(t, test.path)
+
+		if code != http.StatusOK {
+			t.Errorf("%s: got http status %d want 200", test.path, code)
+		}
+
+		if body != test.want {
+			t.Errorf("%s: got body %q want %q", test.path, body, test.want)
+		}
+	}
+}
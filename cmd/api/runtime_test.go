@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRuntimeFormatMiddleware(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name       string
+		url        string
+		header     string
+		wantStatus int
+		want       data.RuntimeFormat
+	}{
+		{name: "default", url: "/", wantStatus: http.StatusOK},
+		{name: "parameter", url: "/?runtime_format=hours", wantStatus: http.StatusOK, want: data.RuntimeFormatHours},
+		{name: "header", url: "/", header: "ISO8601", wantStatus: http.StatusOK, want: data.RuntimeFormatISO8601},
+		{
+			name: "parameter over header", url: "/?runtime_format=minutes", header: "hours",
+			wantStatus: http.StatusOK, want: data.RuntimeFormatMinutes,
+		},
+		{name: "invalid", url: "/?runtime_format=seconds", wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got data.RuntimeFormat
+
+			handler := app.runtimeFormat(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = app.contextGetRuntimeFormat(r)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.header != "" {
+				r.Header.Set("Runtime-Format", test.header)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.wantStatus {
+				t.Errorf("got status %d want %d", w.Code, test.wantStatus)
+			}
+
+			if got != test.want {
+				t.Errorf("got format %q want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteResponseRuntimeFormat(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	newRequest := func(accept string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+
+		return app.contextSetRuntimeFormat(r, data.RuntimeFormatHours)
+	}
+
+	w := httptest.NewRecorder()
+
+	err := app.writeResponse(w, newRequest(mediaTypeJSON), http.StatusOK, envelope{"movies": []*data.ScoredMovie{
+		{Movie: data.Movie{ID: 1, Title: "Arrival", Runtime: 116, Version: 1}, Score: 0.5},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"movies":[{"id":1,"title":"Arrival","version":1,"runtime":"1h 56m","score":0.5}]}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("got body %s want %s", w.Body.String(), want)
+	}
+
+	w = httptest.NewRecorder()
+
+	err = app.writeResponse(w, newRequest(mediaTypeMessagePack), http.StatusOK, envelope{
+		"movie": &data.Movie{ID: 1, Title: "Arrival", Runtime: 116},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Movie struct {
+			ID      int64  `msgpack:"id"`
+			Runtime string `msgpack:"runtime"`
+		} `msgpack:"movie"`
+	}
+
+	if err = msgpack.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Movie.ID != 1 || got.Movie.Runtime != "1h 56m" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestFormatRuntimes(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	r := app.contextSetRuntimeFormat(httptest.NewRequest(http.MethodGet, "/", nil), data.RuntimeFormatHours)
+
+	cached := &data.MovieStats{Runtime: data.RuntimeStats{Min: 90, P25: 95, Median: 100, P75: 110, P90: 120, Max: 125}}
+	env := envelope{
+		"revisions": []*data.MovieRevision{{MovieID: 1, Version: 1, Runtime: 116}},
+		"changes":   []data.FieldChange{{Field: "runtime", From: data.Runtime(116), To: data.Runtime(120)}},
+		"stats":     cached,
+		"items":     []*data.ListItem{{MovieID: 1, Runtime: 116}},
+		"results":   []batchResult{{Movie: &data.Movie{ID: 1, Runtime: 116}}},
+	}
+
+	app.formatRuntimes(r, env)
+
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Revisions []struct {
+			Runtime string `json:"runtime"`
+		} `json:"revisions"`
+		Changes []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"changes"`
+		Stats struct {
+			Runtime struct {
+				Min string `json:"min"`
+				Max string `json:"max"`
+			} `json:"runtime"`
+		} `json:"stats"`
+		Items []struct {
+			Runtime string `json:"runtime"`
+		} `json:"items"`
+		Results []struct {
+			Movie struct {
+				Runtime string `json:"runtime"`
+			} `json:"movie"`
+		} `json:"results"`
+	}
+
+	if err = json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Revisions[0].Runtime != "1h 56m" || got.Changes[0].From != "1h 56m" || got.Changes[0].To != "2h" ||
+		got.Stats.Runtime.Min != "1h 30m" || got.Stats.Runtime.Max != "2h 5m" || got.Items[0].Runtime != "1h 56m" ||
+		got.Results[0].Movie.Runtime != "1h 56m" {
+		t.Errorf("got %s", body)
+	}
+
+	if cached.Runtime.RuntimeFormat != "" {
+		t.Errorf("the cached statistics were modified")
+	}
+}
@@ -11,17 +11,26 @@ import (
 	"time"
 )
 
+const (
+	idleTimeout  = time.Minute
+	readTimeout  = 5 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
 func (app *application) serve() error {
 	srv := &http.Server{
 		Addr:         app.config.addr,
 		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  idleTimeout,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
 	}
 
 	shutdownError := make(chan error)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	app.startJobs(jobsCtx)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -37,6 +46,8 @@ func (app *application) serve() error {
 			shutdownError <- err
 		}
 
+		stopJobs()
+
 		app.logger.Info("completing background tasks", "addr", srv.Addr)
 		app.wg.Wait()
 		shutdownError <- nil
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+// maxStatsCacheEntries bounds the number of filter combinations kept in the stats cache.
+const maxStatsCacheEntries = 1000
+
+// statsCache keeps movie statistics for a while, keyed by the criteria they were computed for.
+// The zero value is an empty cache.
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	stats   *data.MovieStats
+	expires time.Time
+}
+
+// get returns the statistics cached for the key, if they haven't expired at now.
+func (c *statsCache) get(key string, now time.Time) (*data.MovieStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found || !now.Before(entry.expires) {
+		return nil, false
+	}
+
+	return entry.stats, true
+}
+
+// put caches the statistics for the key until now+ttl. Expired entries are
+// evicted when the cache is full, and the whole cache is cleared if that isn't enough.
+func (c *statsCache) put(key string, stats *data.MovieStats, now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]statsCacheEntry)
+	}
+
+	if _, found := c.entries[key]; !found && len(c.entries) >= maxStatsCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= maxStatsCacheEntries {
+			clear(c.entries)
+		}
+	}
+
+	c.entries[key] = statsCacheEntry{stats: stats, expires: now.Add(ttl)}
+}
+
+// statsCacheKey identifies the criteria regardless of the order genres were listed in.
+func statsCacheKey(criteria data.MovieCriteria) (string, error) {
+	criteria.Genres = slices.Clone(criteria.Genres)
+	slices.Sort(criteria.Genres)
+
+	key, err := json.Marshal(criteria)
+	if err != nil {
+		return "", fmt.Errorf("encoding stats cache key: %w", err)
+	}
+
+	return string(key), nil
+}
+
+func (app *application) movieStatsHandler(w http.ResponseWriter, r *http.Request) {
+	validate := validator.New()
+
+	criteria, err := app.readMovieCriteria(r.URL.Query(), validate)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !validate.Valid() {
+		app.failedValidationResponse(w, r, validate.Errors)
+		return
+	}
+
+	key, err := statsCacheKey(criteria)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	now := time.Now()
+
+	stats, found := app.stats.get(key, now)
+	if !found {
+		stats, err = app.models.Movies.Stats(criteria)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.stats.put(key, stats, now, app.config.stats.cacheTTL)
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"stats": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+)
+
+func TestStatsCache(t *testing.T) {
+	t.Parallel()
+
+	var cache statsCache
+
+	now := time.Now()
+	stats := &data.MovieStats{Total: 42}
+
+	if _, found := cache.get("key", now); found {
+		t.Fatal("empty cache returned stats")
+	}
+
+	cache.put("disabled", stats, now, 0)
+
+	if _, found := cache.get("disabled", now); found {
+		t.Error("stats were cached with a zero ttl")
+	}
+
+	cache.put("key", stats, now, time.Minute)
+
+	if got, found := cache.get("key", now.Add(time.Second)); !found || got != stats {
+		t.Errorf("got %v, %v want cached stats", got, found)
+	}
+
+	if _, found := cache.get("key", now.Add(time.Minute)); found {
+		t.Error("expired stats were returned")
+	}
+}
+
+func TestStatsCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	var cache statsCache
+
+	now := time.Now()
+	stats := &data.MovieStats{}
+
+	cache.put("expired", stats, now.Add(-time.Hour), time.Minute)
+
+	for i := 1; i < maxStatsCacheEntries; i++ {
+		cache.put(string(rune(i)), stats, now, time.Minute)
+	}
+
+	cache.put("new", stats, now, time.Minute)
+
+	if len(cache.entries) != maxStatsCacheEntries {
+		t.Errorf("got %d entries want %d: expired entry wasn't evicted", len(cache.entries), maxStatsCacheEntries)
+	}
+
+	cache.put("overflow", stats, now, time.Minute)
+
+	if len(cache.entries) != 1 {
+		t.Errorf("got %d entries want 1: full cache wasn't cleared", len(cache.entries))
+	}
+}
+
+func TestStatsCacheKey(t *testing.T) {
+	t.Parallel()
+
+	a, err := statsCacheKey(data.MovieCriteria{Genres: []string{"Drama", "Comedy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := statsCacheKey(data.MovieCriteria{Genres: []string{"Comedy", "Drama"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := statsCacheKey(data.MovieCriteria{Genres: []string{"Comedy"}, ReleasedIn: "FR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a != b {
+		t.Errorf("keys depend on the order of genres: %q != %q", a, b)
+	}
+
+	if a == c {
+		t.Errorf("different criteria share the key %q", a)
+	}
+}
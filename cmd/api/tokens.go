@@ -15,7 +15,7 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		Password string `json:"password"`
 	}
 
-	err := app.readJSON(w, r, &input)
+	err := app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -59,7 +59,7 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"authentication_token": token}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
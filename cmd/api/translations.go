@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Crocmagnon/greenlight/internal/data"
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxLanguageRanges bounds the number of language ranges read from Accept-Language.
+const maxLanguageRanges = 10
+
+func (app *application) listMovieTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieOrRespond(w, r)
+	if !ok {
+		return
+	}
+
+	translations, err := app.models.Translations.GetAllForMovie(movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"translations": translations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putMovieTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Title    string `json:"title"`
+		Synopsis string `json:"synopsis"`
+	}
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	translation := &data.MovieTranslation{
+		MovieID:  id,
+		Locale:   httprouter.ParamsFromContext(r.Context()).ByName("locale"),
+		Title:    input.Title,
+		Synopsis: input.Synopsis,
+	}
+	v := validator.New()
+
+	if data.ValidateMovieTranslation(v, translation); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	created, err := app.models.Translations.Put(translation)
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/translations/%s", id, translation.Locale))
+
+	err = app.writeResponse(w, r, status, envelope{"translation": translation}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Translations.Delete(id, httprouter.ParamsFromContext(r.Context()).ByName("locale"))
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "translation successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// localizeMovies translates the movies in the language preferred by the client,
+// according to Accept-Language. Movies without a suitable translation keep their
+// original title.
+func (app *application) localizeMovies(r *http.Request, movies ...*data.Movie) error {
+	locales := acceptedLocales(r.Header.Get("Accept-Language"))
+	if len(locales) == 0 || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	translations, err := app.models.Translations.GetPreferred(locales, ids...)
+	if err != nil {
+		return fmt.Errorf("getting translations: %w", err)
+	}
+
+	for _, movie := range movies {
+		if translation, found := translations[movie.ID]; found {
+			movie.Localize(translation)
+		}
+	}
+
+	return nil
+}
+
+// acceptedLocales returns the locales acceptable according to an Accept-Language header,
+// by decreasing preference. Following the lookup scheme of RFC 4647, each language range
+// is followed by its truncations, e.g. "zh-Hant-TW" by "zh-Hant" and "zh".
+// The wildcard and ranges with a zero weight are ignored.
+func acceptedLocales(header string) []string {
+	type languageRange struct {
+		tag    string
+		weight float64
+	}
+
+	var ranges []languageRange
+
+	for _, value := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		weight := 1.0
+
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+
+			weight = parsed
+		}
+
+		if weight <= 0 || !data.LocaleRX.MatchString(tag) {
+			continue
+		}
+
+		ranges = append(ranges, languageRange{tag: data.CanonicalLocale(tag), weight: weight})
+
+		if len(ranges) == maxLanguageRanges {
+			break
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].weight > ranges[j].weight
+	})
+
+	var locales []string
+
+	for _, languageRange := range ranges {
+		for tag := languageRange.tag; tag != ""; {
+			if !slices.Contains(locales, tag) {
+				locales = append(locales, tag)
+			}
+
+			i := strings.LastIndex(tag, "-")
+			if i < 0 {
+				break
+			}
+
+			tag = tag[:i]
+		}
+	}
+
+	return locales
+}
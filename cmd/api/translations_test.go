@@ -0,0 +1,35 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAcceptedLocales(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"single", "fr", []string{"fr"}},
+		{"truncations", "zh-hant-tw", []string{"zh-Hant-TW", "zh-Hant", "zh"}},
+		{"weights", "en;q=0.5, fr-CA, de;q=0.8", []string{"fr-CA", "fr", "de", "en"}},
+		{"duplicates", "fr-CA, fr-FR;q=0.9", []string{"fr-CA", "fr", "fr-FR"}},
+		{"ignored", "*, es;q=0, it;q=abc, pt-BR", []string{"pt-BR", "pt"}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := acceptedLocales(test.header); !slices.Equal(got, test.want) {
+				t.Errorf("acceptedLocales(%q) = %v want %v", test.header, got, test.want)
+			}
+		})
+	}
+}
@@ -17,7 +17,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Password string `json:"password"`
 	}
 
-	err := app.readJSON(w, r, &input)
+	err := app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -46,7 +46,10 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	switch {
 	case errors.Is(err, data.ErrDuplicateEmail):
-		validate.AddError("email", "a user with this email address already exists")
+		validate.Add("email", validator.FieldError{
+			Code:    data.CodeDuplicateEmail,
+			Message: "a user with this email address already exists",
+		})
 		app.failedValidationResponse(w, r, validate.Errors)
 
 		return
@@ -55,7 +58,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Permissions.AddForUser(user.ID, "movies:read")
+	err = app.models.Permissions.AddForUser(user.ID, "movies:read", "reviews:write")
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -74,11 +77,11 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 		err = app.mailer.Send(user.Email, "user_welcome.tmpl", mailData)
 		if err != nil {
-			app.logger.Error(err.Error())
+			app.logger.ErrorContext(r.Context(), err.Error())
 		}
 	})
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -89,7 +92,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		TokenPlaintext string `json:"token"`
 	}
 
-	err := app.readJSON(w, r, &input)
+	err := app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -106,7 +109,10 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 
 	switch {
 	case errors.Is(err, data.ErrRecordNotFound):
-		validate.AddError("token", "invalid or expired activation token")
+		validate.Add("token", validator.FieldError{
+			Code:    data.CodeTokenInvalid,
+			Message: "invalid or expired activation token",
+		})
 		app.failedValidationResponse(w, r, validate.Errors)
 
 		return
@@ -134,7 +140,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -8,11 +8,13 @@ require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.9
 	github.com/tomasen/realip v0.0.0-20180522021738-f0c99a92ddce
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	golang.org/x/crypto v0.13.0
 	golang.org/x/time v0.3.0
 )
 
 require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/mail.v2 v2.3.1 // indirect
 )
@@ -0,0 +1,43 @@
+// Package blob provides stores for binary objects such as images.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// Errors returned by stores.
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// An Object is a stored object being read. Body must be closed by the caller.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// A Store stores objects by key. Keys are slash-separated relative paths,
+// e.g. "posters/1/abc.jpg".
+type Store interface {
+	// Put stores size bytes read from body under key, replacing any existing object.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get returns the object stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete deletes the object stored under key. Deleting a missing object isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// validateKey rejects keys that could escape the store's namespace.
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// LocalStore stores objects as files under a directory.
+// The content type of objects is derived from the extension of their key.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a LocalStore, creating dir if needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil { //nolint:gomnd
+		return nil, fmt.Errorf("creating blob directory: %w", err)
+	}
+
+	return &LocalStore{dir: dir}, nil
+}
+
+// Put implements Store.
+func (s *LocalStore) Put(_ context.Context, key string, body io.Reader, _ int64, _ string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	name := filepath.Join(s.dir, filepath.FromSlash(key))
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil { //nolint:gomnd
+		return fmt.Errorf("creating object directory: %w", err)
+	}
+
+	// Write to a temporary file first so that readers never see a partial object.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err = io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing object: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing object: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("renaming object: %w", err)
+	}
+
+	return nil
+}
+
+// Get implements Store.
+func (s *LocalStore) Get(_ context.Context, key string) (*Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(key)))
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("opening object: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading object info: %w", err)
+	}
+
+	return &Object{
+		Body:        file,
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		Size:        info.Size(),
+	}, nil
+}
+
+// Delete implements Store.
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("deleting object: %w", err)
+	}
+
+	return nil
+}
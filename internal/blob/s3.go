@@ -0,0 +1,247 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrUnexpectedStatus is returned when an S3 request fails.
+var ErrUnexpectedStatus = errors.New("unexpected status")
+
+// S3Config configures an S3Store.
+type S3Config struct {
+	// Endpoint is the base URL of the S3-compatible service, e.g. https://s3.eu-west-3.amazonaws.com
+	// or http://127.0.0.1:9000 for a local MinIO.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store stores objects in a bucket of an S3-compatible service.
+// Requests use path-style URLs and are signed with AWS Signature Version 4.
+type S3Store struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Store creates an S3Store. A nil client means http.DefaultClient.
+func NewS3Store(config S3Config, client *http.Client) *S3Store {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &S3Store{config: config, client: client, now: time.Now}
+}
+
+// Put implements Store.
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := s.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("putting object: %w %d", ErrUnexpectedStatus, res.StatusCode)
+	}
+
+	return nil
+}
+
+// Get implements Store.
+func (s *S3Store) Get(ctx context.Context, key string) (*Object, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return &Object{Body: res.Body, ContentType: res.Header.Get("Content-Type"), Size: res.ContentLength}, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, ErrNotFound
+	default:
+		res.Body.Close()
+		return nil, fmt.Errorf("getting object: %w %d", ErrUnexpectedStatus, res.StatusCode)
+	}
+}
+
+// Delete implements Store.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(req)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("deleting object: %w %d", ErrUnexpectedStatus, res.StatusCode)
+	}
+}
+
+func (s *S3Store) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(s.config.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint: %w", err)
+	}
+
+	endpoint.Path += "/" + s.config.Bucket + "/" + key
+	endpoint.RawPath = uriEncode(endpoint.Path, false)
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	return req, nil
+}
+
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	// The payload is streamed, so it isn't part of the signature.
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	signV4(req, s.config, s.now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+
+	return res, nil
+}
+
+// signV4 signs the request for S3 with AWS Signature Version 4, covering the host and
+// all the headers already set. X-Amz-Content-Sha256 must be set beforehand.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func signV4(req *http.Request, config S3Config, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		headers["host"] = req.URL.Host
+	}
+
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := date + "/" + config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretKey), date)
+	key = hmacSHA256(key, config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func canonicalQuery(values url.Values) string {
+	pairs := make([]string, 0, len(values))
+
+	for name, list := range values {
+		for _, value := range list {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte but unreserved characters, and slashes
+// unless encodeSlash is set.
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return encoded.String()
+}
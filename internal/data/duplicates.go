@@ -0,0 +1,308 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Sources of external identifiers.
+const (
+	SourceIMDb     = "imdb"
+	SourceTMDB     = "tmdb"
+	SourceWikidata = "wikidata"
+)
+
+// duplicateRuntimeTolerance is the runtime difference, in minutes, under which
+// movies with the same title and year are considered duplicates.
+const duplicateRuntimeTolerance = 5
+
+// ErrDuplicateExternalID is returned when an external identifier is already used by another movie.
+var ErrDuplicateExternalID = errors.New("duplicate external id")
+
+var (
+	imdbIDRX     = regexp.MustCompile(`^tt\d{7,10}$`)
+	tmdbIDRX     = regexp.MustCompile(`^\d{1,10}$`)
+	wikidataIDRX = regexp.MustCompile(`^Q\d{1,12}$`)
+)
+
+// ExternalIDs identify a movie in other databases.
+// Each identifier is used by at most one movie.
+type ExternalIDs struct {
+	IMDb     string `json:"imdb,omitempty"`
+	TMDB     string `json:"tmdb,omitempty"`
+	Wikidata string `json:"wikidata,omitempty"`
+}
+
+// bySource returns the identifiers that are set, keyed by source.
+func (ids ExternalIDs) bySource() map[string]string {
+	bySource := make(map[string]string, 3) //nolint:gomnd
+
+	for source, id := range map[string]string{SourceIMDb: ids.IMDb, SourceTMDB: ids.TMDB, SourceWikidata: ids.Wikidata} {
+		if id != "" {
+			bySource[source] = id
+		}
+	}
+
+	return bySource
+}
+
+// set sets the identifier of the given source.
+func (ids *ExternalIDs) set(source, id string) {
+	switch source {
+	case SourceIMDb:
+		ids.IMDb = id
+	case SourceTMDB:
+		ids.TMDB = id
+	case SourceWikidata:
+		ids.Wikidata = id
+	}
+}
+
+// Codes of the errors reported for invalid external identifiers.
+const (
+	CodeIMDbID     = "imdb_id"
+	CodeTMDBID     = "tmdb_id"
+	CodeWikidataID = "wikidata_id"
+)
+
+// ValidateExternalIDs validates external identifiers.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+func ValidateExternalIDs(v *validator.Validator, field string, ids ExternalIDs) {
+	v = v.Nested(field)
+
+	v.CheckError(ids.IMDb == "" || validator.Matches(ids.IMDb, imdbIDRX), "imdb", validator.FieldError{
+		Code:    CodeIMDbID,
+		Message: "must be an IMDb title identifier, e.g. tt0133093",
+	})
+	v.CheckError(ids.TMDB == "" || validator.Matches(ids.TMDB, tmdbIDRX), "tmdb", validator.FieldError{
+		Code:    CodeTMDBID,
+		Message: "must be a numeric TMDB identifier",
+	})
+	v.CheckError(ids.Wikidata == "" || validator.Matches(ids.Wikidata, wikidataIDRX), "wikidata", validator.FieldError{
+		Code:    CodeWikidataID,
+		Message: "must be a Wikidata item identifier, e.g. Q83495",
+	})
+}
+
+// GetExternalIDs returns the external identifiers of the given movies, keyed by movie ID.
+// Movies without identifiers are absent from the returned map.
+func (m MovieModel) GetExternalIDs(ids ...int64) (map[int64]*ExternalIDs, error) {
+	query := `
+		SELECT movie_id, source, external_id
+		FROM movie_external_ids
+		WHERE movie_id = ANY($1)`
+
+	var rows []struct {
+		MovieID    int64  `db:"movie_id"`
+		Source     string `db:"source"`
+		ExternalID string `db:"external_id"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &rows, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("querying external ids: %w", err)
+	}
+
+	byMovie := make(map[int64]*ExternalIDs, len(ids))
+
+	for _, row := range rows {
+		if byMovie[row.MovieID] == nil {
+			byMovie[row.MovieID] = &ExternalIDs{}
+		}
+
+		byMovie[row.MovieID].set(row.Source, row.ExternalID)
+	}
+
+	return byMovie, nil
+}
+
+// SetExternalIDs replaces the external identifiers of a movie.
+// ErrRecordNotFound is returned if the movie doesn't exist, and ErrDuplicateExternalID
+// if an identifier is used by another movie.
+func (m MovieModel) SetExternalIDs(movieID int64, ids ExternalIDs) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		var locked int64
+
+		err := tx.GetContext(ctx, &locked, `SELECT id FROM movies WHERE id = $1 FOR UPDATE`, movieID)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return fmt.Errorf("locking movie: %w", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM movie_external_ids WHERE movie_id = $1`, movieID); err != nil {
+			return fmt.Errorf("deleting external ids: %w", err)
+		}
+
+		return insertExternalIDs(ctx, tx, movieID, ids)
+	})
+}
+
+func insertExternalIDs(ctx context.Context, tx *sqlx.Tx, movieID int64, ids ExternalIDs) error {
+	for source, id := range ids.bySource() {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO movie_external_ids (movie_id, source, external_id) VALUES ($1, $2, $3)`,
+			movieID, source, id)
+
+		var pqErr *pq.Error
+
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505": // unique_violation
+			return ErrDuplicateExternalID
+		case err != nil:
+			return fmt.Errorf("inserting external id: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// TitleKey normalizes a title like the movie_title_key SQL function does:
+// "The Matrix" and "the matrix!" share the "thematrix" key.
+func TitleKey(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return -1
+	}, title)
+}
+
+// LikelyDuplicates reports whether two movies that aren't stored yet are likely
+// duplicates, by the same rules as FindDuplicates.
+func LikelyDuplicates(a, b *Movie) bool {
+	if a.ExternalIDs != nil && b.ExternalIDs != nil {
+		for source, id := range a.ExternalIDs.bySource() {
+			if b.ExternalIDs.bySource()[source] == id {
+				return true
+			}
+		}
+	}
+
+	runtimeDiff := a.Runtime - b.Runtime
+	if runtimeDiff < 0 {
+		runtimeDiff = -runtimeDiff
+	}
+
+	return a.Year == b.Year && runtimeDiff <= duplicateRuntimeTolerance && TitleKey(a.Title) == TitleKey(b.Title)
+}
+
+// FindDuplicates returns the movies, including deleted ones, that are likely
+// duplicates of the given movie: movies sharing one of its external identifiers,
+// or with the same normalized title and year and a close runtime.
+func (m MovieModel) FindDuplicates(movie *Movie) ([]*Movie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	duplicates, err := findDuplicates(ctx, m.DB, []*Movie{movie})
+	if err != nil {
+		return nil, err
+	}
+
+	return duplicates[0], nil
+}
+
+// FindDuplicatesTx is the transactional variant of FindDuplicates, for several movies at once.
+// The returned slice holds the duplicates of each movie, in the same order.
+func (MovieModel) FindDuplicatesTx(ctx context.Context, tx *sqlx.Tx, movies []*Movie) ([][]*Movie, error) {
+	return findDuplicates(ctx, tx, movies)
+}
+
+func findDuplicates(ctx context.Context, db sqlx.QueryerContext, movies []*Movie) ([][]*Movie, error) {
+	var (
+		titles, imdbIDs, tmdbIDs, wikidataIDs []string
+		years, runtimes                       []int64
+	)
+
+	for _, movie := range movies {
+		var ids ExternalIDs
+		if movie.ExternalIDs != nil {
+			ids = *movie.ExternalIDs
+		}
+
+		titles = append(titles, movie.Title)
+		years = append(years, int64(movie.Year))
+		runtimes = append(runtimes, int64(movie.Runtime))
+		imdbIDs = append(imdbIDs, ids.IMDb)
+		tmdbIDs = append(tmdbIDs, ids.TMDB)
+		wikidataIDs = append(wikidataIDs, ids.Wikidata)
+	}
+
+	query := `
+		WITH input AS (
+			SELECT *
+			FROM unnest($1::text[], $2::integer[], $3::integer[], $4::text[], $5::text[], $6::text[])
+				WITH ORDINALITY AS i(title, year, runtime, imdb, tmdb, wikidata, ord)
+		)
+		SELECT d.ord, m.id, m.created_at, m.title, m.year, m.runtime, m.genres, m.version, m.deleted_at,
+			m.rating, m.rating_count
+		FROM (
+			SELECT i.ord, m.id
+			FROM input AS i
+			JOIN movies AS m
+				ON movie_title_key(m.title) = movie_title_key(i.title)
+				AND m.year = i.year
+				AND abs(m.runtime - i.runtime) <= $7
+			UNION
+			SELECT i.ord, e.movie_id
+			FROM input AS i
+			JOIN movie_external_ids AS e
+				ON (e.source, e.external_id) IN (('imdb', i.imdb), ('tmdb', i.tmdb), ('wikidata', i.wikidata))
+		) AS d(ord, id)
+		JOIN movies AS m ON m.id = d.id
+		ORDER BY d.ord, m.id`
+
+	args := []any{
+		pq.Array(titles), pq.Array(years), pq.Array(runtimes),
+		pq.Array(imdbIDs), pq.Array(tmdbIDs), pq.Array(wikidataIDs),
+		duplicateRuntimeTolerance,
+	}
+
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying duplicate movies: %w", err)
+	}
+
+	defer rows.Close()
+
+	duplicates := make([][]*Movie, len(movies))
+
+	for rows.Next() {
+		var row struct {
+			Ord int `db:"ord"`
+			Movie
+		}
+
+		if err = rows.StructScan(&row); err != nil {
+			return nil, fmt.Errorf("scanning duplicate movie: %w", err)
+		}
+
+		duplicates[row.Ord-1] = append(duplicates[row.Ord-1], &row.Movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating duplicate movies: %w", err)
+	}
+
+	return duplicates, nil
+}
@@ -0,0 +1,66 @@
+package data
+
+import "testing"
+
+func TestTitleKey(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"The Matrix":       "thematrix",
+		"the matrix!":      "thematrix",
+		"Amélie":           "amélie",
+		"2001: A Space…":   "2001aspace",
+		"  -- ":            "",
+		"WALL·E":           "walle",
+		"Se7en (Director)": "se7endirector",
+	}
+
+	for title, want := range tests {
+		if got := TitleKey(title); got != want {
+			t.Errorf("TitleKey(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestLikelyDuplicates(t *testing.T) {
+	t.Parallel()
+
+	matrix := &Movie{Title: "The Matrix", Year: 1999, Runtime: 136, ExternalIDs: &ExternalIDs{IMDb: "tt0133093"}}
+
+	tests := []struct {
+		name  string
+		movie *Movie
+		want  bool
+	}{
+		{name: "same title", movie: &Movie{Title: "the matrix", Year: 1999, Runtime: 136}, want: true},
+		{name: "close runtime", movie: &Movie{Title: "The Matrix", Year: 1999, Runtime: 131}, want: true},
+		{name: "far runtime", movie: &Movie{Title: "The Matrix", Year: 1999, Runtime: 130}},
+		{name: "other year", movie: &Movie{Title: "The Matrix", Year: 2021, Runtime: 136}},
+		{name: "other title", movie: &Movie{Title: "The Matrix Reloaded", Year: 1999, Runtime: 136}},
+		{
+			name:  "shared external id",
+			movie: &Movie{Title: "Matrix", Year: 1998, Runtime: 120, ExternalIDs: &ExternalIDs{IMDb: "tt0133093"}},
+			want:  true,
+		},
+		{
+			name:  "other external id",
+			movie: &Movie{Title: "Matrix", Year: 1999, Runtime: 136, ExternalIDs: &ExternalIDs{IMDb: "tt0234215"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := LikelyDuplicates(matrix, test.movie); got != test.want {
+				t.Errorf("got %t want %t", got, test.want)
+			}
+
+			if got := LikelyDuplicates(test.movie, matrix); got != test.want {
+				t.Errorf("got %t want %t when swapped", got, test.want)
+			}
+		})
+	}
+}
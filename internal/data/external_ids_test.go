@@ -0,0 +1,43 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestValidateExternalIDs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		ids        ExternalIDs
+		wantErrors []string
+	}{
+		{"empty", ExternalIDs{}, nil},
+		{"valid", ExternalIDs{IMDb: "tt0133093", TMDB: "603", Wikidata: "Q83495"}, nil},
+		{"invalid", ExternalIDs{IMDb: "0133093", TMDB: "tt603", Wikidata: "83495"},
+			[]string{"externalIds.imdb", "externalIds.tmdb", "externalIds.wikidata"}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			validate := validator.New()
+			ValidateExternalIDs(validate, "externalIds", test.ids)
+
+			if len(validate.Errors) != len(test.wantErrors) {
+				t.Errorf("got errors %v want keys %v", validate.Errors, test.wantErrors)
+			}
+
+			for _, key := range test.wantErrors {
+				if _, found := validate.Errors[validator.Pointer(key)]; !found {
+					t.Errorf("missing error for %q in %v", key, validate.Errors)
+				}
+			}
+		})
+	}
+}
@@ -52,11 +52,12 @@ func ValidateFilters(validate *validator.Validator, filters Filters) {
 		maxPageSize = 100
 	)
 
-	validate.Check(filters.Page > 0, "page", "must be greater than zero")
-	validate.Check(filters.Page <= maxPage, "page", "must be a maximum of 10 million")
-	validate.Check(filters.PageSize > 0, "page_size", "must be greater than zero")
-	validate.Check(filters.PageSize <= maxPageSize, "page_size", "must be a maximum of 100")
-	validate.Check(validator.PermittedValue(filters.Sort, filters.SortSafelist...), "sort", "invalid sort value")
+	validate.CheckError(filters.Page > 0, "page", validator.GreaterThan(0))
+	validate.CheckError(filters.Page <= maxPage, "page", validator.Max(maxPage))
+	validate.CheckError(filters.PageSize > 0, "page_size", validator.GreaterThan(0))
+	validate.CheckError(filters.PageSize <= maxPageSize, "page_size", validator.Max(maxPageSize))
+	validate.CheckError(validator.PermittedValue(filters.Sort, filters.SortSafelist...), "sort",
+		validator.OneOf(filters.SortSafelist...))
 }
 
 // Metadata holds pagination metadata.
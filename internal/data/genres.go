@@ -0,0 +1,466 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrDuplicateGenre is returned when a genre's name or aliases clash with another genre.
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	// ErrGenreInUse is returned when deleting a genre that movies still reference.
+	ErrGenreInUse = errors.New("genre in use")
+)
+
+// revisionsChunkSize bounds the number of revisions recorded by a single statement,
+// to stay below the placeholder limit of Postgres.
+const revisionsChunkSize = 1000
+
+// A Genre is an entry of the genres vocabulary, as stored in the DB.
+// Movies reference genres by Name. Aliases are slugs of other spellings
+// that are accepted in place of the name.
+type Genre struct {
+	ID        int64          `db:"id"         json:"id"`
+	CreatedAt time.Time      `db:"created_at" json:"-"`
+	Name      string         `db:"name"       json:"name"`
+	Slug      string         `db:"slug"       json:"slug"`
+	Aliases   pq.StringArray `db:"aliases"    json:"aliases"`
+	Movies    int64          `db:"movies"     json:"movies"`
+	Version   int32          `db:"version"    json:"version"`
+}
+
+// GenreSlug returns the slug of a genre name: lowercase letters and digits,
+// with any other run of characters replaced by a single dash.
+// "Sci-Fi", "sci fi" and " SCI/FI" all share the "sci-fi" slug.
+func GenreSlug(name string) string {
+	var slug strings.Builder
+
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = slug.Len() > 0
+			continue
+		}
+
+		if dash {
+			slug.WriteByte('-')
+			dash = false
+		}
+
+		slug.WriteRune(r)
+	}
+
+	return slug.String()
+}
+
+// setSlugs sets the slug of the genre from its name, and normalizes its aliases to
+// sorted unique slugs, dropping the ones matching the genre's own slug.
+func (g *Genre) setSlugs() {
+	g.Slug = GenreSlug(g.Name)
+
+	aliases := make(pq.StringArray, 0, len(g.Aliases))
+
+	for _, alias := range g.Aliases {
+		if slug := GenreSlug(alias); slug != "" && slug != g.Slug {
+			aliases = append(aliases, slug)
+		}
+	}
+
+	slices.Sort(aliases)
+	g.Aliases = slices.Compact(aliases)
+}
+
+// Codes of the errors reported for invalid genres.
+const (
+	CodeSurroundingSpaces = "surrounding_spaces"
+	CodeLettersOrDigits   = "letters_or_digits"
+	CodeGenreClash        = "genre_clash"
+	CodeMergeIntoSelf     = "merge_into_self"
+	CodeUnknownGenre      = "unknown_genre"
+)
+
+// ValidateGenre validates a genre.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	lettersOrDigits := validator.FieldError{Code: CodeLettersOrDigits, Message: "must contain letters or digits"}
+
+	v.CheckError(genre.Name != "", "name", validator.Required())
+	v.CheckError(len(genre.Name) <= 100, "name", validator.MaxLength(100))
+	v.CheckError(genre.Name == strings.TrimSpace(genre.Name), "name", validator.FieldError{
+		Code:    CodeSurroundingSpaces,
+		Message: "must not start or end with spaces",
+	})
+	v.CheckError(genre.Name == "" || GenreSlug(genre.Name) != "", "name", lettersOrDigits)
+
+	v.CheckError(len(genre.Aliases) <= 20, "aliases", validator.MaxItems(20))
+
+	validator.Each(v, "aliases", genre.Aliases, func(v *validator.Validator, _ int, alias string) {
+		v.CheckError(GenreSlug(alias) != "", "", lettersOrDigits)
+	})
+}
+
+// GenreVocabulary maps the slugs of genre names and aliases to the canonical genre names.
+type GenreVocabulary map[string]string
+
+// Canonical returns the canonical name of the given genre name or alias.
+// The boolean is false when the genre is unknown.
+func (v GenreVocabulary) Canonical(name string) (string, bool) {
+	canonical, ok := v[GenreSlug(name)]
+
+	return canonical, ok
+}
+
+// Normalize returns the canonical names of the given genres.
+// Unknown genres are returned unchanged.
+func (v GenreVocabulary) Normalize(genres []string) []string {
+	normalized := make([]string, 0, len(genres))
+
+	for _, genre := range genres {
+		if canonical, ok := v.Canonical(genre); ok {
+			genre = canonical
+		}
+
+		normalized = append(normalized, genre)
+	}
+
+	return normalized
+}
+
+// GenreModel implements methods to query the database.
+type GenreModel struct {
+	DB *sqlx.DB
+}
+
+// Vocabulary returns the vocabulary of all known genres.
+func (m GenreModel) Vocabulary() (GenreVocabulary, error) {
+	query := `
+		SELECT name, slug, aliases
+		FROM genres`
+
+	var genres []*Genre
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &genres, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying genres: %w", err)
+	}
+
+	vocabulary := make(GenreVocabulary, len(genres))
+
+	for _, genre := range genres {
+		vocabulary[genre.Slug] = genre.Name
+
+		for _, alias := range genre.Aliases {
+			vocabulary[alias] = genre.Name
+		}
+	}
+
+	return vocabulary, nil
+}
+
+// GetAll returns all genres sorted by name, along with the number of movies using them.
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT g.id, g.created_at, g.name, g.slug, g.aliases, g.version,
+			(SELECT count(*) FROM movies AS m WHERE m.genres @> ARRAY[g.name] AND m.deleted_at IS NULL) AS movies
+		FROM genres AS g
+		ORDER BY g.name`
+
+	genres := []*Genre{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &genres, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying genres: %w", err)
+	}
+
+	return genres, nil
+}
+
+// Get returns the genre with the given id.
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT g.id, g.created_at, g.name, g.slug, g.aliases, g.version,
+			(SELECT count(*) FROM movies AS m WHERE m.genres @> ARRAY[g.name] AND m.deleted_at IS NULL) AS movies
+		FROM genres AS g
+		WHERE g.id = $1`
+
+	var genre Genre
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &genre, query, id)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying genre: %w", err)
+	}
+
+	return &genre, nil
+}
+
+// Insert inserts a genre in the DB.
+// Genre.ID, Genre.CreatedAt, Genre.Slug and Genre.Version are set on the passed genre,
+// and its aliases are normalized.
+// ErrDuplicateGenre is returned if its name or aliases clash with another genre.
+func (m GenreModel) Insert(genre *Genre) error {
+	genre.setSlugs()
+
+	query := `
+		INSERT INTO genres (name, slug, aliases)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		if err := lockGenres(ctx, tx, genre); err != nil {
+			return err
+		}
+
+		err := tx.GetContext(ctx, genre, query, genre.Name, genre.Slug, genre.Aliases)
+		if err != nil {
+			return genreWriteError("inserting genre", err)
+		}
+
+		return nil
+	})
+}
+
+// Update updates a genre in the DB. When it's renamed, the movies using it are
+// rewritten with the new name and get a new revision attributed to the given user,
+// and the previous name is kept as an alias.
+// Genre.Slug and Genre.Version are set on the passed genre.
+// ErrDuplicateGenre is returned if its name or aliases clash with another genre.
+func (m GenreModel) Update(genre *Genre, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		var previous string
+
+		err := tx.GetContext(ctx, &previous, `SELECT name FROM genres WHERE id = $1 AND version = $2`,
+			genre.ID, genre.Version)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err != nil:
+			return fmt.Errorf("querying genre: %w", err)
+		}
+
+		genre.Aliases = append(genre.Aliases, previous)
+		genre.setSlugs()
+
+		if err = lockGenres(ctx, tx, genre); err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE genres
+			SET name = $1, slug = $2, aliases = $3, version = version + 1
+			WHERE id = $4
+			RETURNING version`
+
+		err = tx.GetContext(ctx, &genre.Version, query, genre.Name, genre.Slug, genre.Aliases, genre.ID)
+		if err != nil {
+			return genreWriteError("updating genre", err)
+		}
+
+		if genre.Name == previous {
+			return nil
+		}
+
+		_, err = replaceMovieGenre(ctx, tx, userID, previous, genre.Name)
+
+		return err
+	})
+}
+
+// Merge merges the source genre into the target genre: the movies using the source
+// are rewritten with the target and get a new revision attributed to the given user,
+// the source name and aliases become aliases of the target, and the source is deleted.
+// It returns the number of rewritten movies.
+// Genre.Aliases and Genre.Version are set on the passed target.
+func (m GenreModel) Merge(source, target *Genre, userID int64) (int64, error) {
+	var rewritten int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1 AND version = $2`,
+			source.ID, source.Version)
+		if err != nil {
+			return fmt.Errorf("deleting genre: %w", err)
+		}
+
+		if rows, rowsErr := result.RowsAffected(); rowsErr != nil || rows == 0 {
+			return errors.Join(ErrEditConflict, rowsErr)
+		}
+
+		target.Aliases = append(target.Aliases, source.Name)
+		target.Aliases = append(target.Aliases, source.Aliases...)
+		target.setSlugs()
+
+		if err = lockGenres(ctx, tx, target); err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE genres
+			SET aliases = $1, version = version + 1
+			WHERE id = $2 AND version = $3
+			RETURNING version`
+
+		err = tx.GetContext(ctx, &target.Version, query, target.Aliases, target.ID, target.Version)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err != nil:
+			return fmt.Errorf("updating genre: %w", err)
+		}
+
+		rewritten, err = replaceMovieGenre(ctx, tx, userID, source.Name, target.Name)
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rewritten, nil
+}
+
+// Delete deletes the genre with the given id.
+// ErrGenreInUse is returned if a movie, even a deleted one, still uses it.
+func (m GenreModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM genres AS g
+		WHERE g.id = $1
+		RETURNING EXISTS (SELECT 1 FROM movies AS m WHERE m.genres @> ARRAY[g.name]) AS in_use`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		var inUse bool
+
+		err := tx.GetContext(ctx, &inUse, query, id)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return fmt.Errorf("deleting genre: %w", err)
+		case inUse:
+			return ErrGenreInUse
+		}
+
+		return nil
+	})
+}
+
+// lockGenres locks the genres table against concurrent writes for the rest of the transaction,
+// and returns ErrDuplicateGenre if another genre's name or aliases clash with the genre's.
+func lockGenres(ctx context.Context, tx *sqlx.Tx, genre *Genre) error {
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE genres IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("locking genres: %w", err)
+	}
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM genres
+			WHERE id <> $1 AND (slug = ANY($2) OR aliases && $2)
+		)`
+
+	slugs := append(pq.StringArray{genre.Slug}, genre.Aliases...)
+
+	var clash bool
+
+	if err := tx.GetContext(ctx, &clash, query, genre.ID, slugs); err != nil {
+		return fmt.Errorf("checking genre clashes: %w", err)
+	}
+
+	if clash {
+		return ErrDuplicateGenre
+	}
+
+	return nil
+}
+
+func genreWriteError(action string, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return ErrDuplicateGenre
+	}
+
+	return fmt.Errorf("%s: %w", action, err)
+}
+
+// replaceMovieGenre replaces the genre from with the genre to in all movies, dropping
+// the duplicates this creates, and records the new revisions attributed to the given user.
+// It returns the number of rewritten movies.
+func replaceMovieGenre(ctx context.Context, tx *sqlx.Tx, userID int64, from, to string) (int64, error) {
+	query := `
+		UPDATE movies AS m
+		SET genres = ARRAY(
+				SELECT genre
+				FROM unnest(array_replace(m.genres, $1::text, $2::text)) WITH ORDINALITY AS t(genre, ord)
+				GROUP BY genre
+				ORDER BY min(ord)
+			),
+			version = version + 1
+		WHERE m.genres @> ARRAY[$1::text]
+		RETURNING id, created_at, title, year, runtime, genres, version`
+
+	var movies []*Movie
+
+	err := tx.SelectContext(ctx, &movies, query, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("rewriting movie genres: %w", err)
+	}
+
+	for start := 0; start < len(movies); start += revisionsChunkSize {
+		chunk := movies[start:min(start+revisionsChunkSize, len(movies))]
+
+		if err = insertRevisions(ctx, tx, userID, chunk...); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(movies)), nil
+}
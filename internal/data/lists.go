@@ -0,0 +1,490 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Kinds of lists. Each user has exactly one watchlist and one watched list,
+// created on first use, and any number of collections.
+const (
+	ListWatchlist  = "watchlist"
+	ListWatched    = "watched"
+	ListCollection = "collection"
+)
+
+// A List is an ordered list of movies owned by a user, as stored in the DB.
+type List struct {
+	ID        int64     `db:"id"         json:"id"`
+	UserID    int64     `db:"user_id"    json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	Kind      string    `db:"kind"       json:"kind"`
+	Name      string    `db:"name"       json:"name"`
+	Public    bool      `db:"public"     json:"public"`
+	Shared    bool      `db:"shared"     json:"shared"`
+	Items     int32     `db:"items"      json:"items"`
+	Version   int32     `db:"version"    json:"version"`
+}
+
+// A ListItem is a movie in a list. Positions start at 0.
+type ListItem struct {
+	MovieID  int64     `db:"movie_id" json:"movieId"`
+	Position int32     `db:"position" json:"position"`
+	AddedAt  time.Time `db:"added_at" json:"addedAt"`
+	Title    string    `db:"title"    json:"title"`
+	Year     int32     `db:"year"     json:"year,omitempty"`
+	Runtime  Runtime   `db:"runtime"  json:"runtime,omitempty"`
+
+	// RuntimeFormat is the format of the runtime sent to the client.
+	RuntimeFormat RuntimeFormat `db:"-" json:"-"`
+}
+
+// listItem has the fields of ListItem, without its MarshalJSON method.
+type listItem ListItem
+
+// MarshalJSON implements json.Marshaler, sending the runtime in the requested format.
+func (i ListItem) MarshalJSON() ([]byte, error) {
+	if i.RuntimeFormat.isDefault() {
+		return json.Marshal(listItem(i)) //nolint:wrapcheck
+	}
+
+	formatted := struct {
+		listItem
+		Runtime any `json:"runtime,omitempty"`
+	}{listItem: listItem(i)}
+
+	if i.Runtime != 0 {
+		formatted.Runtime = i.Runtime.Formatted(i.RuntimeFormat)
+	}
+
+	return json.Marshal(formatted) //nolint:wrapcheck
+}
+
+// ValidateList validates a list.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidateList(v *validator.Validator, list *List) {
+	v.CheckError(list.Name != "", "name", validator.Required())
+	v.CheckError(len(list.Name) <= 200, "name", validator.MaxLength(200))
+	v.CheckError(validator.PermittedValue(list.Kind, ListWatchlist, ListWatched, ListCollection), "kind",
+		validator.OneOf(ListWatchlist, ListWatched, ListCollection))
+}
+
+// CodeNotCollection is the code of the error reported when changing what only collections allow.
+const CodeNotCollection = "not_collection"
+
+// RenameList sets the name of the list. Only collections can be renamed:
+// the watchlist and watched list keep their name.
+func RenameList(v *validator.Validator, list *List, name string) {
+	v.CheckError(list.Kind == ListCollection || name == list.Name, "name", validator.FieldError{
+		Code:    CodeNotCollection,
+		Message: "can only be changed for collections",
+	})
+	list.Name = name
+}
+
+// ListModel implements methods to query the database.
+type ListModel struct {
+	DB *sqlx.DB
+}
+
+const listColumns = `lists.id, lists.user_id, lists.created_at, lists.kind, lists.name, lists.public,
+	lists.share_hash IS NOT NULL AS shared, lists.version,
+	(SELECT count(*) FROM list_items WHERE list_items.list_id = lists.id) AS items`
+
+// GetAllForUser returns all the lists of a user, starting with the watchlist and watched list.
+func (m ListModel) GetAllForUser(userID int64) ([]*List, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := m.createDefaultLists(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + listColumns + `
+		FROM lists
+		WHERE user_id = $1
+		ORDER BY kind = 'collection', kind DESC, lower(name), id`
+
+	lists := []*List{}
+
+	err := m.DB.SelectContext(ctx, &lists, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying lists: %w", err)
+	}
+
+	return lists, nil
+}
+
+// GetForUser returns the list with the given id, if it belongs to the user.
+func (m ListModel) GetForUser(userID, id int64) (*List, error) {
+	return m.get(`lists.id = $1 AND lists.user_id = $2`, id, userID)
+}
+
+// GetDefault returns the watchlist or watched list of the user.
+func (m ListModel) GetDefault(userID int64, kind string) (*List, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := m.createDefaultLists(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return m.get(`lists.user_id = $1 AND lists.kind = $2`, userID, kind)
+}
+
+// GetPublic returns the list with the given id if it's public.
+func (m ListModel) GetPublic(id int64) (*List, error) {
+	return m.get(`lists.id = $1 AND lists.public`, id)
+}
+
+// GetShared returns the list shared with the given plaintext token.
+func (m ListModel) GetShared(tokenPlaintext string) (*List, error) {
+	return m.get(`lists.share_hash = $1`, hashToken(tokenPlaintext))
+}
+
+func (m ListModel) get(condition string, args ...any) (*List, error) {
+	query := `
+		SELECT ` + listColumns + `
+		FROM lists
+		WHERE ` + condition
+
+	var list List
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &list, query, args...)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying list: %w", err)
+	}
+
+	return &list, nil
+}
+
+func (m ListModel) createDefaultLists(ctx context.Context, userID int64) error {
+	query := `
+		INSERT INTO lists (user_id, kind, name)
+		VALUES ($1, 'watchlist', 'Watchlist'), ($1, 'watched', 'Watched')
+		ON CONFLICT (user_id, kind) WHERE kind <> 'collection' DO NOTHING`
+
+	if _, err := m.DB.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("creating default lists: %w", err)
+	}
+
+	return nil
+}
+
+// Insert inserts a collection in the DB.
+// List.ID, List.CreatedAt, List.Kind and List.Version are set on the passed list.
+func (m ListModel) Insert(list *List) error {
+	query := `
+		INSERT INTO lists (user_id, kind, name, public)
+		VALUES ($1, 'collection', $2, $3)
+		RETURNING id, created_at, kind, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, list, query, list.UserID, list.Name, list.Public)
+	if err != nil {
+		return fmt.Errorf("inserting list: %w", err)
+	}
+
+	return nil
+}
+
+// Update updates the name and visibility of a list.
+// List.Version is set on the passed list.
+func (m ListModel) Update(list *List) error {
+	query := `
+		UPDATE lists
+		SET name = $1, public = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+	args := []any{list.Name, list.Public, list.ID, list.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &list.Version, query, args...)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrEditConflict
+	case err != nil:
+		return fmt.Errorf("updating list: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a collection. The watchlist and watched list can't be deleted.
+func (m ListModel) Delete(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, `DELETE FROM lists WHERE id = $1 AND kind = 'collection'`, id)
+	if err != nil {
+		return fmt.Errorf("deleting list: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("counting affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Share generates a new share token for the list and returns its plaintext.
+// Previously generated tokens stop working.
+func (m ListModel) Share(list *List) (string, error) {
+	plaintext, hash, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, `UPDATE lists SET share_hash = $1 WHERE id = $2`, hash, list.ID)
+	if err != nil {
+		return "", fmt.Errorf("sharing list: %w", err)
+	}
+
+	list.Shared = true
+
+	return plaintext, nil
+}
+
+// Unshare revokes the share token of the list.
+func (m ListModel) Unshare(list *List) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `UPDATE lists SET share_hash = NULL WHERE id = $1`, list.ID)
+	if err != nil {
+		return fmt.Errorf("unsharing list: %w", err)
+	}
+
+	list.Shared = false
+
+	return nil
+}
+
+// GetItems returns a page of the movies of a list, by position.
+// Movies in the trash are left out.
+func (m ListModel) GetItems(listID int64, filters Filters) ([]*ListItem, Metadata, error) {
+	query := `
+		SELECT count(*) OVER() AS total_records,
+			i.movie_id, i.position, i.added_at, m.title, m.year, m.runtime
+		FROM list_items AS i
+		JOIN movies AS m ON m.id = i.movie_id
+		WHERE i.list_id = $1 AND m.deleted_at IS NULL
+		ORDER BY i.position
+		LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryxContext(ctx, query, listID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("listing list items: %w", err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*ListItem{}
+
+	for rows.Next() {
+		var item struct {
+			TotalRecords int `db:"total_records"`
+			ListItem
+		}
+
+		err = rows.StructScan(&item)
+		if err != nil {
+			return nil, Metadata{}, fmt.Errorf("scanning list item: %w", err)
+		}
+
+		totalRecords = item.TotalRecords
+		items = append(items, &item.ListItem)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, fmt.Errorf("iterating over rows: %w", err)
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+// PutItem adds a movie to a list, or moves it if it's already there.
+// The movie is inserted at the given position, or appended when position is nil
+// or past the end of the list. The item is returned with its actual position.
+// ErrRecordNotFound is returned if the movie doesn't exist.
+//
+//nolint:funlen
+func (m ListModel) PutItem(listID, movieID int64, position *int32) (*ListItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	item := &ListItem{MovieID: movieID}
+
+	err := inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		// Lock the list so that concurrent moves don't interleave.
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM lists WHERE id = $1 FOR UPDATE`, listID); err != nil {
+			return fmt.Errorf("locking list: %w", err)
+		}
+
+		query := `
+			SELECT title, year, runtime
+			FROM movies
+			WHERE id = $1 AND deleted_at IS NULL`
+
+		err := tx.GetContext(ctx, item, query, movieID)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return fmt.Errorf("querying movie: %w", err)
+		}
+
+		if err = removeListItem(ctx, tx, listID, movieID); err != nil && !errors.Is(err, ErrRecordNotFound) {
+			return err
+		}
+
+		var count int32
+
+		err = tx.GetContext(ctx, &count, `SELECT count(*) FROM list_items WHERE list_id = $1`, listID)
+		if err != nil {
+			return fmt.Errorf("counting list items: %w", err)
+		}
+
+		item.Position = insertPosition(position, count)
+
+		query = `
+			UPDATE list_items
+			SET position = position + 1
+			WHERE list_id = $1 AND position >= $2`
+
+		if _, err = tx.ExecContext(ctx, query, listID, item.Position); err != nil {
+			return fmt.Errorf("shifting list items: %w", err)
+		}
+
+		query = `
+			INSERT INTO list_items (list_id, movie_id, position)
+			VALUES ($1, $2, $3)
+			RETURNING added_at`
+
+		if err = tx.GetContext(ctx, &item.AddedAt, query, listID, movieID, item.Position); err != nil {
+			return fmt.Errorf("inserting list item: %w", err)
+		}
+
+		return nil
+	})
+
+	return item, err
+}
+
+// insertPosition returns the position of a movie inserted in a list of count items,
+// once removed from it if it was already there: the requested position, or the end of the list.
+func insertPosition(position *int32, count int32) int32 {
+	if position != nil && *position < count {
+		return *position
+	}
+
+	return count
+}
+
+// DeleteItem removes a movie from a list.
+func (m ListModel) DeleteItem(listID, movieID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM lists WHERE id = $1 FOR UPDATE`, listID); err != nil {
+			return fmt.Errorf("locking list: %w", err)
+		}
+
+		return removeListItem(ctx, tx, listID, movieID)
+	})
+}
+
+// removeListItem removes a movie from a list and closes the gap it leaves.
+func removeListItem(ctx context.Context, tx *sqlx.Tx, listID, movieID int64) error {
+	var position int32
+
+	query := `DELETE FROM list_items WHERE list_id = $1 AND movie_id = $2 RETURNING position`
+
+	err := tx.GetContext(ctx, &position, query, listID, movieID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrRecordNotFound
+	case err != nil:
+		return fmt.Errorf("deleting list item: %w", err)
+	}
+
+	query = `
+		UPDATE list_items
+		SET position = position - 1
+		WHERE list_id = $1 AND position > $2`
+
+	if _, err = tx.ExecContext(ctx, query, listID, position); err != nil {
+		return fmt.Errorf("shifting list items: %w", err)
+	}
+
+	return nil
+}
+
+// InWatchlist returns which of the given movies are in the watchlist of the user.
+func (m ListModel) InWatchlist(userID int64, movieIDs ...int64) (map[int64]bool, error) {
+	query := `
+		SELECT i.movie_id
+		FROM list_items AS i
+		JOIN lists AS l ON l.id = i.list_id
+		WHERE l.user_id = $1 AND l.kind = 'watchlist' AND i.movie_id = ANY($2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var ids []int64
+
+	err := m.DB.SelectContext(ctx, &ids, query, userID, pq.Array(movieIDs))
+	if err != nil {
+		return nil, fmt.Errorf("querying watchlist: %w", err)
+	}
+
+	inWatchlist := make(map[int64]bool, len(ids))
+
+	for _, id := range ids {
+		inWatchlist[id] = true
+	}
+
+	return inWatchlist, nil
+}
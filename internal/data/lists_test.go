@@ -0,0 +1,129 @@
+package data
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestValidateList(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		list       List
+		wantErrors []string
+	}{
+		{name: "collection", list: List{Kind: ListCollection, Name: "Favourites"}},
+		{name: "watchlist", list: List{Kind: ListWatchlist, Name: "Watchlist"}},
+		{name: "watched", list: List{Kind: ListWatched, Name: "Watched"}},
+		{name: "missing name", list: List{Kind: ListCollection}, wantErrors: []string{"/name"}},
+		{name: "long name", list: List{Kind: ListCollection, Name: strings.Repeat("a", 201)}, wantErrors: []string{"/name"}},
+		{name: "longest name", list: List{Kind: ListCollection, Name: strings.Repeat("a", 200)}},
+		{name: "missing kind", list: List{Name: "Favourites"}, wantErrors: []string{"/kind"}},
+		{name: "unknown kind", list: List{Kind: "playlist", Name: "Favourites"}, wantErrors: []string{"/kind"}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := validator.New()
+			ValidateList(v, &test.list)
+
+			if len(v.Errors) != len(test.wantErrors) {
+				t.Errorf("got errors %v want errors for %v", v.Errors, test.wantErrors)
+			}
+
+			for _, pointer := range test.wantErrors {
+				if _, found := v.Errors[pointer]; !found {
+					t.Errorf("got errors %v want an error for %s", v.Errors, pointer)
+				}
+			}
+		})
+	}
+}
+
+func TestRenameList(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		kind  string
+		name  string
+		valid bool
+	}{
+		{ListCollection, "Sci-Fi classics", true},
+		{ListWatchlist, "Watchlist", true},
+		{ListWatchlist, "To watch", false},
+		{ListWatched, "Seen", false},
+	}
+
+	for _, test := range tests {
+		list := &List{Kind: test.kind, Name: "Watchlist"}
+		if test.kind == ListWatched {
+			list.Name = "Watched"
+		}
+
+		v := validator.New()
+		RenameList(v, list, test.name)
+
+		if v.Valid() != test.valid {
+			t.Errorf("renaming %s to %q: got valid %t want %t", test.kind, test.name, v.Valid(), test.valid)
+		}
+	}
+}
+
+func TestInsertPosition(t *testing.T) {
+	t.Parallel()
+
+	position := func(p int32) *int32 { return &p }
+
+	tests := []struct {
+		name     string
+		position *int32
+		count    int32
+		want     int32
+	}{
+		{"append to empty list", nil, 0, 0},
+		{"append", nil, 3, 3},
+		{"first", position(0), 3, 0},
+		{"middle", position(1), 3, 1},
+		{"last", position(2), 3, 2},
+		{"at end", position(3), 3, 3},
+		{"past end", position(10), 3, 3},
+	}
+
+	for _, test := range tests {
+		if got := insertPosition(test.position, test.count); got != test.want {
+			t.Errorf("%s: got %d want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestShareTokenHashed(t *testing.T) {
+	t.Parallel()
+
+	plaintext, hash, err := randomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plaintext) != 26 { //nolint:gomnd
+		t.Errorf("got plaintext %q of %d bytes want 26", plaintext, len(plaintext))
+	}
+
+	// The hash stored by Share must be the one GetShared looks the plaintext up with.
+	want := sha256.Sum256([]byte(plaintext))
+	if !bytes.Equal(hash, want[:]) || !bytes.Equal(hashToken(plaintext), hash) {
+		t.Errorf("got hash %x want %x", hash, want)
+	}
+
+	if other, _, _ := randomToken(); other == plaintext {
+		t.Errorf("got the same token %q twice", plaintext)
+	}
+}
@@ -16,19 +16,39 @@ var (
 
 // Models holds all model interfaces.
 type Models struct {
-	Movies      MovieModel
-	Tokens      TokenModel
-	Users       UserModel
-	Permissions PermissionModel
+	Movies          MovieModel
+	MovieRevisions  MovieRevisionModel
+	Translations    MovieTranslationModel
+	Genres          GenreModel
+	Lists           ListModel
+	People          PersonModel
+	Posters         PosterModel
+	Ratings         RatingModel
+	Recommendations RecommendationModel
+	Releases        ReleaseModel
+	Reviews         ReviewModel
+	Tokens          TokenModel
+	Users           UserModel
+	Permissions     PermissionModel
 }
 
 // NewModels initializes Models with the proper implementations
 // for production use.
 func NewModels(db *sqlx.DB) Models {
 	return Models{
-		Movies:      MovieModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Users:       UserModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Movies:          MovieModel{DB: db},
+		MovieRevisions:  MovieRevisionModel{DB: db},
+		Translations:    MovieTranslationModel{DB: db},
+		Genres:          GenreModel{DB: db},
+		Lists:           ListModel{DB: db},
+		People:          PersonModel{DB: db},
+		Posters:         PosterModel{DB: db},
+		Ratings:         RatingModel{DB: db},
+		Recommendations: RecommendationModel{DB: db},
+		Releases:        ReleaseModel{DB: db},
+		Reviews:         ReviewModel{DB: db},
+		Tokens:          TokenModel{DB: db},
+		Users:           UserModel{DB: db},
+		Permissions:     PermissionModel{DB: db},
 	}
 }
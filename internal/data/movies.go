@@ -3,8 +3,11 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Crocmagnon/greenlight/internal/validator"
@@ -24,39 +27,144 @@ type Movie struct {
 	Runtime   Runtime        `db:"runtime"    json:"runtime,omitempty"`
 	Genres    pq.StringArray `db:"genres"     json:"genres,omitempty"`
 	Version   int32          `db:"version"    json:"version"`
+	DeletedAt *time.Time     `db:"deleted_at" json:"deletedAt,omitempty"`
+	Credits   []Credit       `db:"-"          json:"credits,omitempty"`
+	Releases  []Release      `db:"-"          json:"releases,omitempty"`
+
+	// ExternalIDs are only loaded when requested.
+	ExternalIDs *ExternalIDs `db:"-" json:"externalIds,omitempty"`
+
+	// Rating is the average rating given by users, maintained along with RatingCount
+	// when ratings are set or removed.
+	Rating      float32 `db:"rating"       json:"rating,omitempty"`
+	RatingCount int32   `db:"rating_count" json:"ratingCount,omitempty"`
+
+	// InWatchlist is set when the movie is served to an authenticated user.
+	InWatchlist *bool `db:"-" json:"inWatchlist,omitempty"`
+
+	// Locale is set when the movie is served translated, see [Movie.Localize].
+	Locale             string `db:"-" json:"locale,omitempty"`
+	OriginalTitle      string `db:"-" json:"originalTitle,omitempty"`
+	Synopsis           string `db:"-" json:"synopsis,omitempty"`
+	TranslationVersion int32  `db:"-" json:"-"`
+
+	// RuntimeFormat is the format of the runtime sent to the client.
+	RuntimeFormat RuntimeFormat `db:"-" json:"-"`
+}
+
+// movie has the fields of Movie, without its MarshalJSON method.
+type movie Movie
+
+// formattedMovie is marshaled in place of a movie whose runtime is sent in another format
+// than the default one.
+type formattedMovie struct {
+	movie
+	Runtime any `json:"runtime,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, sending the runtime in the requested format.
+func (m Movie) MarshalJSON() ([]byte, error) {
+	if m.defaultRuntimeFormat() {
+		return json.Marshal(movie(m)) //nolint:wrapcheck
+	}
+
+	return json.Marshal(m.formatted()) //nolint:wrapcheck
+}
+
+func (m Movie) defaultRuntimeFormat() bool {
+	return m.RuntimeFormat.isDefault()
+}
+
+func (m Movie) formatted() formattedMovie {
+	formatted := formattedMovie{movie: movie(m)}
+
+	if m.Runtime != 0 {
+		formatted.Runtime = m.Runtime.Formatted(m.RuntimeFormat)
+	}
+
+	return formatted
+}
+
+// Localize replaces the title of the movie with the translated one, keeping
+// the original title aside. A localized movie must not be written back to the DB.
+func (m *Movie) Localize(translation *MovieTranslation) {
+	m.OriginalTitle = m.Title
+	m.Title = translation.Title
+	m.Synopsis = translation.Synopsis
+	m.Locale = translation.Locale
+	m.TranslationVersion = translation.Version
+}
+
+// CodeYearInFuture is the code of the error reported for movies released after this year.
+const CodeYearInFuture = "year_in_future"
+
+// ErrYearInFuture is returned when the releases of a movie are replaced by releases
+// that no longer allow its year.
+var ErrYearInFuture = errors.New("year is in the future")
+
+// YearInFuture is the error reported for movies dated after this year and their first release.
+func YearInFuture() validator.FieldError {
+	return validator.FieldError{
+		Code:    CodeYearInFuture,
+		Message: "must not be in the future, unless the movie is released that year",
+	}
+}
+
+// yearAllowed reports whether a movie with the given releases can be dated the given year.
+func yearAllowed(year int32, releases []Release) bool {
+	return year <= max(int32(time.Now().Year()), firstReleaseYear(releases))
 }
 
 // ValidateMovie validates a movie.
+// Genres must be known to the vocabulary, and aliases are replaced with
+// the canonical genre names on the passed movie.
+// The year can only be in the future for upcoming movies, up to the year of their first release.
 // The passed validator will contain all detected errors.
 // The caller is expected to call [validator.Validator.Valid]
 // after this method.
-func ValidateMovie(validate *validator.Validator, movie *Movie) {
+func ValidateMovie(validate *validator.Validator, movie *Movie, genres GenreVocabulary) {
 	const (
-		titleMaxLength    = 500
-		minYear           = 1888
-		minGenres         = 1
-		maxGenres         = 5
-		fieldTitle        = "title"
-		fieldYear         = "year"
-		fieldRuntime      = "runtime"
-		fieldGenres       = "genres"
-		errMustBeProvided = "must be provided"
+		titleMaxLength = 500
+		minYear        = 1888
+		minGenres      = 1
+		maxGenres      = 5
+		fieldTitle     = "title"
+		fieldYear      = "year"
+		fieldRuntime   = "runtime"
+		fieldGenres    = "genres"
 	)
 
-	validate.Check(movie.Title != "", fieldTitle, errMustBeProvided)
-	validate.Check(len(movie.Title) <= titleMaxLength, fieldTitle, "must not be more than 500 bytes long")
+	validate.CheckError(movie.Title != "", fieldTitle, validator.Required())
+	validate.CheckError(len(movie.Title) <= titleMaxLength, fieldTitle, validator.MaxLength(titleMaxLength))
+
+	validate.CheckError(movie.Year != 0, fieldYear, validator.Required())
+	validate.CheckError(movie.Year >= minYear, fieldYear, validator.GreaterThan(minYear-1))
+	validate.CheckError(yearAllowed(movie.Year, movie.Releases), fieldYear, YearInFuture())
 
-	validate.Check(movie.Year != 0, fieldYear, errMustBeProvided)
-	validate.Check(movie.Year >= minYear, fieldYear, "must be greater than 1888")
-	validate.Check(movie.Year <= int32(time.Now().Year()), fieldYear, "must not be in the future")
+	validate.CheckError(movie.Runtime != 0, fieldRuntime, validator.Required())
+	validate.CheckError(movie.Runtime > 0, fieldRuntime, validator.GreaterThan(0))
 
-	validate.Check(movie.Runtime != 0, fieldRuntime, errMustBeProvided)
-	validate.Check(movie.Runtime > 0, fieldRuntime, "must be a positive integer")
+	validate.CheckError(movie.Genres != nil, fieldGenres, validator.Required())
+	validate.CheckError(len(movie.Genres) >= minGenres, fieldGenres, validator.MinItems(minGenres))
+	validate.CheckError(len(movie.Genres) <= maxGenres, fieldGenres, validator.MaxItems(maxGenres))
 
-	validate.Check(movie.Genres != nil, fieldGenres, errMustBeProvided)
-	validate.Check(len(movie.Genres) >= minGenres, fieldGenres, "must contain at least 1 genre")
-	validate.Check(len(movie.Genres) <= maxGenres, fieldGenres, "must not contain more than 5 genres")
-	validate.Check(validator.Unique(movie.Genres), fieldGenres, "must not contain duplicate values")
+	validator.Each(validate, fieldGenres, movie.Genres, func(validate *validator.Validator, i int, genre string) {
+		canonical, ok := genres.Canonical(genre)
+		if !ok {
+			validate.Add("", validator.UnknownValue(genre))
+			return
+		}
+
+		movie.Genres[i] = canonical
+	})
+
+	validate.CheckError(validator.Unique(movie.Genres), fieldGenres, validator.NotUnique())
+
+	ValidateReleases(validate, movie.Releases)
+
+	if movie.ExternalIDs != nil {
+		ValidateExternalIDs(validate, "externalIds", *movie.ExternalIDs)
+	}
 }
 
 // MovieModel implements methods to query the database.
@@ -64,37 +172,195 @@ type MovieModel struct {
 	DB *sqlx.DB
 }
 
-// Insert inserts a movie in the database.
+// Insert inserts a movie in the database and records its first revision,
+// attributed to the given user.
 // Movie.CreatedAt and Movie.Version are set on the passed movie.
-func (m MovieModel) Insert(movie *Movie) error {
+func (m MovieModel) Insert(movie *Movie, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		return m.InsertTx(ctx, tx, movie, userID)
+	})
+}
+
+// InsertTx is the transactional variant of Insert.
+func (MovieModel) InsertTx(ctx context.Context, tx *sqlx.Tx, movie *Movie, userID int64) error {
 	query := `
 		INSERT INTO movies (title, year, runtime, genres)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at, version`
 	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	err := m.DB.GetContext(ctx, movie, query, args...)
+	err := tx.GetContext(ctx, movie, query, args...)
 	if err != nil {
 		return fmt.Errorf("inserting movie in DB: %w", err)
 	}
 
+	if err = insertReleases(ctx, tx, movie.ID, movie.Releases); err != nil {
+		return err
+	}
+
+	if movie.ExternalIDs != nil {
+		if err = insertExternalIDs(ctx, tx, movie.ID, *movie.ExternalIDs); err != nil {
+			return err
+		}
+	}
+
+	return insertRevisions(ctx, tx, userID, movie)
+}
+
+// BeginTx starts a transaction for the transactional variants of the MovieModel methods.
+// The transaction is bound to ctx.
+func (m MovieModel) BeginTx(ctx context.Context) (*sqlx.Tx, error) {
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+
+	return tx, nil
+}
+
+// WithSavepoint runs fn under a savepoint of tx. If fn fails, the changes it made
+// are rolled back but tx can still be used.
+func WithSavepoint(ctx context.Context, tx *sqlx.Tx, fn func() error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT greenlight"); err != nil {
+		return fmt.Errorf("creating savepoint: %w", err)
+	}
+
+	err := fn()
+	if err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT greenlight"); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("rolling back to savepoint: %w", rollbackErr))
+		}
+
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT greenlight"); err != nil {
+		return fmt.Errorf("releasing savepoint: %w", err)
+	}
+
 	return nil
 }
 
+// InsertBatch inserts movies with a single statement in tx, along with their releases
+// and external identifiers, and records their first revision, attributed to the given user.
+// The batch is inserted under a savepoint: on error, none of its movies are inserted
+// but tx can still be used.
+// Movie.ID, Movie.CreatedAt and Movie.Version are set on the passed movies.
+func (m MovieModel) InsertBatch(ctx context.Context, tx *sqlx.Tx, movies []*Movie, userID int64) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	const columns = 4
+
+	placeholders := make([]string, 0, len(movies))
+	args := make([]any, 0, len(movies)*columns)
+
+	for i, movie := range movies {
+		placeholders = append(placeholders, valuesPlaceholder(i*columns, columns))
+		args = append(args, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres))
+	}
+
+	query := `
+		INSERT INTO movies (title, year, runtime, genres)
+		VALUES ` + strings.Join(placeholders, ", ") + `
+		RETURNING id, created_at, version`
+
+	return WithSavepoint(ctx, tx, func() error {
+		rows, err := tx.QueryxContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("inserting movies in DB: %w", err)
+		}
+
+		// The rows must be closed before the connection can be used for the revisions.
+		err = scanInsertedMovies(rows, movies)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			if err = insertReleases(ctx, tx, movie.ID, movie.Releases); err != nil {
+				return err
+			}
+
+			if movie.ExternalIDs != nil {
+				if err = insertExternalIDs(ctx, tx, movie.ID, *movie.ExternalIDs); err != nil {
+					return err
+				}
+			}
+		}
+
+		return insertRevisions(ctx, tx, userID, movies...)
+	})
+}
+
+func scanInsertedMovies(rows *sqlx.Rows, movies []*Movie) error {
+	defer rows.Close()
+
+	// Postgres returns the inserted rows in the order of the VALUES list.
+	for i := 0; rows.Next(); i++ {
+		if err := rows.Scan(&movies[i].ID, &movies[i].CreatedAt, &movies[i].Version); err != nil {
+			return fmt.Errorf("scanning inserted movie: %w", err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating over rows: %w", err)
+	}
+
+	return nil
+}
+
+// MovieFields lists the fields of movies, by JSON name, that clients can select.
+// Fields that aren't stored in the movies table, like inWatchlist, are computed
+// when serving the movie.
+var MovieFields = []string{
+	"id", "title", "year", "runtime", "genres", "version", "rating", "ratingCount",
+	"inWatchlist", "locale", "originalTitle", "synopsis",
+}
+
+// movieFieldColumns maps the fields of movies to their column.
+var movieFieldColumns = map[string]string{
+	"title":   "title",
+	"year":    "year",
+	"runtime": "runtime",
+	"genres":  "genres",
+}
+
+// movieColumns returns the columns to select to get the given fields of movies,
+// or all of them if fields is empty. The columns needed to identify a movie and
+// compute its entity tag are always selected.
+func movieColumns(fields []string) string {
+	if len(fields) == 0 {
+		return "id, created_at, title, year, runtime, genres, version, rating, rating_count"
+	}
+
+	columns := []string{"id", "version", "rating", "rating_count"}
+
+	for _, field := range fields {
+		if column, found := movieFieldColumns[field]; found && !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+
+	return strings.Join(columns, ", ")
+}
+
 // Get returns the Movie with the given id from the DB,
 // or an error if it couldn't be found.
-func (m MovieModel) Get(id int64) (*Movie, error) {
+// When fields are given, only those are read, see [MovieFields].
+func (m MovieModel) Get(id int64, fields ...string) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
+		SELECT ` + movieColumns(fields) + `
 		FROM movies
-		WHERE id=$1`
+		WHERE id=$1 AND deleted_at IS NULL`
 
 	var movie Movie
 
@@ -113,13 +379,51 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-// Update updates a movie in the DB.
+// GetTx is the transactional variant of Get.
+// The movie is locked until the end of the transaction.
+func (MovieModel) GetTx(ctx context.Context, tx *sqlx.Tx, id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version, rating, rating_count
+		FROM movies
+		WHERE id=$1 AND deleted_at IS NULL
+		FOR UPDATE`
+
+	var movie Movie
+
+	err := tx.GetContext(ctx, &movie, query, id)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying movie: %w", err)
+	}
+
+	return &movie, nil
+}
+
+// Update updates a movie in the DB and records the new revision,
+// attributed to the given user.
 // Movie.Version is set on the passed movie.
-func (m MovieModel) Update(movie *Movie) error {
+func (m MovieModel) Update(movie *Movie, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		return m.UpdateTx(ctx, tx, movie, userID)
+	})
+}
+
+// UpdateTx is the transactional variant of Update.
+func (MovieModel) UpdateTx(ctx context.Context, tx *sqlx.Tx, movie *Movie, userID int64) error {
 	query := `
 		UPDATE movies
 		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 		RETURNING version`
 	args := []any{
 		movie.Title,
@@ -130,10 +434,7 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	err := m.DB.GetContext(ctx, &movie.Version, query, args...)
+	err := tx.GetContext(ctx, &movie.Version, query, args...)
 
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
@@ -142,21 +443,37 @@ func (m MovieModel) Update(movie *Movie) error {
 		return fmt.Errorf("inserting movie in DB: %w", err)
 	}
 
-	return nil
+	return insertRevisions(ctx, tx, userID, movie)
+}
+
+// Delete moves a movie to the trash.
+// Deleted movies are ignored by Get, GetAll and Update until they're restored
+// or purged.
+// When version is greater than zero, the movie is only deleted if its version
+// matches and ErrEditConflict is returned otherwise.
+func (m MovieModel) Delete(id int64, version int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return m.delete(ctx, m.DB, id, version)
+}
+
+// DeleteTx is the transactional variant of Delete.
+func (m MovieModel) DeleteTx(ctx context.Context, tx *sqlx.Tx, id int64, version int32) error {
+	return m.delete(ctx, tx, id, version)
 }
 
-// Delete deletes a movie from the DB.
-func (m MovieModel) Delete(id int64) error {
+func (MovieModel) delete(ctx context.Context, db sqlx.ExecerContext, id int64, version int32) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	query := `DELETE FROM movies WHERE id=$1`
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	query := `
+		UPDATE movies
+		SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 < 1 OR version = $2)`
 
-	res, err := m.DB.ExecContext(ctx, query, id)
+	res, err := db.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return fmt.Errorf("deleting movie from db: %w", err)
 	}
@@ -166,26 +483,219 @@ func (m MovieModel) Delete(id int64) error {
 		return fmt.Errorf("counting affected rows: %w", err)
 	}
 
-	if rows == 0 {
+	switch {
+	case rows == 0 && version > 0:
+		return ErrEditConflict
+	case rows == 0:
 		return ErrRecordNotFound
 	}
 
 	return nil
 }
 
+// GetDeleted returns the movie with the given id from the trash,
+// or an error if it couldn't be found there.
+func (m MovieModel) GetDeleted(id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, title, year, runtime, genres, version, deleted_at
+		FROM movies
+		WHERE id=$1 AND deleted_at IS NOT NULL`
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &movie, query, id)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying deleted movie: %w", err)
+	}
+
+	return &movie, nil
+}
+
+// Restore takes a movie out of the trash and returns it, provided its version
+// still matches. Movie.Version is incremented and the new revision is attributed
+// to the given user.
+func (m MovieModel) Restore(id int64, version int32, userID int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND version = $2
+		RETURNING id, created_at, title, year, runtime, genres, version, rating, rating_count`
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &movie, query, id, version)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err != nil:
+			return fmt.Errorf("restoring movie: %w", err)
+		}
+
+		return insertRevisions(ctx, tx, userID, &movie)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+// Purge permanently deletes movies that have been in the trash
+// for longer than retention. It returns the number of deleted movies.
+func (m MovieModel) Purge(retention time.Duration) (int64, error) {
+	query := `DELETE FROM movies WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("purging movies: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("counting affected rows: %w", err)
+	}
+
+	return rows, nil
+}
+
+// MovieCriteria holds the criteria used to search movies.
+// Zero values are ignored.
+type MovieCriteria struct {
+	Title    string
+	Genres   []string
+	Director string
+	Actor    string
+
+	// ReleasedIn, ReleasedAfter and ReleasedBefore match movies with a release
+	// in the country and date range. The bounds are inclusive.
+	ReleasedIn     string
+	ReleasedAfter  Date
+	ReleasedBefore Date
+
+	// ExternalIDs match the movie with these identifiers.
+	ExternalIDs ExternalIDs
+}
+
+// where returns the WHERE condition matching the criteria, adding its arguments to args.
+// Deleted movies never match.
+func (c MovieCriteria) where(args *queryArgs) string {
+	conditions := []string{"movies.deleted_at IS NULL"}
+
+	if c.Title != "" {
+		conditions = append(conditions, titleCondition(args.add(c.Title)))
+	}
+
+	if len(c.Genres) > 0 {
+		conditions = append(conditions, "movies.genres @> "+args.add(pq.Array(c.Genres)))
+	}
+
+	if c.Director != "" {
+		conditions = append(conditions, creditedCondition(RoleDirector, args.add(c.Director)))
+	}
+
+	if c.Actor != "" {
+		conditions = append(conditions, creditedCondition(RoleActor, args.add(c.Actor)))
+	}
+
+	for source, id := range c.ExternalIDs.bySource() {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1
+			FROM movie_external_ids
+			WHERE movie_external_ids.movie_id = movies.id
+			AND movie_external_ids.source = `+args.add(source)+`
+			AND movie_external_ids.external_id = `+args.add(id)+`)`)
+	}
+
+	if c.ReleasedIn != "" || !c.ReleasedAfter.IsZero() || !c.ReleasedBefore.IsZero() {
+		conditions = append(conditions, c.releasedCondition(args))
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// titleCondition matches movies whose original title, or translated title or synopsis,
+// match the placeholder. Translations are searched with the configuration of their language.
+func titleCondition(placeholder string) string {
+	return `(to_tsvector('simple', movies.title) @@ plainto_tsquery('simple', ` + placeholder + `)
+		OR EXISTS (
+			SELECT 1
+			FROM movie_translations
+			WHERE movie_translations.movie_id = movies.id
+			AND to_tsvector(movie_translations.search_config, movie_translations.title || ' ' || movie_translations.synopsis)
+				@@ plainto_tsquery(movie_translations.search_config, ` + placeholder + `)))`
+}
+
+// releasedCondition matches movies with a release matching the release criteria.
+func (c MovieCriteria) releasedCondition(args *queryArgs) string {
+	conditions := []string{"releases.movie_id = movies.id"}
+
+	if c.ReleasedIn != "" {
+		conditions = append(conditions, "releases.country = "+args.add(c.ReleasedIn))
+	}
+
+	if !c.ReleasedAfter.IsZero() {
+		conditions = append(conditions, "releases.date >= "+args.add(c.ReleasedAfter))
+	}
+
+	if !c.ReleasedBefore.IsZero() {
+		conditions = append(conditions, "releases.date <= "+args.add(c.ReleasedBefore))
+	}
+
+	return "EXISTS (SELECT 1 FROM releases WHERE " + strings.Join(conditions, " AND ") + ")"
+}
+
+// creditedCondition matches movies crediting a person whose name matches the placeholder
+// in the given role.
+func creditedCondition(role, placeholder string) string {
+	return `EXISTS (
+			SELECT 1
+			FROM movie_credits
+			JOIN people ON people.id = movie_credits.person_id
+			WHERE movie_credits.movie_id = movies.id
+			AND movie_credits.role = '` + role + `'
+			AND to_tsvector('simple', people.name) @@ plainto_tsquery('simple', ` + placeholder + `))`
+}
+
 // GetAll returns a filtered list of movies from the DB.
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
-	query := fmt.Sprintf(`SELECT count(*) OVER() AS total_records, id, created_at, title, year, runtime, genres, version
+// When fields are given, only those are read, see [MovieFields].
+func (m MovieModel) GetAll(criteria MovieCriteria, filters Filters, fields ...string) ([]*Movie, Metadata, error) {
+	var args queryArgs
+
+	where := criteria.where(&args)
+
+	query := fmt.Sprintf(`SELECT count(*) OVER() AS total_records,
+		%s
 		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
+		WHERE %s
 		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
-
-	const dbTimeout = 3 * time.Second
+		LIMIT %s OFFSET %s`,
+		movieColumns(fields), where, filters.sortColumn(), filters.sortDirection(),
+		args.add(filters.limit()), args.add(filters.offset()))
 
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryxContext(ctx, query, args...)
@@ -221,3 +731,102 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 
 	return movies, metadata, nil
 }
+
+// GetAllDeleted returns a page of the movies currently in the trash.
+func (m MovieModel) GetAllDeleted(filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER() AS total_records, id, created_at, title, year, runtime, genres, version, deleted_at
+		FROM movies
+		WHERE deleted_at IS NOT NULL
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryxContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("listing deleted movies: %w", err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie struct {
+			TotalRecords int `db:"total_records"`
+			Movie
+		}
+
+		err = rows.StructScan(&movie)
+		if err != nil {
+			return nil, Metadata{}, fmt.Errorf("scanning movie: %w", err)
+		}
+
+		totalRecords = movie.TotalRecords
+		movies = append(movies, &movie.Movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, fmt.Errorf("iterating over rows: %w", err)
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// Export streams all the movies matching the criteria, sorted according to filters,
+// through a server-side cursor. Pagination settings in filters are ignored.
+// handle is called with successive batches of at most batchSize movies; the batch slice
+// is reused between calls. Export stops at the first error returned by handle.
+func (m MovieModel) Export(
+	ctx context.Context, criteria MovieCriteria, filters Filters, batchSize int, handle func([]*Movie) error,
+) error {
+	tx, err := m.DB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	var args queryArgs
+
+	query := fmt.Sprintf(`
+		DECLARE movies_export NO SCROLL CURSOR FOR
+		SELECT id, created_at, title, year, runtime, genres, version, rating, rating_count
+		FROM movies
+		WHERE %s
+		ORDER BY %s %s, id ASC`, criteria.where(&args), filters.sortColumn(), filters.sortDirection())
+
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("declaring cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM movies_export", batchSize)
+	batch := make([]*Movie, 0, batchSize)
+
+	for {
+		batch = batch[:0]
+
+		if err = tx.SelectContext(ctx, &batch, fetch); err != nil {
+			return fmt.Errorf("fetching movies: %w", err)
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+
+		if err = handle(batch); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,359 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Roles a person can be credited with on a movie.
+const (
+	RoleDirector = "director"
+	RoleActor    = "actor"
+	RoleWriter   = "writer"
+)
+
+// ErrUnknownPerson is returned when crediting a person that doesn't exist.
+var ErrUnknownPerson = errors.New("unknown person")
+
+// A Person is someone who worked on movies, as stored in the DB.
+type Person struct {
+	ID        int64     `db:"id"         json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+	Name      string    `db:"name"       json:"name"`
+	BirthYear *int32    `db:"birth_year" json:"birthYear,omitempty"`
+	Version   int32     `db:"version"    json:"version"`
+}
+
+// A Credit links a person to a movie with a role.
+// Character is only meaningful for actors. Credits are listed by ascending BillingOrder.
+type Credit struct {
+	MovieID      int64  `db:"movie_id"      json:"movieId"`
+	MovieTitle   string `db:"movie_title"   json:"movieTitle,omitempty"`
+	PersonID     int64  `db:"person_id"     json:"personId"`
+	Name         string `db:"name"          json:"name,omitempty"`
+	Role         string `db:"role"          json:"role"`
+	Character    string `db:"character"     json:"character,omitempty"`
+	BillingOrder int32  `db:"billing_order" json:"billingOrder"`
+}
+
+// Codes of the errors reported for invalid people and credits.
+const (
+	CodeBirthYearInFuture = "birth_year_in_future"
+	CodeActorsOnly        = "actors_only"
+	CodeDuplicateCredit   = "duplicate_credit"
+	CodeUnknownPerson     = "unknown_person"
+)
+
+// ValidatePerson validates a person.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.CheckError(person.Name != "", "name", validator.Required())
+	v.CheckError(len(person.Name) <= 500, "name", validator.MaxLength(500))
+
+	if person.BirthYear != nil {
+		v.CheckError(*person.BirthYear >= 1800, "birthYear", validator.Min(1800))
+		v.CheckError(*person.BirthYear <= int32(time.Now().Year()), "birthYear", validator.FieldError{
+			Code:    CodeBirthYearInFuture,
+			Message: "must not be in the future",
+		})
+	}
+}
+
+// ValidateCredits validates the credits of a movie.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidateCredits(v *validator.Validator, credits []Credit) {
+	type key struct {
+		personID int64
+		role     string
+	}
+
+	seen := make(map[key]bool, len(credits))
+
+	validator.Each(v, "credits", credits, func(v *validator.Validator, _ int, credit Credit) {
+		v.CheckError(credit.PersonID > 0, "personId", validator.Required())
+		v.CheckError(validator.PermittedValue(credit.Role, RoleDirector, RoleActor, RoleWriter), "role",
+			validator.OneOf(RoleDirector, RoleActor, RoleWriter))
+		v.CheckError(credit.Character == "" || credit.Role == RoleActor, "character", validator.FieldError{
+			Code:    CodeActorsOnly,
+			Message: "must only be set for actors",
+		})
+		v.CheckError(len(credit.Character) <= 500, "character", validator.MaxLength(500))
+		v.CheckError(credit.BillingOrder >= 0, "billingOrder", validator.Min(0))
+		v.CheckError(!seen[key{credit.PersonID, credit.Role}], "", validator.FieldError{
+			Code:    CodeDuplicateCredit,
+			Message: "must not duplicate another credit",
+		})
+
+		seen[key{credit.PersonID, credit.Role}] = true
+	})
+}
+
+// PersonModel implements methods to query the database.
+type PersonModel struct {
+	DB *sqlx.DB
+}
+
+// Insert inserts a person in the DB.
+// Person.ID, Person.CreatedAt and Person.Version are set on the passed person.
+func (m PersonModel) Insert(person *Person) error {
+	query := `
+		INSERT INTO people (name, birth_year)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, person, query, person.Name, person.BirthYear)
+	if err != nil {
+		return fmt.Errorf("inserting person: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the person with the given id.
+func (m PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, birth_year, version
+		FROM people
+		WHERE id = $1`
+
+	var person Person
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &person, query, id)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying person: %w", err)
+	}
+
+	return &person, nil
+}
+
+// Update updates a person in the DB.
+// Person.Version is set on the passed person.
+func (m PersonModel) Update(person *Person) error {
+	query := `
+		UPDATE people
+		SET name = $1, birth_year = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING version`
+	args := []any{person.Name, person.BirthYear, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &person.Version, query, args...)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrEditConflict
+	case err != nil:
+		return fmt.Errorf("updating person: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a person and all their credits from the DB.
+func (m PersonModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM people WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("deleting person: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("counting affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAll returns a page of the people whose name matches, or all people if name is empty.
+func (m PersonModel) GetAll(name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER() AS total_records, id, created_at, name, birth_year, version
+		FROM people
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	args := []any{name, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("listing people: %w", err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	people := []*Person{}
+
+	for rows.Next() {
+		var person struct {
+			TotalRecords int `db:"total_records"`
+			Person
+		}
+
+		err = rows.StructScan(&person)
+		if err != nil {
+			return nil, Metadata{}, fmt.Errorf("scanning person: %w", err)
+		}
+
+		totalRecords = person.TotalRecords
+		people = append(people, &person.Person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, fmt.Errorf("iterating over rows: %w", err)
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return people, metadata, nil
+}
+
+// GetCreditsForMovies returns the credits of the given movies, indexed by movie ID.
+func (m PersonModel) GetCreditsForMovies(movieIDs ...int64) (map[int64][]Credit, error) {
+	query := `
+		SELECT c.movie_id, c.person_id, p.name, c.role, c.character, c.billing_order
+		FROM movie_credits AS c
+		JOIN people AS p ON p.id = c.person_id
+		WHERE c.movie_id = ANY($1)
+		ORDER BY c.movie_id, c.billing_order, c.role, p.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var credits []Credit
+
+	err := m.DB.SelectContext(ctx, &credits, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, fmt.Errorf("querying credits: %w", err)
+	}
+
+	byMovie := make(map[int64][]Credit, len(movieIDs))
+
+	for _, credit := range credits {
+		byMovie[credit.MovieID] = append(byMovie[credit.MovieID], credit)
+	}
+
+	return byMovie, nil
+}
+
+// GetCreditsForPerson returns the filmography of a person.
+// Credits on deleted movies are ignored.
+func (m PersonModel) GetCreditsForPerson(personID int64) ([]Credit, error) {
+	query := `
+		SELECT c.movie_id, m.title AS movie_title, c.person_id, c.role, c.character, c.billing_order
+		FROM movie_credits AS c
+		JOIN movies AS m ON m.id = c.movie_id
+		WHERE c.person_id = $1 AND m.deleted_at IS NULL
+		ORDER BY m.year DESC, m.id, c.role`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	credits := []Credit{}
+
+	err := m.DB.SelectContext(ctx, &credits, query, personID)
+	if err != nil {
+		return nil, fmt.Errorf("querying credits: %w", err)
+	}
+
+	return credits, nil
+}
+
+// SetCredits replaces all the credits of a movie.
+// ErrUnknownPerson is returned if a credit references a person that doesn't exist.
+func (m PersonModel) SetCredits(movieID int64, credits []Credit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM movie_credits WHERE movie_id = $1`, movieID); err != nil {
+		return fmt.Errorf("deleting credits: %w", err)
+	}
+
+	if len(credits) > 0 {
+		const columns = 5
+
+		placeholders := make([]string, 0, len(credits))
+		args := make([]any, 0, len(credits)*columns)
+
+		for i, credit := range credits {
+			placeholders = append(placeholders, valuesPlaceholder(i*columns, columns))
+			args = append(args, movieID, credit.PersonID, credit.Role, credit.Character, credit.BillingOrder)
+		}
+
+		query := `
+			INSERT INTO movie_credits (movie_id, person_id, role, character, billing_order)
+			VALUES ` + strings.Join(placeholders, ", ")
+
+		_, err = tx.ExecContext(ctx, query, args...)
+
+		var pqErr *pq.Error
+
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503": // foreign_key_violation
+			return ErrUnknownPerson
+		case err != nil:
+			return fmt.Errorf("inserting credits: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Codes of the errors reported for invalid poster images.
+const (
+	CodeImageFormat   = "image_format"
+	CodeImageInvalid  = "image_invalid"
+	CodeImageTooSmall = "image_too_small"
+	CodeImageTooLarge = "image_too_large"
+)
+
+// A Poster describes the poster image of a movie, as stored in the DB.
+// The image itself and its thumbnail live in a blob store under Key and ThumbnailKey.
+type Poster struct {
+	MovieID      int64     `db:"movie_id"      json:"movieId"`
+	UploadedAt   time.Time `db:"uploaded_at"   json:"uploadedAt"`
+	Key          string    `db:"key"           json:"-"`
+	ContentType  string    `db:"content_type"  json:"contentType"`
+	Size         int64     `db:"size"          json:"size"`
+	Width        int32     `db:"width"         json:"width"`
+	Height       int32     `db:"height"        json:"height"`
+	ThumbnailKey *string   `db:"thumbnail_key" json:"-"`
+
+	// URLs to download the images, set by the API.
+	URL          string `db:"-" json:"url"`
+	ThumbnailURL string `db:"-" json:"thumbnailUrl,omitempty"`
+}
+
+// PosterModel implements methods to query the database.
+type PosterModel struct {
+	DB *sqlx.DB
+}
+
+// Get returns the poster of a movie.
+func (m PosterModel) Get(movieID int64) (*Poster, error) {
+	query := `
+		SELECT p.movie_id, p.uploaded_at, p.key, p.content_type, p.size, p.width, p.height, p.thumbnail_key
+		FROM posters AS p
+		JOIN movies AS m ON m.id = p.movie_id
+		WHERE p.movie_id = $1 AND m.deleted_at IS NULL`
+
+	var poster Poster
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &poster, query, movieID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying poster: %w", err)
+	}
+
+	return &poster, nil
+}
+
+// Set sets the poster of a movie and returns the one it replaced, if any,
+// so that its blobs can be deleted.
+// Poster.UploadedAt is set on the passed poster.
+func (m PosterModel) Set(poster *Poster) (*Poster, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var previous *Poster
+
+	err := inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		var old Poster
+
+		query := `
+			SELECT movie_id, uploaded_at, key, content_type, size, width, height, thumbnail_key
+			FROM posters
+			WHERE movie_id = $1
+			FOR UPDATE`
+
+		err := tx.GetContext(ctx, &old, query, poster.MovieID)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return fmt.Errorf("querying poster: %w", err)
+		default:
+			previous = &old
+		}
+
+		query = `
+			INSERT INTO posters (movie_id, key, content_type, size, width, height)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (movie_id) DO UPDATE
+			SET uploaded_at = now(), key = EXCLUDED.key, content_type = EXCLUDED.content_type, size = EXCLUDED.size,
+				width = EXCLUDED.width, height = EXCLUDED.height, thumbnail_key = NULL
+			RETURNING uploaded_at`
+		args := []any{poster.MovieID, poster.Key, poster.ContentType, poster.Size, poster.Width, poster.Height}
+
+		if err = tx.GetContext(ctx, &poster.UploadedAt, query, args...); err != nil {
+			return fmt.Errorf("upserting poster: %w", err)
+		}
+
+		return nil
+	})
+
+	return previous, err
+}
+
+// SetThumbnail records the thumbnail generated for the poster stored under key.
+// ErrRecordNotFound is returned if the poster has been replaced or deleted in the meantime.
+func (m PosterModel) SetThumbnail(movieID int64, key, thumbnailKey string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	query := `UPDATE posters SET thumbnail_key = $1 WHERE movie_id = $2 AND key = $3`
+
+	res, err := m.DB.ExecContext(ctx, query, thumbnailKey, movieID, key)
+	if err != nil {
+		return fmt.Errorf("updating poster: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("counting affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes the poster of a movie and returns it, so that its blobs can be deleted.
+func (m PosterModel) Delete(movieID int64) (*Poster, error) {
+	query := `
+		DELETE FROM posters
+		WHERE movie_id = $1
+		RETURNING movie_id, uploaded_at, key, content_type, size, width, height, thumbnail_key`
+
+	var poster Poster
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &poster, query, movieID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("deleting poster: %w", err)
+	}
+
+	return &poster, nil
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// queryArgs collects the arguments of a query built dynamically.
+type queryArgs []any
+
+// add appends an argument and returns its placeholder.
+func (a *queryArgs) add(value any) string {
+	*a = append(*a, value)
+
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// valuesPlaceholder returns the placeholders of a row in a multi-row VALUES list,
+// e.g. ($5, $6, $7, $8) for offset 4 and 4 columns.
+func valuesPlaceholder(offset, columns int) string {
+	placeholders := make([]string, columns)
+
+	for i := range placeholders {
+		placeholders[i] = "$" + strconv.Itoa(offset+i+1)
+	}
+
+	return "(" + strings.Join(placeholders, ", ") + ")"
+}
+
+// inTx runs fn in a new transaction, which is committed if fn succeeds
+// and rolled back otherwise.
+func inTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
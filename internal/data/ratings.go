@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+)
+
+// A Rating is the score given by a user to a movie, as stored in the DB.
+type Rating struct {
+	MovieID   int64     `db:"movie_id"   json:"movieId"`
+	UserID    int64     `db:"user_id"    json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+	Rating    int32     `db:"rating"     json:"rating"`
+}
+
+// Bounds of the ratings users give to movies.
+const (
+	minRating = 1
+	maxRating = 10
+)
+
+// ValidateRating validates a rating.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidateRating(v *validator.Validator, rating *Rating) {
+	v.CheckError(rating.Rating >= minRating, "rating", validator.Min[int32](minRating))
+	v.CheckError(rating.Rating <= maxRating, "rating", validator.Max[int32](maxRating))
+}
+
+// RatingModel implements methods to query the database.
+type RatingModel struct {
+	DB *sqlx.DB
+}
+
+// Get returns the rating the user gave to the movie.
+func (m RatingModel) Get(movieID, userID int64) (*Rating, error) {
+	query := `
+		SELECT movie_id, user_id, created_at, updated_at, rating
+		FROM ratings
+		WHERE movie_id = $1 AND user_id = $2`
+
+	var rating Rating
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &rating, query, movieID, userID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying rating: %w", err)
+	}
+
+	return &rating, nil
+}
+
+// Set inserts or replaces the rating of a user, and updates the rating aggregate of the movie.
+// Rating.CreatedAt and Rating.UpdatedAt are set on the passed rating.
+func (m RatingModel) Set(rating *Rating) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		previous, err := lockRating(ctx, tx, rating.MovieID, rating.UserID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO ratings (movie_id, user_id, rating)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (movie_id, user_id) DO UPDATE SET rating = EXCLUDED.rating, updated_at = now()
+			RETURNING created_at, updated_at`
+
+		err = tx.GetContext(ctx, rating, query, rating.MovieID, rating.UserID, rating.Rating)
+		if err != nil {
+			return fmt.Errorf("upserting rating: %w", err)
+		}
+
+		count, sum := ratingDelta(previous, sql.NullInt64{Int64: int64(rating.Rating), Valid: true})
+
+		return updateRatingAggregate(ctx, tx, rating.MovieID, count, sum)
+	})
+}
+
+// Delete deletes the rating of a user, and updates the rating aggregate of the movie.
+func (m RatingModel) Delete(movieID, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		previous, err := lockRating(ctx, tx, movieID, userID)
+		if err != nil {
+			return err
+		}
+
+		if !previous.Valid {
+			return ErrRecordNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM ratings WHERE movie_id = $1 AND user_id = $2`, movieID, userID)
+		if err != nil {
+			return fmt.Errorf("deleting rating: %w", err)
+		}
+
+		count, sum := ratingDelta(previous, sql.NullInt64{})
+
+		return updateRatingAggregate(ctx, tx, movieID, count, sum)
+	})
+}
+
+// lockRating locks the movie so that its rating aggregate can be updated,
+// and returns the current rating of the user, if any.
+// ErrRecordNotFound is returned if the movie doesn't exist.
+func lockRating(ctx context.Context, tx *sqlx.Tx, movieID, userID int64) (sql.NullInt64, error) {
+	var previous sql.NullInt64
+
+	query := `
+		SELECT r.rating
+		FROM movies AS m
+		LEFT JOIN ratings AS r ON r.movie_id = m.id AND r.user_id = $2
+		WHERE m.id = $1 AND m.deleted_at IS NULL
+		FOR UPDATE OF m`
+
+	err := tx.GetContext(ctx, &previous, query, movieID, userID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return previous, ErrRecordNotFound
+	case err != nil:
+		return previous, fmt.Errorf("locking movie: %w", err)
+	}
+
+	return previous, nil
+}
+
+// ratingDelta returns the changes to the rating_count and rating_sum of a movie when the rating
+// of a user goes from previous to current, either being NULL when the user didn't rate the movie.
+// The average rating of the movie is derived from both columns.
+func ratingDelta(previous, current sql.NullInt64) (int, int64) {
+	count := 0
+
+	switch {
+	case current.Valid && !previous.Valid:
+		count = 1
+	case previous.Valid && !current.Valid:
+		count = -1
+	}
+
+	return count, current.Int64 - previous.Int64
+}
+
+func updateRatingAggregate(ctx context.Context, tx *sqlx.Tx, movieID int64, count int, sum int64) error {
+	query := `
+		UPDATE movies
+		SET rating_count = rating_count + $1, rating_sum = rating_sum + $2
+		WHERE id = $3`
+
+	if _, err := tx.ExecContext(ctx, query, count, sum, movieID); err != nil {
+		return fmt.Errorf("updating movie rating: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestValidateRating(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rating int32
+		valid  bool
+	}{
+		{-1, false},
+		{0, false},
+		{1, true},
+		{7, true},
+		{10, true},
+		{11, false},
+	}
+
+	for _, test := range tests {
+		v := validator.New()
+
+		if ValidateRating(v, &Rating{MovieID: 1, UserID: 1, Rating: test.rating}); v.Valid() != test.valid {
+			t.Errorf("rating %d: got valid %t want %t, errors %v", test.rating, v.Valid(), test.valid, v.Errors)
+		}
+	}
+}
+
+func TestRatingDelta(t *testing.T) {
+	t.Parallel()
+
+	rating := func(value int64) sql.NullInt64 {
+		return sql.NullInt64{Int64: value, Valid: true}
+	}
+
+	tests := []struct {
+		name              string
+		previous, current sql.NullInt64
+		wantCount         int
+		wantSum           int64
+	}{
+		{"first rating", sql.NullInt64{}, rating(8), 1, 8},
+		{"raised", rating(4), rating(9), 0, 5},
+		{"lowered", rating(9), rating(2), 0, -7},
+		{"unchanged", rating(6), rating(6), 0, 0},
+		{"deleted", rating(7), sql.NullInt64{}, -1, -7},
+	}
+
+	for _, test := range tests {
+		count, sum := ratingDelta(test.previous, test.current)
+		if count != test.wantCount || sum != test.wantSum {
+			t.Errorf("%s: got %d, %d want %d, %d", test.name, count, sum, test.wantCount, test.wantSum)
+		}
+	}
+}
+
+// TestRatingAverage replays ratings through ratingDelta, computing the average like the
+// generated rating column of movies: rating_sum / rating_count, or 0 without ratings.
+func TestRatingAverage(t *testing.T) {
+	t.Parallel()
+
+	var (
+		count int
+		sum   int64
+	)
+
+	ratings := map[int64]sql.NullInt64{}
+
+	average := func() float32 {
+		if count == 0 {
+			return 0
+		}
+
+		return float32(sum) / float32(count)
+	}
+
+	steps := []struct {
+		userID      int64
+		rating      sql.NullInt64
+		wantCount   int
+		wantAverage float32
+	}{
+		{1, sql.NullInt64{Int64: 8, Valid: true}, 1, 8},
+		{2, sql.NullInt64{Int64: 5, Valid: true}, 2, 6.5},
+		{1, sql.NullInt64{Int64: 2, Valid: true}, 2, 3.5},
+		{3, sql.NullInt64{Int64: 10, Valid: true}, 3, 17.0 / 3},
+		{2, sql.NullInt64{}, 2, 6},
+		{1, sql.NullInt64{}, 1, 10},
+		{3, sql.NullInt64{}, 0, 0},
+	}
+
+	for i, step := range steps {
+		deltaCount, deltaSum := ratingDelta(ratings[step.userID], step.rating)
+		count += deltaCount
+		sum += deltaSum
+		ratings[step.userID] = step.rating
+
+		if count != step.wantCount || average() != step.wantAverage {
+			t.Errorf("step %d: got count %d, average %g want %d, %g", i, count, average(), step.wantCount, step.wantAverage)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Limits of the precomputed recommendations.
+const (
+	// MaxSimilarMovies is the number of similar movies kept for each movie.
+	MaxSimilarMovies = 20
+	// MaxRecommendations is the number of recommendations kept for each user.
+	MaxRecommendations = 50
+)
+
+// Weights of the similarity criteria. They add up to 1, so similarity scores are between 0 and 1.
+const (
+	genreWeight   = 0.6
+	yearWeight    = 0.25
+	runtimeWeight = 0.15
+
+	// yearScale and runtimeScale are the year and runtime differences, in years
+	// and minutes, at which the corresponding criterion scores half its weight.
+	yearScale    = 10
+	runtimeScale = 30
+)
+
+// Ratings are centered on their midpoint and scaled to [-1, 1] when weighting
+// similar movies, so that low ratings push similar movies away.
+const (
+	ratingMidpoint  = (minRating + maxRating) / 2.0
+	ratingHalfRange = (maxRating - minRating) / 2.0
+)
+
+// Weights of the movies users listed without rating them. A movie in the watchlist
+// says more about the user's taste than one they watched and didn't bother to rate,
+// but less than a top rating.
+const (
+	watchlistWeight = 0.6
+	watchedWeight   = 0.3
+)
+
+// Users without rated or listed movies are recommended the movies similar to
+// the popularSeeds most rated movies, each weighing popularWeight.
+const (
+	popularSeeds  = 10
+	popularWeight = 0.2
+)
+
+// refreshTimeout bounds the duration of a refresh of the recommendations.
+const refreshTimeout = 10 * time.Minute
+
+// A ScoredMovie is a movie recommended with a score. Scores are only comparable
+// between movies returned by the same call.
+type ScoredMovie struct {
+	Movie
+	Score float64 `db:"score" json:"score"`
+}
+
+// ratingWeights returns the weight of each rating, indexed by rating minus minRating
+// so that it can be indexed by rating once sent as a 1-based SQL array.
+func ratingWeights() []float64 {
+	weights := make([]float64, 0, maxRating-minRating+1)
+
+	for rating := minRating; rating <= maxRating; rating++ {
+		weights = append(weights, (float64(rating)-ratingMidpoint)/ratingHalfRange)
+	}
+
+	return weights
+}
+
+// MarshalJSON implements json.Marshaler. Without it, the MarshalJSON method of
+// the movie would be promoted and drop the score.
+func (m ScoredMovie) MarshalJSON() ([]byte, error) {
+	if m.defaultRuntimeFormat() {
+		return json.Marshal(struct { //nolint:wrapcheck
+			movie
+			Score float64 `json:"score"`
+		}{movie(m.Movie), m.Score})
+	}
+
+	return json.Marshal(struct { //nolint:wrapcheck
+		formattedMovie
+		Score float64 `json:"score"`
+	}{m.formatted(), m.Score})
+}
+
+// RecommendationModel implements methods to query the database.
+type RecommendationModel struct {
+	DB *sqlx.DB
+}
+
+// Similar returns up to limit movies similar to the given movie, most similar first.
+func (m RecommendationModel) Similar(movieID int64, limit int) ([]*ScoredMovie, error) {
+	query := `
+		SELECT s.score, m.id, m.created_at, m.title, m.year, m.runtime, m.genres, m.version,
+			m.rating, m.rating_count
+		FROM movie_similarities AS s
+		JOIN movies AS m ON m.id = s.similar_id
+		WHERE s.movie_id = $1 AND m.deleted_at IS NULL
+		ORDER BY s.score DESC, m.id
+		LIMIT $2`
+
+	movies := []*ScoredMovie{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &movies, query, movieID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying similar movies: %w", err)
+	}
+
+	return movies, nil
+}
+
+// ForUser returns up to limit movies recommended to the user, best first.
+// Movies in the user's watched list are left out.
+func (m RecommendationModel) ForUser(userID int64, limit int) ([]*ScoredMovie, error) {
+	query := `
+		SELECT r.score, m.id, m.created_at, m.title, m.year, m.runtime, m.genres, m.version,
+			m.rating, m.rating_count
+		FROM user_recommendations AS r
+		JOIN movies AS m ON m.id = r.movie_id
+		WHERE r.user_id = $1 AND m.deleted_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1
+			FROM list_items AS i
+			JOIN lists AS l ON l.id = i.list_id
+			WHERE l.user_id = r.user_id AND l.kind = 'watched' AND i.movie_id = m.id)
+		ORDER BY r.score DESC, m.id
+		LIMIT $2`
+
+	movies := []*ScoredMovie{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &movies, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying recommendations: %w", err)
+	}
+
+	return movies, nil
+}
+
+// Refresh recomputes the similar movies of every movie, and the recommendations of
+// every activated user. It reports whether the refresh ran: it's skipped when
+// another one, possibly from another server, is in progress.
+//
+// Movies are similar when they share at least one genre. Their similarity combines
+// the Jaccard index of their genres with the proximity of their years and runtimes.
+// Users are recommended the movies most similar to the ones they rated, weighted by
+// their ratings, or put in their watchlist or watched list, leaving out these movies.
+// Users who did neither are recommended the movies similar to the most rated ones.
+func (m RecommendationModel) Refresh() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	refreshed := false
+
+	err := inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &refreshed, `SELECT pg_try_advisory_xact_lock(hashtext('refresh_recommendations'))`)
+		if err != nil {
+			return fmt.Errorf("locking recommendations: %w", err)
+		}
+
+		if !refreshed {
+			return nil
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM user_recommendations`); err != nil {
+			return fmt.Errorf("deleting recommendations: %w", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM movie_similarities`); err != nil {
+			return fmt.Errorf("deleting similar movies: %w", err)
+		}
+
+		query := `
+			WITH scored AS (
+				SELECT a.id AS movie_id, b.id AS similar_id,
+					$1 * genre_jaccard(a.genres, b.genres)
+					+ $2 / (1 + abs(a.year - b.year) / $4::double precision)
+					+ $3 / (1 + abs(a.runtime - b.runtime) / $5::double precision) AS score
+				FROM movies AS a
+				JOIN movies AS b ON b.genres && a.genres AND b.id <> a.id AND b.deleted_at IS NULL
+				WHERE a.deleted_at IS NULL
+			), ranked AS (
+				SELECT movie_id, similar_id, score,
+					row_number() OVER (PARTITION BY movie_id ORDER BY score DESC, similar_id) AS rank
+				FROM scored
+			)
+			INSERT INTO movie_similarities (movie_id, similar_id, score)
+			SELECT movie_id, similar_id, score
+			FROM ranked
+			WHERE rank <= $6`
+
+		_, err = tx.ExecContext(ctx, query,
+			genreWeight, yearWeight, runtimeWeight, yearScale, runtimeScale, MaxSimilarMovies)
+		if err != nil {
+			return fmt.Errorf("computing similar movies: %w", err)
+		}
+
+		// A movie both rated and listed weighs its rating, and a movie in both lists
+		// weighs as watched.
+		query = `
+			WITH seeds AS (
+				SELECT DISTINCT ON (user_id, movie_id) user_id, movie_id, weight
+				FROM (
+					SELECT user_id, movie_id, ($1::double precision[])[rating - $2 + 1] AS weight, 1 AS priority
+					FROM ratings
+					UNION ALL
+					SELECT l.user_id, i.movie_id,
+						CASE l.kind WHEN 'watched' THEN $3::double precision ELSE $4::double precision END,
+						CASE l.kind WHEN 'watched' THEN 2 ELSE 3 END
+					FROM list_items AS i
+					JOIN lists AS l ON l.id = i.list_id
+					WHERE l.kind IN ('watched', 'watchlist')
+				) AS s
+				ORDER BY user_id, movie_id, priority
+			), popular AS (
+				SELECT u.id AS user_id, p.id AS movie_id, $5::double precision AS weight
+				FROM users AS u
+				CROSS JOIN (
+					SELECT id
+					FROM movies
+					WHERE deleted_at IS NULL
+					ORDER BY rating_count DESC, rating DESC, id
+					LIMIT $6
+				) AS p
+				WHERE u.activated AND NOT EXISTS (SELECT 1 FROM seeds WHERE seeds.user_id = u.id)
+			), scored AS (
+				SELECT seed.user_id, s.similar_id AS movie_id, sum(s.score * seed.weight) AS score
+				FROM (SELECT * FROM seeds UNION ALL SELECT * FROM popular) AS seed
+				JOIN movie_similarities AS s ON s.movie_id = seed.movie_id
+				WHERE NOT EXISTS (
+					SELECT 1
+					FROM seeds AS seen
+					WHERE seen.user_id = seed.user_id AND seen.movie_id = s.similar_id)
+				GROUP BY seed.user_id, s.similar_id
+			), ranked AS (
+				SELECT user_id, movie_id, score,
+					row_number() OVER (PARTITION BY user_id ORDER BY score DESC, movie_id) AS rank
+				FROM scored
+				WHERE score > 0
+			)
+			INSERT INTO user_recommendations (user_id, movie_id, score)
+			SELECT user_id, movie_id, score
+			FROM ranked
+			WHERE rank <= $7`
+
+		_, err = tx.ExecContext(ctx, query, pq.Array(ratingWeights()), minRating, watchedWeight, watchlistWeight,
+			popularWeight, popularSeeds, MaxRecommendations)
+		if err != nil {
+			return fmt.Errorf("computing recommendations: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return refreshed, nil
+}
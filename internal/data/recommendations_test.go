@@ -0,0 +1,87 @@
+package data
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestSimilarityWeights(t *testing.T) {
+	t.Parallel()
+
+	if sum := genreWeight + yearWeight + runtimeWeight; math.Abs(sum-1) > 1e-9 {
+		t.Errorf("got similarity weights adding up to %g want 1", sum)
+	}
+}
+
+func TestRatingWeights(t *testing.T) {
+	t.Parallel()
+
+	weights := ratingWeights()
+
+	if len(weights) != maxRating-minRating+1 {
+		t.Fatalf("got %d weights want one per rating", len(weights))
+	}
+
+	if weights[0] != -1 || weights[len(weights)-1] != 1 {
+		t.Errorf("got weights from %g to %g want -1 to 1", weights[0], weights[len(weights)-1])
+	}
+
+	for i := 1; i < len(weights); i++ {
+		if weights[i] <= weights[i-1] {
+			t.Errorf("got weight %g for rating %d, not more than %g for the rating below", weights[i], i+minRating, weights[i-1])
+		}
+
+		if -weights[i] != weights[len(weights)-1-i] {
+			t.Errorf("got weight %g for rating %d, not opposite to %g", weights[i], i+minRating, weights[len(weights)-1-i])
+		}
+	}
+}
+
+func TestSeedWeights(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		weight, weaker float64
+	}{
+		{name: "top rating above watchlist", weight: ratingWeights()[maxRating-minRating], weaker: watchlistWeight},
+		{name: "watchlist above watched", weight: watchlistWeight, weaker: watchedWeight},
+		{name: "watched above popular", weight: watchedWeight, weaker: popularWeight},
+		{name: "popular above neutral", weight: popularWeight},
+	}
+
+	for _, test := range tests {
+		if test.weight <= test.weaker {
+			t.Errorf("%s: got %g, not more than %g", test.name, test.weight, test.weaker)
+		}
+	}
+}
+
+func TestScoredMovieMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format RuntimeFormat
+		want   string
+	}{
+		{"", `{"id":1,"title":"Arrival","year":2016,"runtime":"116 mins","version":1,"score":0.5}`},
+		{RuntimeFormatHours, `{"id":1,"title":"Arrival","year":2016,"version":1,"runtime":"1h 56m","score":0.5}`},
+	}
+
+	for _, test := range tests {
+		movie := &ScoredMovie{
+			Movie: Movie{ID: 1, Title: "Arrival", Year: 2016, Runtime: 116, Version: 1, RuntimeFormat: test.format},
+			Score: 0.5,
+		}
+
+		got, err := json.Marshal(movie)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(got) != test.want {
+			t.Errorf("%q: got %s want %s", test.format, got, test.want)
+		}
+	}
+}
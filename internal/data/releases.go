@@ -0,0 +1,320 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Kinds of release events.
+const (
+	ReleaseTheatrical = "theatrical"
+	ReleaseStreaming  = "streaming"
+	ReleasePhysical   = "physical"
+)
+
+// DateLayout is the layout of dates in JSON and query strings.
+const DateLayout = time.DateOnly
+
+// ErrInvalidDateFormat is returned when unmarshaling JSON.
+// The expected format is "YYYY-MM-DD".
+var ErrInvalidDateFormat = errors.New("invalid date format")
+
+// InvalidDate returns the error of dates not formatted as "YYYY-MM-DD".
+func InvalidDate() validator.FieldError {
+	return validator.FieldError{Code: CodeDateFormat, Message: "must be a date formatted as YYYY-MM-DD"}
+}
+
+// InvalidCountryCode returns the error of values that aren't ISO 3166-1 alpha-2 country codes.
+func InvalidCountryCode() validator.FieldError {
+	return validator.FieldError{Code: CodeCountryCode, Message: "must be an uppercase ISO 3166-1 alpha-2 country code"}
+}
+
+// CountryRX matches ISO 3166-1 alpha-2 country codes.
+var CountryRX = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Certifications lists the age ratings known for each country.
+// Releases in other countries can't have a certification.
+var Certifications = map[string][]string{
+	"AU": {"G", "PG", "M", "MA15+", "R18+", "X18+"},
+	"BR": {"L", "10", "12", "14", "16", "18"},
+	"CA": {"G", "PG", "14A", "18A", "R", "A"},
+	"DE": {"FSK 0", "FSK 6", "FSK 12", "FSK 16", "FSK 18"},
+	"ES": {"A", "7", "12", "16", "18", "X"},
+	"FR": {"TP", "12", "16", "18"},
+	"GB": {"U", "PG", "12A", "12", "15", "18", "R18"},
+	"IT": {"T", "6+", "14+", "18+"},
+	"JP": {"G", "PG12", "R15+", "R18+"},
+	"US": {"G", "PG", "PG-13", "R", "NC-17"},
+}
+
+// Date is a calendar date, without time of day.
+type Date struct {
+	time.Time
+}
+
+// ParseDate parses a date formatted as "YYYY-MM-DD".
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, ErrInvalidDateFormat
+	}
+
+	return Date{t}, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.Format(DateLayout))), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	*d, err = ParseDate(unquotedJSONValue)
+
+	return err
+}
+
+// MarshalText implements encoding.TextMarshaler, for encodings other than JSON.
+// It overrides the method of the embedded time.Time, which includes the time of day.
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.Format(DateLayout)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Date) UnmarshalText(text []byte) error {
+	date, err := ParseDate(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = date
+
+	return nil
+}
+
+// Scan implements sql.Scanner.
+func (d *Date) Scan(src any) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("%w: scanning %T", ErrInvalidDateFormat, src)
+	}
+
+	d.Time = t
+
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (d Date) Value() (driver.Value, error) {
+	return d.Format(DateLayout), nil
+}
+
+// A Release is the release of a movie in a country, through a given channel.
+type Release struct {
+	MovieID       int64  `db:"movie_id"      json:"-"`
+	Country       string `db:"country"       json:"country"`
+	Kind          string `db:"kind"          json:"kind"`
+	Date          Date   `db:"date"          json:"date"`
+	Certification string `db:"certification" json:"certification,omitempty"`
+}
+
+// firstReleaseYear returns the year of the earliest of the releases, or 0 if there are none.
+func firstReleaseYear(releases []Release) int32 {
+	var year int32
+
+	for _, release := range releases {
+		if y := int32(release.Date.Year()); year == 0 || y < year {
+			year = y
+		}
+	}
+
+	return year
+}
+
+// Codes of the errors reported for invalid releases.
+const (
+	CodeDateFormat       = "date_format"
+	CodeCountryCode      = "country_code"
+	CodeReleaseTooEarly  = "release_too_early"
+	CodeNoCertifications = "no_certifications"
+	CodeDuplicateRelease = "duplicate_release"
+)
+
+// ValidateReleases validates the releases of a movie.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidateReleases(v *validator.Validator, releases []Release) {
+	type key struct {
+		country string
+		kind    string
+	}
+
+	v.CheckError(len(releases) <= 500, "releases", validator.MaxItems(500))
+
+	seen := make(map[key]bool, len(releases))
+
+	validator.Each(v, "releases", releases, func(v *validator.Validator, _ int, release Release) {
+		v.CheckError(validator.Matches(release.Country, CountryRX), "country", InvalidCountryCode())
+		v.CheckError(validator.PermittedValue(release.Kind, ReleaseTheatrical, ReleaseStreaming, ReleasePhysical),
+			"kind", validator.OneOf(ReleaseTheatrical, ReleaseStreaming, ReleasePhysical))
+		v.CheckError(!release.Date.IsZero(), "date", validator.Required())
+		v.CheckError(release.Date.IsZero() || release.Date.Year() >= 1888, "date", validator.FieldError{
+			Code:    CodeReleaseTooEarly,
+			Message: "must be after 1888",
+			Params:  map[string]any{"year": 1888},
+		})
+
+		if release.Certification != "" {
+			certifications, known := Certifications[release.Country]
+
+			v.CheckError(known, "certification", validator.FieldError{
+				Code:    CodeNoCertifications,
+				Message: "must not be set, no certifications are known for this country",
+			})
+			v.CheckError(!known || validator.PermittedValue(release.Certification, certifications...), "certification",
+				validator.OneOf(certifications...))
+		}
+
+		v.CheckError(!seen[key{release.Country, release.Kind}], "", validator.FieldError{
+			Code:    CodeDuplicateRelease,
+			Message: "must not duplicate another release",
+		})
+
+		seen[key{release.Country, release.Kind}] = true
+	})
+}
+
+// ReleaseModel implements methods to query the database.
+type ReleaseModel struct {
+	DB *sqlx.DB
+}
+
+// GetForMovies returns the releases of the given movies, keyed by movie ID
+// and sorted by date.
+func (m ReleaseModel) GetForMovies(ids ...int64) (map[int64][]Release, error) {
+	query := `
+		SELECT movie_id, country, kind, date, certification
+		FROM releases
+		WHERE movie_id = ANY($1)
+		ORDER BY date, country, kind`
+
+	var releases []Release
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &releases, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("querying releases: %w", err)
+	}
+
+	byMovie := make(map[int64][]Release, len(ids))
+
+	for _, release := range releases {
+		byMovie[release.MovieID] = append(byMovie[release.MovieID], release)
+	}
+
+	return byMovie, nil
+}
+
+// Set replaces the releases of a movie and records its new revision, attributed to the given user.
+// The movie must still be at the version of the passed movie, ErrEditConflict is returned otherwise.
+// ErrRecordNotFound is returned if the movie doesn't exist, and ErrYearInFuture if its year is
+// in the future and after the first of the releases.
+// Movie.Version and Movie.Releases are set on the passed movie.
+func (m ReleaseModel) Set(movie *Movie, releases []Release, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		// Locking the movie serializes concurrent replacements, and prevents its year
+		// from changing while it's checked against the releases.
+		var locked Movie
+
+		err := tx.GetContext(ctx, &locked, `
+			SELECT id, created_at, title, year, runtime, genres, version
+			FROM movies
+			WHERE id = $1 AND deleted_at IS NULL
+			FOR UPDATE`, movie.ID)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case err != nil:
+			return fmt.Errorf("locking movie: %w", err)
+		case locked.Version != movie.Version:
+			return ErrEditConflict
+		case !yearAllowed(locked.Year, releases):
+			return ErrYearInFuture
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM releases WHERE movie_id = $1`, movie.ID); err != nil {
+			return fmt.Errorf("deleting releases: %w", err)
+		}
+
+		if err = insertReleases(ctx, tx, movie.ID, releases); err != nil {
+			return err
+		}
+
+		err = tx.GetContext(ctx, &locked.Version,
+			`UPDATE movies SET version = version + 1 WHERE id = $1 RETURNING version`, movie.ID)
+		if err != nil {
+			return fmt.Errorf("updating movie version: %w", err)
+		}
+
+		if err = insertRevisions(ctx, tx, userID, &locked); err != nil {
+			return err
+		}
+
+		movie.Version = locked.Version
+		movie.Releases = releases
+
+		return nil
+	})
+}
+
+// insertReleases inserts the releases of a movie.
+func insertReleases(ctx context.Context, tx *sqlx.Tx, movieID int64, releases []Release) error {
+	if len(releases) == 0 {
+		return nil
+	}
+
+	const columns = 5
+
+	placeholders := make([]string, 0, len(releases))
+	args := make([]any, 0, len(releases)*columns)
+
+	for i, release := range releases {
+		placeholders = append(placeholders, valuesPlaceholder(i*columns, columns))
+		args = append(args, movieID, release.Country, release.Kind, release.Date, release.Certification)
+	}
+
+	query := `
+		INSERT INTO releases (movie_id, country, kind, date, certification)
+		VALUES ` + strings.Join(placeholders, ", ")
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("inserting releases: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+)
+
+func TestValidateReleases(t *testing.T) {
+	t.Parallel()
+
+	date := Date{Time: time.Date(2001, time.April, 25, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name       string
+		releases   []Release
+		wantErrors []string
+	}{
+		{"valid", []Release{
+			{Country: "FR", Kind: ReleaseTheatrical, Date: date, Certification: "TP"},
+			{Country: "DE", Kind: ReleaseTheatrical, Date: date, Certification: "FSK 12"},
+			{Country: "FR", Kind: ReleaseStreaming, Date: date},
+			{Country: "BE", Kind: ReleasePhysical, Date: date},
+		}, nil},
+		{"invalid fields", []Release{
+			{Country: "fr", Kind: "vhs"},
+		}, []string{"releases[0].country", "releases[0].kind", "releases[0].date"}},
+		{"certifications", []Release{
+			{Country: "US", Kind: ReleaseTheatrical, Date: date, Certification: "FSK 12"},
+			{Country: "BE", Kind: ReleaseTheatrical, Date: date, Certification: "12"},
+		}, []string{"releases[0].certification", "releases[1].certification"}},
+		{"duplicate", []Release{
+			{Country: "FR", Kind: ReleaseTheatrical, Date: date},
+			{Country: "FR", Kind: ReleaseTheatrical, Date: date},
+		}, []string{"releases[1]"}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			validate := validator.New()
+			ValidateReleases(validate, test.releases)
+
+			if len(validate.Errors) != len(test.wantErrors) {
+				t.Errorf("got errors %v want keys %v", validate.Errors, test.wantErrors)
+			}
+
+			for _, key := range test.wantErrors {
+				if _, found := validate.Errors[validator.Pointer(key)]; !found {
+					t.Errorf("missing error for %q in %v", key, validate.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMovieUpcomingYear(t *testing.T) {
+	t.Parallel()
+
+	nextYear := time.Now().Year() + 1
+	release := Release{
+		Country: "US",
+		Kind:    ReleaseTheatrical,
+		Date:    Date{Time: time.Date(nextYear, time.June, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name      string
+		year      int
+		releases  []Release
+		wantError bool
+	}{
+		{"past", 2001, nil, false},
+		{"future without releases", nextYear, nil, true},
+		{"future with a release that year", nextYear, []Release{release}, false},
+		{"after the first release", nextYear + 1, []Release{release}, true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			movie := &Movie{
+				Title:    "Dune: Part Three",
+				Year:     int32(test.year),
+				Runtime:  150,
+				Genres:   []string{"Drama"},
+				Releases: test.releases,
+			}
+			validate := validator.New()
+			ValidateMovie(validate, movie, GenreVocabulary{"drama": "Drama"})
+
+			if _, found := validate.Errors["/year"]; found != test.wantError {
+				t.Errorf("got errors %v want year error: %t", validate.Errors, test.wantError)
+			}
+		})
+	}
+}
+
+func TestYearAllowed(t *testing.T) {
+	t.Parallel()
+
+	thisYear := int32(time.Now().Year())
+
+	release := func(year int32) Release {
+		return Release{Date: Date{time.Date(int(year), time.March, 1, 0, 0, 0, 0, time.UTC)}}
+	}
+
+	tests := []struct {
+		name     string
+		year     int32
+		releases []Release
+		want     bool
+	}{
+		{name: "past", year: 1999, want: true},
+		{name: "this year", year: thisYear, want: true},
+		{name: "next year", year: thisYear + 1},
+		{name: "next year released", year: thisYear + 1, releases: []Release{release(thisYear + 1)}, want: true},
+		{name: "after first release", year: thisYear + 2, releases: []Release{release(thisYear + 2), release(thisYear + 1)}},
+		{name: "past with later releases", year: 1999, releases: []Release{release(thisYear + 1)}, want: true},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := yearAllowed(test.year, test.releases); got != test.want {
+				t.Errorf("got %t want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Moderation states of a review. Hidden reviews are only visible to their author and moderators.
+const (
+	ReviewPublished = "published"
+	ReviewHidden    = "hidden"
+)
+
+// ErrDuplicateReview is returned when a user reviews a movie they already reviewed.
+var ErrDuplicateReview = errors.New("duplicate review")
+
+// CodeAlreadyReviewed is the code of the error reported when a user reviews a movie twice.
+const CodeAlreadyReviewed = "already_reviewed"
+
+// A Review is the opinion of a user on a movie, as stored in the DB.
+type Review struct {
+	ID          int64      `db:"id"           json:"id"`
+	MovieID     int64      `db:"movie_id"     json:"movieId"`
+	UserID      int64      `db:"user_id"      json:"userId"`
+	CreatedAt   time.Time  `db:"created_at"   json:"createdAt"`
+	UpdatedAt   time.Time  `db:"updated_at"   json:"updatedAt"`
+	Body        string     `db:"body"         json:"body"`
+	Status      string     `db:"status"       json:"status"`
+	ModeratedBy *int64     `db:"moderated_by" json:"moderatedBy,omitempty"`
+	ModeratedAt *time.Time `db:"moderated_at" json:"moderatedAt,omitempty"`
+	Version     int32      `db:"version"      json:"version"`
+}
+
+// A ReviewRevision is the body of a review at a given version.
+type ReviewRevision struct {
+	ReviewID  int64     `db:"review_id"  json:"reviewId"`
+	Version   int32     `db:"version"    json:"version"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	Body      string    `db:"body"       json:"body"`
+}
+
+// ValidateReview validates a review.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.CheckError(review.Body != "", "body", validator.Required())
+	v.CheckError(len(review.Body) <= 10_000, "body", validator.MaxLength(10_000))
+	v.CheckError(validator.PermittedValue(review.Status, ReviewPublished, ReviewHidden), "status",
+		validator.OneOf(ReviewPublished, ReviewHidden))
+}
+
+// ReviewModel implements methods to query the database.
+type ReviewModel struct {
+	DB *sqlx.DB
+}
+
+const reviewColumns = `id, movie_id, user_id, created_at, updated_at, body, status, moderated_by, moderated_at, version`
+
+// Insert inserts a review in the DB and records its first revision.
+// Review.ID, Review.CreatedAt, Review.UpdatedAt, Review.Status and Review.Version
+// are set on the passed review.
+// ErrDuplicateReview is returned if the user already reviewed the movie.
+func (m ReviewModel) Insert(review *Review) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		query := `
+			INSERT INTO reviews (movie_id, user_id, body)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at, updated_at, status, version`
+
+		err := tx.GetContext(ctx, review, query, review.MovieID, review.UserID, review.Body)
+
+		var pqErr *pq.Error
+
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505": // unique_violation
+			return ErrDuplicateReview
+		case err != nil:
+			return fmt.Errorf("inserting review: %w", err)
+		}
+
+		return insertReviewRevision(ctx, tx, review)
+	})
+}
+
+// Get returns the review of the movie with the given id.
+func (m ReviewModel) Get(movieID, id int64) (*Review, error) {
+	if movieID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + reviewColumns + `
+		FROM reviews
+		WHERE id = $1 AND movie_id = $2
+		AND EXISTS (SELECT 1 FROM movies WHERE movies.id = reviews.movie_id AND movies.deleted_at IS NULL)`
+
+	var review Review
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &review, query, id, movieID)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying review: %w", err)
+	}
+
+	return &review, nil
+}
+
+// GetAllForMovie returns a page of the reviews of a movie with the given status,
+// or with any status if status is empty.
+func (m ReviewModel) GetAllForMovie(movieID int64, status string, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER() AS total_records, %s
+		FROM reviews
+		WHERE movie_id = $1 AND (status = $2 OR $2 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4`, reviewColumns, filters.sortColumn(), filters.sortDirection())
+	args := []any{movieID, status, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("listing reviews: %w", err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review struct {
+			TotalRecords int `db:"total_records"`
+			Review
+		}
+
+		err = rows.StructScan(&review)
+		if err != nil {
+			return nil, Metadata{}, fmt.Errorf("scanning review: %w", err)
+		}
+
+		totalRecords = review.TotalRecords
+		reviews = append(reviews, &review.Review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, fmt.Errorf("iterating over rows: %w", err)
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reviews, metadata, nil
+}
+
+// Update updates the body of a review and records the new revision.
+// Review.UpdatedAt and Review.Version are set on the passed review.
+func (m ReviewModel) Update(review *Review) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx *sqlx.Tx) error {
+		query := `
+			UPDATE reviews
+			SET body = $1, updated_at = now(), version = version + 1
+			WHERE id = $2 AND version = $3
+			RETURNING updated_at, version`
+
+		err := tx.GetContext(ctx, review, query, review.Body, review.ID, review.Version)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case err != nil:
+			return fmt.Errorf("updating review: %w", err)
+		}
+
+		return insertReviewRevision(ctx, tx, review)
+	})
+}
+
+// Moderate sets the status of a review on behalf of the given moderator.
+// Review.ModeratedBy, Review.ModeratedAt and Review.Version are set on the passed review.
+func (m ReviewModel) Moderate(review *Review, moderatorID int64) error {
+	query := `
+		UPDATE reviews
+		SET status = $1, moderated_by = $2, moderated_at = now(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING moderated_by, moderated_at, version`
+	args := []any{review.Status, moderatorID, review.ID, review.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, review, query, args...)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrEditConflict
+	case err != nil:
+		return fmt.Errorf("moderating review: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a review and its history from the DB.
+func (m ReviewModel) Delete(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, `DELETE FROM reviews WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting review: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("counting affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetRevisions returns the edit history of a review, most recent first.
+func (m ReviewModel) GetRevisions(id int64) ([]ReviewRevision, error) {
+	query := `
+		SELECT review_id, version, created_at, body
+		FROM review_revisions
+		WHERE review_id = $1
+		ORDER BY version DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	revisions := []ReviewRevision{}
+
+	err := m.DB.SelectContext(ctx, &revisions, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("querying review revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func insertReviewRevision(ctx context.Context, tx *sqlx.Tx, review *Review) error {
+	query := `
+		INSERT INTO review_revisions (review_id, version, body)
+		VALUES ($1, $2, $3)`
+
+	if _, err := tx.ExecContext(ctx, query, review.ID, review.Version, review.Body); err != nil {
+		return fmt.Errorf("inserting review revision: %w", err)
+	}
+
+	return nil
+}
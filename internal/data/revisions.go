@@ -0,0 +1,217 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// A MovieRevision is a snapshot of a movie at a given version.
+type MovieRevision struct {
+	MovieID   int64          `db:"movie_id"   json:"movieId"`
+	Version   int32          `db:"version"    json:"version"`
+	CreatedAt time.Time      `db:"created_at" json:"createdAt"`
+	UserID    *int64         `db:"user_id"    json:"userId,omitempty"`
+	Title     string         `db:"title"      json:"title"`
+	Year      int32          `db:"year"       json:"year"`
+	Runtime   Runtime        `db:"runtime"    json:"runtime"`
+	Genres    pq.StringArray `db:"genres"     json:"genres"`
+
+	// RuntimeFormat is the format of the runtime sent to the client.
+	RuntimeFormat RuntimeFormat `db:"-" json:"-"`
+}
+
+// movieRevision has the fields of MovieRevision, without its MarshalJSON method.
+type movieRevision MovieRevision
+
+// MarshalJSON implements json.Marshaler, sending the runtime in the requested format.
+func (r MovieRevision) MarshalJSON() ([]byte, error) {
+	if r.RuntimeFormat.isDefault() {
+		return json.Marshal(movieRevision(r)) //nolint:wrapcheck
+	}
+
+	return json.Marshal(struct { //nolint:wrapcheck
+		movieRevision
+		Runtime any `json:"runtime"`
+	}{movieRevision(r), r.Runtime.Formatted(r.RuntimeFormat)})
+}
+
+// Apply copies the snapshot held by the revision onto the movie.
+// The movie's ID and version are left untouched.
+func (r *MovieRevision) Apply(movie *Movie) {
+	movie.Title = r.Title
+	movie.Year = r.Year
+	movie.Runtime = r.Runtime
+	movie.Genres = slices.Clone(r.Genres)
+}
+
+// A FieldChange describes the change of a single field between two revisions.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffRevisions returns the fields that changed between from and to.
+// The returned slice is empty when both snapshots are identical.
+// Genres are a set: reordering them isn't a change.
+func DiffRevisions(from, to *MovieRevision) []FieldChange {
+	changes := []FieldChange{}
+
+	if from.Title != to.Title {
+		changes = append(changes, FieldChange{Field: "title", From: from.Title, To: to.Title})
+	}
+
+	if from.Year != to.Year {
+		changes = append(changes, FieldChange{Field: "year", From: from.Year, To: to.Year})
+	}
+
+	if from.Runtime != to.Runtime {
+		changes = append(changes, FieldChange{Field: "runtime", From: from.Runtime, To: to.Runtime})
+	}
+
+	if !sameGenres(from.Genres, to.Genres) {
+		changes = append(changes, FieldChange{Field: "genres", From: from.Genres, To: to.Genres})
+	}
+
+	return changes
+}
+
+func sameGenres(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(a, b)
+}
+
+// insertRevisions records the current state of the movies as new revisions.
+// It's meant to be called in the same transaction as the write to the movies table.
+func insertRevisions(ctx context.Context, tx *sqlx.Tx, userID int64, movies ...*Movie) error {
+	query, args := revisionsInsert(userID, movies...)
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("inserting movie revisions: %w", err)
+	}
+
+	return nil
+}
+
+// revisionsInsert returns the query inserting the snapshots of the movies, and its arguments.
+// Revisions recorded without a user have a NULL user_id.
+func revisionsInsert(userID int64, movies ...*Movie) (string, []any) {
+	const columns = 7
+
+	placeholders := make([]string, 0, len(movies))
+	args := make([]any, 0, len(movies)*columns)
+
+	for i, movie := range movies {
+		placeholders = append(placeholders, valuesPlaceholder(i*columns, columns))
+		args = append(args,
+			movie.ID,
+			movie.Version,
+			sql.NullInt64{Int64: userID, Valid: userID > 0},
+			movie.Title,
+			movie.Year,
+			movie.Runtime,
+			pq.Array(movie.Genres),
+		)
+	}
+
+	query := `
+		INSERT INTO movie_revisions (movie_id, version, user_id, title, year, runtime, genres)
+		VALUES ` + strings.Join(placeholders, ", ")
+
+	return query, args
+}
+
+// MovieRevisionModel implements methods to query the database.
+type MovieRevisionModel struct {
+	DB *sqlx.DB
+}
+
+// Get returns the revision of the movie at the given version.
+func (m MovieRevisionModel) Get(movieID int64, version int32) (*MovieRevision, error) {
+	if movieID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT r.movie_id, r.version, r.created_at, r.user_id, r.title, r.year, r.runtime, r.genres
+		FROM movie_revisions AS r
+		JOIN movies AS m ON m.id = r.movie_id
+		WHERE r.movie_id = $1 AND r.version = $2 AND m.deleted_at IS NULL`
+
+	var revision MovieRevision
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &revision, query, movieID, version)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrRecordNotFound
+	case err != nil:
+		return nil, fmt.Errorf("querying movie revision: %w", err)
+	}
+
+	return &revision, nil
+}
+
+// GetAllForMovie returns a page of the revisions of a movie.
+func (m MovieRevisionModel) GetAllForMovie(movieID int64, filters Filters) ([]*MovieRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER() AS total_records,
+			r.movie_id, r.version, r.created_at, r.user_id, r.title, r.year, r.runtime, r.genres
+		FROM movie_revisions AS r
+		JOIN movies AS m ON m.id = r.movie_id
+		WHERE r.movie_id = $1 AND m.deleted_at IS NULL
+		ORDER BY r.%s %s
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	args := []any{movieID, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("listing movie revisions: %w", err)
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*MovieRevision{}
+
+	for rows.Next() {
+		var revision struct {
+			TotalRecords int `db:"total_records"`
+			MovieRevision
+		}
+
+		err = rows.StructScan(&revision)
+		if err != nil {
+			return nil, Metadata{}, fmt.Errorf("scanning movie revision: %w", err)
+		}
+
+		totalRecords = revision.TotalRecords
+		revisions = append(revisions, &revision.MovieRevision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, fmt.Errorf("iterating over rows: %w", err)
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
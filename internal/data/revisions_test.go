@@ -0,0 +1,150 @@
+package data
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	t.Parallel()
+
+	from := &MovieRevision{
+		MovieID: 1, Version: 1, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Drama", "Sci-Fi"},
+	}
+
+	tests := []struct {
+		name string
+		to   MovieRevision
+		want []FieldChange
+	}{
+		{
+			name: "identical",
+			to:   *from,
+			want: []FieldChange{},
+		},
+		{
+			name: "identical but version",
+			to: MovieRevision{
+				MovieID: 1, Version: 2, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Drama", "Sci-Fi"},
+			},
+			want: []FieldChange{},
+		},
+		{
+			name: "genres reordered",
+			to: MovieRevision{
+				MovieID: 1, Version: 2, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Sci-Fi", "Drama"},
+			},
+			want: []FieldChange{},
+		},
+		{
+			name: "changed",
+			to: MovieRevision{
+				MovieID: 1, Version: 2, Title: "Arrival (2016)", Year: 2017, Runtime: 118, Genres: []string{"Sci-Fi"},
+			},
+			want: []FieldChange{
+				{Field: "title", From: "Arrival", To: "Arrival (2016)"},
+				{Field: "year", From: int32(2016), To: int32(2017)},
+				{Field: "runtime", From: Runtime(116), To: Runtime(118)},
+				{Field: "genres", From: pq.StringArray{"Drama", "Sci-Fi"}, To: pq.StringArray{"Sci-Fi"}},
+			},
+		},
+		{
+			name: "genre replaced",
+			to: MovieRevision{
+				MovieID: 1, Version: 2, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Drama", "Mystery"},
+			},
+			want: []FieldChange{
+				{Field: "genres", From: pq.StringArray{"Drama", "Sci-Fi"}, To: pq.StringArray{"Drama", "Mystery"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := DiffRevisions(from, &test.to); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMovieRevisionApply(t *testing.T) {
+	t.Parallel()
+
+	revision := &MovieRevision{
+		MovieID: 1, Version: 2, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Drama", "Sci-Fi"},
+	}
+
+	movie := &Movie{ID: 1, Version: 5, Title: "Arrival (2016)", Year: 2017, Runtime: 118, Genres: []string{"Sci-Fi"}}
+
+	revision.Apply(movie)
+
+	want := &Movie{ID: 1, Version: 5, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Drama", "Sci-Fi"}}
+	if !reflect.DeepEqual(movie, want) {
+		t.Errorf("got %+v want %+v", movie, want)
+	}
+
+	// Every field of the snapshot is restored: diffing against the revision finds nothing.
+	snapshot := &MovieRevision{
+		MovieID: movie.ID, Version: movie.Version, Title: movie.Title, Year: movie.Year,
+		Runtime: movie.Runtime, Genres: movie.Genres,
+	}
+	if changes := DiffRevisions(revision, snapshot); len(changes) != 0 {
+		t.Errorf("got changes %+v after applying the revision", changes)
+	}
+
+	movie.Genres[0] = "Mystery"
+
+	if revision.Genres[0] != "Drama" {
+		t.Errorf("got revision genres %v changed along with the movie", revision.Genres)
+	}
+}
+
+func TestRevisionsInsert(t *testing.T) {
+	t.Parallel()
+
+	movies := []*Movie{
+		{ID: 1, Version: 2, Title: "Arrival", Year: 2016, Runtime: 116, Genres: []string{"Drama"}},
+		{ID: 2, Version: 1, Title: "Dune", Year: 2021, Runtime: 155, Genres: []string{"Sci-Fi"}},
+	}
+
+	tests := []struct {
+		name   string
+		userID int64
+		want   sql.NullInt64
+	}{
+		{name: "user", userID: 7, want: sql.NullInt64{Int64: 7, Valid: true}},
+		{name: "no user", userID: 0, want: sql.NullInt64{}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, args := revisionsInsert(test.userID, movies...)
+
+			if !strings.HasSuffix(query, "VALUES ($1, $2, $3, $4, $5, $6, $7), ($8, $9, $10, $11, $12, $13, $14)") {
+				t.Errorf("got query %s", query)
+			}
+
+			want := []any{
+				int64(1), int32(2), test.want, "Arrival", int32(2016), Runtime(116), pq.Array(movies[0].Genres),
+				int64(2), int32(1), test.want, "Dune", int32(2021), Runtime(155), pq.Array(movies[1].Genres),
+			}
+
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("got args %v want %v", args, want)
+			}
+		})
+	}
+}
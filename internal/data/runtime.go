@@ -1,19 +1,100 @@
 package data
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
 )
 
-// ErrInvalidRuntimeFormat is returned when unmarshaling JSON.
-// The expected format is "\d+ mins".
+// ErrInvalidRuntimeFormat is returned when parsing a runtime.
+// The accepted formats are described in [ParseRuntime].
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
-// Runtime represents the duration of a movie.
+// CodeRuntime is the code of the error reported for runtimes that can't be parsed.
+const CodeRuntime = "runtime"
+
+// InvalidRuntime is the error reported for runtimes that can't be parsed.
+func InvalidRuntime() validator.FieldError {
+	return validator.FieldError{
+		Code:    CodeRuntime,
+		Message: "must be a number of minutes, hours and minutes or an ISO 8601 duration",
+	}
+}
+
+// Formats of runtimes sent to clients.
+const (
+	// RuntimeFormatMins is the default format, e.g. "102 mins".
+	RuntimeFormatMins RuntimeFormat = "mins"
+	// RuntimeFormatMinutes is a number of minutes, e.g. 102.
+	RuntimeFormatMinutes RuntimeFormat = "minutes"
+	// RuntimeFormatHours is a number of hours and minutes, e.g. "1h 42m".
+	RuntimeFormatHours RuntimeFormat = "hours"
+	// RuntimeFormatISO8601 is an ISO 8601 duration, e.g. "PT1H42M".
+	RuntimeFormatISO8601 RuntimeFormat = "iso8601"
+)
+
+// RuntimeFormats lists the known runtime formats.
+var RuntimeFormats = []RuntimeFormat{
+	RuntimeFormatMins, RuntimeFormatMinutes, RuntimeFormatHours, RuntimeFormatISO8601,
+}
+
+var (
+	runtimeMinsRX    = regexp.MustCompile(`(?i)^(\d+)(?: ?mins?)?$`)
+	runtimeHoursRX   = regexp.MustCompile(`(?i)^(?:(\d+) ?h)? ?(?:(\d+) ?m(?:ins?)?)?$`)
+	runtimeISO8601RX = regexp.MustCompile(`(?i)^PT(?:(\d+)H)?(?:(\d+)M)?$`)
+)
+
+// Runtime represents the duration of a movie, in minutes.
 type Runtime int32
 
+// RuntimeFormat is the format of runtimes sent to clients.
+// The zero value is [RuntimeFormatMins].
+type RuntimeFormat string
+
+// isDefault reports whether runtimes are sent as by [Runtime.MarshalJSON].
+func (f RuntimeFormat) isDefault() bool {
+	return f == "" || f == RuntimeFormatMins
+}
+
+// Formatted returns the runtime in the given format: a number for
+// [RuntimeFormatMinutes], a string otherwise.
+func (r Runtime) Formatted(format RuntimeFormat) any {
+	const minutesPerHour = 60
+
+	hours, minutes := r/minutesPerHour, r%minutesPerHour
+
+	switch format {
+	case RuntimeFormatMinutes:
+		return int32(r)
+	case RuntimeFormatHours:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("%dm", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("%dh", hours)
+		default:
+			return fmt.Sprintf("%dh %dm", hours, minutes)
+		}
+	case RuntimeFormatISO8601:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("PT%dM", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("PT%dH", hours)
+		default:
+			return fmt.Sprintf("PT%dH%dM", hours, minutes)
+		}
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
+
 // MarshalJSON implements json.Marshaler.
 // intentionally using a value receiver.
 func (r Runtime) MarshalJSON() ([]byte, error) {
@@ -25,29 +106,112 @@ func (r Runtime) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
+// Runtimes are either a number of minutes or a string accepted by [ParseRuntime].
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if minutes, err := strconv.ParseUint(string(jsonValue), 10, 31); err == nil { //nolint:gomnd
+		*r = Runtime(minutes)
+		return nil
+	}
+
+	value, err := strconv.Unquote(string(jsonValue))
 	if err != nil {
 		return ErrInvalidRuntimeFormat
 	}
 
-	parts := strings.Split(unquotedJSONValue, " ")
-
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRuntimeFormat
+	runtime, err := ParseRuntime(value)
+	if err != nil {
+		return err
 	}
 
-	const (
-		base    = 10
-		bitSize = 32
-	)
+	*r = runtime
+
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, for encodings other than JSON.
+func (r Runtime) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d mins", r)), nil
+}
 
-	i, err := strconv.ParseInt(parts[0], base, bitSize)
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *Runtime) UnmarshalText(text []byte) error {
+	runtime, err := ParseRuntime(string(text))
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
 
-	*r = Runtime(i)
+	*r = runtime
 
 	return nil
 }
+
+// Scan implements sql.Scanner.
+func (r *Runtime) Scan(src any) error {
+	switch src := src.(type) {
+	case int64:
+		if src < 0 || src > math.MaxInt32 {
+			return fmt.Errorf("%w: %d is out of range", ErrInvalidRuntimeFormat, src)
+		}
+
+		*r = Runtime(src)
+	case []byte:
+		return r.UnmarshalText(src)
+	case string:
+		return r.UnmarshalText([]byte(src))
+	case nil:
+		*r = 0
+	default:
+		return fmt.Errorf("%w: scanning %T", ErrInvalidRuntimeFormat, src)
+	}
+
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (r Runtime) Value() (driver.Value, error) {
+	return int64(r), nil
+}
+
+// ParseRuntime parses a runtime formatted as a number of minutes ("102", "102 mins"),
+// hours and minutes ("1h 42m", "1h42m", "2h") or an ISO 8601 duration ("PT1H42M", "PT102M").
+func ParseRuntime(value string) (Runtime, error) {
+	value = strings.TrimSpace(value)
+
+	var hours, minutes string
+
+	if match := runtimeMinsRX.FindStringSubmatch(value); match != nil {
+		minutes = match[1]
+	} else if match = runtimeHoursRX.FindStringSubmatch(value); match != nil && value != "" {
+		hours, minutes = match[1], match[2]
+	} else if match = runtimeISO8601RX.FindStringSubmatch(value); match != nil && len(value) > len("PT") {
+		hours, minutes = match[1], match[2]
+	} else {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	const minutesPerHour = 60
+
+	total := int64(0)
+
+	for _, part := range []struct {
+		value string
+		scale int64
+	}{{hours, minutesPerHour}, {minutes, 1}} {
+		if part.value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(part.value, 10, 32) //nolint:gomnd
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+
+		total += n * part.scale
+	}
+
+	if total > math.MaxInt32 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	return Runtime(total), nil
+}
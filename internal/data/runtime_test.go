@@ -0,0 +1,111 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    Runtime
+		wantErr bool
+	}{
+		{value: "102 mins", want: 102},
+		{value: "102 min", want: 102},
+		{value: "102", want: 102},
+		{value: "1h 42m", want: 102},
+		{value: "1h42m", want: 102},
+		{value: "2h", want: 120},
+		{value: "42m", want: 42},
+		{value: "PT102M", want: 102},
+		{value: "PT1H42M", want: 102},
+		{value: "pt2h", want: 120},
+		{value: "", wantErr: true},
+		{value: "PT", wantErr: true},
+		{value: "102 hours", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "1.5h", wantErr: true},
+		{value: "99999999999 mins", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseRuntime(test.value)
+
+		if test.wantErr {
+			if !errors.Is(err, ErrInvalidRuntimeFormat) {
+				t.Errorf("%q: got error %v want %v", test.value, err, ErrInvalidRuntimeFormat)
+			}
+
+			continue
+		}
+
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %d, %v want %d", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		js      string
+		want    Runtime
+		wantErr bool
+	}{
+		{js: `"102 mins"`, want: 102},
+		{js: `102`, want: 102},
+		{js: `"1h 42m"`, want: 102},
+		{js: `"PT102M"`, want: 102},
+		{js: `102.5`, wantErr: true},
+		{js: `-102`, wantErr: true},
+		{js: `true`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		var got Runtime
+
+		err := json.Unmarshal([]byte(test.js), &got)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %d want error", test.js, got)
+			}
+
+			continue
+		}
+
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %d, %v want %d", test.js, got, err, test.want)
+		}
+	}
+}
+
+func TestRuntimeFormatted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		runtime Runtime
+		format  RuntimeFormat
+		want    any
+	}{
+		{102, "", "102 mins"},
+		{102, RuntimeFormatMins, "102 mins"},
+		{102, RuntimeFormatMinutes, int32(102)},
+		{102, RuntimeFormatHours, "1h 42m"},
+		{120, RuntimeFormatHours, "2h"},
+		{42, RuntimeFormatHours, "42m"},
+		{102, RuntimeFormatISO8601, "PT1H42M"},
+		{120, RuntimeFormatISO8601, "PT2H"},
+	}
+
+	for _, test := range tests {
+		if got := test.runtime.Formatted(test.format); got != test.want {
+			t.Errorf("%d as %q: got %v want %v", test.runtime, test.format, got, test.want)
+		}
+	}
+}
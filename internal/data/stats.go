@@ -0,0 +1,166 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// runtimePercentiles are the percentiles of the runtime distribution computed by Stats.
+var runtimePercentiles = []float64{0.25, 0.5, 0.75, 0.9} //nolint:gomnd
+
+// MovieStats summarizes the movies matching some criteria.
+type MovieStats struct {
+	Total         int64         `json:"total"`
+	Genres        []GenreCount  `json:"genres"`
+	Decades       []DecadeCount `json:"decades"`
+	Runtime       RuntimeStats  `json:"runtime"`
+	RecentlyAdded RecentlyAdded `json:"recentlyAdded"`
+	ComputedAt    time.Time     `json:"computedAt"`
+}
+
+// GenreCount is the number of movies of a genre.
+type GenreCount struct {
+	Genre  string `db:"genre"  json:"genre"`
+	Movies int64  `db:"movies" json:"movies"`
+}
+
+// DecadeCount is the number of movies released during a decade,
+// identified by its first year, e.g. 1990.
+type DecadeCount struct {
+	Decade int32 `db:"decade" json:"decade"`
+	Movies int64 `db:"movies" json:"movies"`
+}
+
+// RuntimeStats describe the distribution of the runtimes of movies.
+// All values are zero when there are no movies.
+type RuntimeStats struct {
+	Min    Runtime `json:"min"`
+	P25    Runtime `json:"p25"`
+	Median Runtime `json:"median"`
+	P75    Runtime `json:"p75"`
+	P90    Runtime `json:"p90"`
+	Max    Runtime `json:"max"`
+
+	// RuntimeFormat is the format of the runtimes sent to the client.
+	RuntimeFormat RuntimeFormat `json:"-"`
+}
+
+// runtimeStats has the fields of RuntimeStats, without its MarshalJSON method.
+type runtimeStats RuntimeStats
+
+// MarshalJSON implements json.Marshaler, sending the runtimes in the requested format.
+func (s RuntimeStats) MarshalJSON() ([]byte, error) {
+	if s.RuntimeFormat.isDefault() {
+		return json.Marshal(runtimeStats(s)) //nolint:wrapcheck
+	}
+
+	format := s.RuntimeFormat
+
+	return json.Marshal(struct { //nolint:wrapcheck
+		Min    any `json:"min"`
+		P25    any `json:"p25"`
+		Median any `json:"median"`
+		P75    any `json:"p75"`
+		P90    any `json:"p90"`
+		Max    any `json:"max"`
+	}{
+		s.Min.Formatted(format), s.P25.Formatted(format), s.Median.Formatted(format),
+		s.P75.Formatted(format), s.P90.Formatted(format), s.Max.Formatted(format),
+	})
+}
+
+// RecentlyAdded counts the movies added to the catalogue recently.
+type RecentlyAdded struct {
+	Last7Days  int64 `db:"added_last_7_days"  json:"last7Days"`
+	Last30Days int64 `db:"added_last_30_days" json:"last30Days"`
+}
+
+// Stats computes statistics about the movies matching the criteria.
+// Deleted movies are ignored.
+func (m MovieModel) Stats(criteria MovieCriteria) (*MovieStats, error) {
+	var args queryArgs
+
+	where := criteria.where(&args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// All the statistics are read from the same snapshot, so they add up.
+	tx, err := m.DB.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	var summary struct {
+		Total       int64         `db:"total"`
+		MinRuntime  Runtime       `db:"min_runtime"`
+		MaxRuntime  Runtime       `db:"max_runtime"`
+		Percentiles pq.Int64Array `db:"percentiles"`
+		RecentlyAdded
+	}
+
+	summaryArgs := append(queryArgs{}, args...)
+
+	query := fmt.Sprintf(`
+		SELECT count(*) AS total,
+			COALESCE(min(runtime), 0) AS min_runtime,
+			COALESCE(max(runtime), 0) AS max_runtime,
+			percentile_disc(%s::float8[]) WITHIN GROUP (ORDER BY runtime) AS percentiles,
+			count(*) FILTER (WHERE created_at >= now() - interval '7 days') AS added_last_7_days,
+			count(*) FILTER (WHERE created_at >= now() - interval '30 days') AS added_last_30_days
+		FROM movies
+		WHERE %s`, summaryArgs.add(pq.Array(runtimePercentiles)), where)
+
+	err = tx.GetContext(ctx, &summary, query, summaryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("querying movie totals: %w", err)
+	}
+
+	stats := &MovieStats{
+		Total:         summary.Total,
+		Genres:        []GenreCount{},
+		Decades:       []DecadeCount{},
+		Runtime:       RuntimeStats{Min: summary.MinRuntime, Max: summary.MaxRuntime},
+		RecentlyAdded: summary.RecentlyAdded,
+	}
+
+	if len(summary.Percentiles) == len(runtimePercentiles) {
+		stats.Runtime.P25 = Runtime(summary.Percentiles[0])
+		stats.Runtime.Median = Runtime(summary.Percentiles[1])
+		stats.Runtime.P75 = Runtime(summary.Percentiles[2])
+		stats.Runtime.P90 = Runtime(summary.Percentiles[3])
+	}
+
+	query = `
+		SELECT genre, count(*) AS movies
+		FROM movies, unnest(movies.genres) AS genre
+		WHERE ` + where + `
+		GROUP BY genre
+		ORDER BY movies DESC, genre`
+
+	if err = tx.SelectContext(ctx, &stats.Genres, query, args...); err != nil {
+		return nil, fmt.Errorf("querying movie genres: %w", err)
+	}
+
+	query = `
+		SELECT year / 10 * 10 AS decade, count(*) AS movies
+		FROM movies
+		WHERE ` + where + `
+		GROUP BY decade
+		ORDER BY decade`
+
+	if err = tx.SelectContext(ctx, &stats.Decades, query, args...); err != nil {
+		return nil, fmt.Errorf("querying movie decades: %w", err)
+	}
+
+	stats.ComputedAt = time.Now()
+
+	return stats, nil
+}
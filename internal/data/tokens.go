@@ -20,6 +20,9 @@ const (
 	ScopeAuthentication = "authentication"
 )
 
+// CodeTokenInvalid is the code of the error reported for unknown or expired tokens.
+const CodeTokenInvalid = "token_invalid"
+
 // A Token is used to activate a User account.
 type Token struct {
 	Plaintext string    `json:"token"`
@@ -36,18 +39,36 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 		Scope:  scope,
 	}
 
+	var err error
+
+	token.Plaintext, token.Hash, err = randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// randomToken returns an unguessable plaintext token and its hash.
+// Only the hash is meant to be stored.
+func randomToken() (string, []byte, error) {
 	randomBytes := make([]byte, 16) //nolint:gomnd
 
 	_, err := rand.Read(randomBytes)
 	if err != nil {
-		return nil, fmt.Errorf("generating bytes: %w", err)
+		return "", nil, fmt.Errorf("generating bytes: %w", err)
 	}
 
-	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
-	hash := sha256.Sum256([]byte(token.Plaintext))
-	token.Hash = hash[:]
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
 
-	return token, nil
+	return plaintext, hashToken(plaintext), nil
+}
+
+// hashToken returns the hash stored for a plaintext token.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	return hash[:]
 }
 
 // ValidateTokenPlaintext validates a token.
@@ -57,8 +78,8 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 //
 //nolint:gomnd
 func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
-	v.Check(tokenPlaintext != "", "token", "must be provided")
-	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
+	v.CheckError(tokenPlaintext != "", "token", validator.Required())
+	v.CheckError(len(tokenPlaintext) == 26, "token", validator.Length(26))
 }
 
 // TokenModel implements methods to query the database.
@@ -0,0 +1,243 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Crocmagnon/greenlight/internal/validator"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// LocaleRX matches the BCP 47 language tags accepted as translation locales,
+// e.g. "fr", "pt-BR" or "zh-Hant-TW".
+var LocaleRX = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// searchConfigs maps languages to the Postgres text search configuration
+// used to index translations in that language.
+// Other languages are indexed with the "simple" configuration.
+var searchConfigs = map[string]string{
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nb": "norwegian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+// A MovieTranslation holds the title and synopsis of a movie in a given locale.
+type MovieTranslation struct {
+	MovieID   int64     `db:"movie_id"   json:"movieId"`
+	Locale    string    `db:"locale"     json:"locale"`
+	Title     string    `db:"title"      json:"title"`
+	Synopsis  string    `db:"synopsis"   json:"synopsis,omitempty"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+	Version   int32     `db:"version"    json:"version"`
+}
+
+// CanonicalLocale returns the canonical case of a language tag:
+// lowercase language, titlecase script and uppercase region, e.g. "zh-Hant-TW".
+func CanonicalLocale(tag string) string {
+	subtags := strings.Split(tag, "-")
+
+	for i, subtag := range subtags {
+		switch {
+		case i == 0:
+			subtags[i] = strings.ToLower(subtag)
+		case len(subtag) == 2: //nolint:gomnd
+			subtags[i] = strings.ToUpper(subtag)
+		case len(subtag) == 4: //nolint:gomnd
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		default:
+			subtags[i] = strings.ToLower(subtag)
+		}
+	}
+
+	return strings.Join(subtags, "-")
+}
+
+// searchConfig returns the text search configuration matching the language of the locale.
+func searchConfig(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+
+	if config, found := searchConfigs[language]; found {
+		return config
+	}
+
+	return "simple"
+}
+
+// CodeLanguageTag is the code of the error reported for invalid language tags.
+const CodeLanguageTag = "language_tag"
+
+// ValidateMovieTranslation validates a movie translation.
+// The passed validator will contain all detected errors.
+// The caller is expected to call [validator.Validator.Valid]
+// after this method.
+//
+//nolint:gomnd
+func ValidateMovieTranslation(v *validator.Validator, translation *MovieTranslation) {
+	v.CheckError(validator.Matches(translation.Locale, LocaleRX), "locale", validator.FieldError{
+		Code:    CodeLanguageTag,
+		Message: "must be a valid language tag",
+	})
+	v.CheckError(len(translation.Locale) <= 35, "locale", validator.MaxLength(35))
+
+	v.CheckError(translation.Title != "", "title", validator.Required())
+	v.CheckError(len(translation.Title) <= 500, "title", validator.MaxLength(500))
+
+	v.CheckError(len(translation.Synopsis) <= 5000, "synopsis", validator.MaxLength(5000))
+}
+
+// MovieTranslationModel implements methods to query the database.
+type MovieTranslationModel struct {
+	DB *sqlx.DB
+}
+
+// GetAllForMovie returns the translations of a movie, sorted by locale.
+func (m MovieTranslationModel) GetAllForMovie(movieID int64) ([]*MovieTranslation, error) {
+	query := `
+		SELECT movie_id, locale, title, synopsis, updated_at, version
+		FROM movie_translations
+		WHERE movie_id = $1
+		ORDER BY locale`
+
+	translations := []*MovieTranslation{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &translations, query, movieID)
+	if err != nil {
+		return nil, fmt.Errorf("querying movie translations: %w", err)
+	}
+
+	return translations, nil
+}
+
+// GetPreferred returns, for each of the given movies, its translation in the first
+// of the locales it's translated in. Locales are listed by decreasing preference.
+// Movies without a translation in any of the locales are absent from the returned map.
+func (m MovieTranslationModel) GetPreferred(locales []string, movieIDs ...int64) (map[int64]*MovieTranslation, error) {
+	translations := make(map[int64]*MovieTranslation, len(movieIDs))
+
+	if len(locales) == 0 || len(movieIDs) == 0 {
+		return translations, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (movie_id) movie_id, locale, title, synopsis, updated_at, version
+		FROM movie_translations
+		WHERE movie_id = ANY($1) AND locale = ANY($2)
+		ORDER BY movie_id, array_position($2, locale)`
+
+	var rows []*MovieTranslation
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.SelectContext(ctx, &rows, query, pq.Array(movieIDs), pq.Array(locales))
+	if err != nil {
+		return nil, fmt.Errorf("querying movie translations: %w", err)
+	}
+
+	for _, translation := range rows {
+		translations[translation.MovieID] = translation
+	}
+
+	return translations, nil
+}
+
+// Put creates or replaces the translation of a movie in the translation's locale.
+// It reports whether the translation was created.
+// Translation.Locale is canonicalized, and Translation.UpdatedAt and Translation.Version
+// are set on the passed translation.
+// ErrRecordNotFound is returned if the movie doesn't exist.
+func (m MovieTranslationModel) Put(translation *MovieTranslation) (bool, error) {
+	translation.Locale = CanonicalLocale(translation.Locale)
+
+	query := `
+		INSERT INTO movie_translations (movie_id, locale, title, synopsis, search_config)
+		SELECT id, $2, $3, $4, $5
+		FROM movies
+		WHERE id = $1 AND deleted_at IS NULL
+		ON CONFLICT (movie_id, locale) DO UPDATE
+		SET title = excluded.title,
+			synopsis = excluded.synopsis,
+			search_config = excluded.search_config,
+			updated_at = now(),
+			version = movie_translations.version + 1
+		RETURNING updated_at, version, version = 1 AS created`
+
+	args := []any{
+		translation.MovieID,
+		translation.Locale,
+		translation.Title,
+		translation.Synopsis,
+		searchConfig(translation.Locale),
+	}
+
+	var result struct {
+		UpdatedAt time.Time `db:"updated_at"`
+		Version   int32     `db:"version"`
+		Created   bool      `db:"created"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := m.DB.GetContext(ctx, &result, query, args...)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, ErrRecordNotFound
+	case err != nil:
+		return false, fmt.Errorf("saving movie translation: %w", err)
+	}
+
+	translation.UpdatedAt = result.UpdatedAt
+	translation.Version = result.Version
+
+	return result.Created, nil
+}
+
+// Delete deletes the translation of a movie in the given locale.
+func (m MovieTranslationModel) Delete(movieID int64, locale string) error {
+	query := `
+		DELETE FROM movie_translations
+		WHERE movie_id = $1 AND locale = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, CanonicalLocale(locale))
+	if err != nil {
+		return fmt.Errorf("deleting movie translation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -17,6 +17,9 @@ import (
 // if there's already another user with the same email address.
 var ErrDuplicateEmail = errors.New("duplicate email")
 
+// CodeDuplicateEmail is the code of the error reported for email addresses used by another user.
+const CodeDuplicateEmail = "duplicate_email"
+
 // AnonymousUser is a sentinel variable to check whether a user is authenticated or not.
 //
 //nolint:gochecknoglobals
@@ -77,8 +80,8 @@ func (p *password) Matches(plaintext string) (bool, error) {
 // The caller is expected to call [validator.Validator.Valid]
 // after this method.
 func ValidateEmail(v *validator.Validator, email string) {
-	v.Check(email != "", "email", "must be provided")
-	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+	v.CheckError(email != "", "email", validator.Required())
+	v.CheckError(validator.Matches(email, validator.EmailRX), "email", validator.InvalidEmail())
 }
 
 // ValidatePasswordPlaintext validates a plaintext password.
@@ -88,9 +91,9 @@ func ValidateEmail(v *validator.Validator, email string) {
 //
 //nolint:gomnd
 func ValidatePasswordPlaintext(v *validator.Validator, password string) {
-	v.Check(password != "", "password", "must be provided")
-	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
-	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+	v.CheckError(password != "", "password", validator.Required())
+	v.CheckError(len(password) >= 8, "password", validator.MinLength(8))
+	v.CheckError(len(password) <= 72, "password", validator.MaxLength(72))
 }
 
 // ValidateUser validates a user.
@@ -100,8 +103,8 @@ func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 //
 //nolint:gomnd
 func ValidateUser(v *validator.Validator, user *User) {
-	v.Check(user.Name != "", "name", "must be provided")
-	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.CheckError(user.Name != "", "name", validator.Required())
+	v.CheckError(len(user.Name) <= 500, "name", validator.MaxLength(500))
 
 	ValidateEmail(v, user.Email)
 
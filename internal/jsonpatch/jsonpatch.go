@@ -0,0 +1,293 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396)
+// and JSON Patch (RFC 6902) documents.
+//
+// Documents are the generic values produced by encoding/json when
+// decoding into an any: map[string]any, []any, string, float64, bool and nil.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Errors returned while applying a JSON Patch.
+var (
+	ErrUnsupportedOperation = errors.New("unsupported operation")
+	ErrInvalidPath          = errors.New("invalid path")
+	ErrPathNotFound         = errors.New("path not found")
+	ErrMissingValue         = errors.New("missing value")
+	ErrTestFailed           = errors.New("test failed")
+)
+
+// An Operation is a single JSON Patch operation.
+// Only add, remove, replace and test are supported:
+// From is only decoded to report move and copy as unsupported.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MergePatch applies a JSON Merge Patch to the target document and returns the result.
+// The target may be modified in place.
+func MergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+
+		targetObject[key] = MergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// Apply applies the JSON Patch operations in order to the document and returns the result.
+// Application stops at the first failing operation.
+// The document may be modified in place, even when an error is returned.
+func Apply(doc any, patch []Operation) (any, error) {
+	for i, operation := range patch {
+		var err error
+
+		doc, err = apply(doc, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func apply(doc any, operation Operation) (any, error) {
+	tokens, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return nil, ErrMissingValue
+		}
+
+		if err = json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, fmt.Errorf("decoding value: %w", err)
+		}
+	case "remove":
+	default:
+		return nil, ErrUnsupportedOperation
+	}
+
+	switch operation.Op {
+	case "add":
+		return add(doc, tokens, value)
+	case "remove":
+		return remove(doc, tokens)
+	case "replace":
+		doc, err = remove(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		return add(doc, tokens, value)
+	default: // test
+		return test(doc, tokens, value)
+	}
+}
+
+func add(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return walk(doc, tokens, func(container any, key string) (any, error) {
+		switch node := container.(type) {
+		case map[string]any:
+			node[key] = value
+			return node, nil
+		case []any:
+			if key == "-" {
+				return append(node, value), nil
+			}
+
+			index, err := arrayIndex(key, len(node)+1)
+			if err != nil {
+				return nil, err
+			}
+
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+
+			return node, nil
+		default:
+			return nil, ErrPathNotFound
+		}
+	})
+}
+
+func remove(doc any, tokens []string) (any, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	return walk(doc, tokens, func(container any, key string) (any, error) {
+		switch node := container.(type) {
+		case map[string]any:
+			if _, found := node[key]; !found {
+				return nil, ErrPathNotFound
+			}
+
+			delete(node, key)
+
+			return node, nil
+		case []any:
+			index, err := arrayIndex(key, len(node))
+			if err != nil {
+				return nil, err
+			}
+
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, ErrPathNotFound
+		}
+	})
+}
+
+func test(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		if !reflect.DeepEqual(doc, value) {
+			return nil, ErrTestFailed
+		}
+
+		return doc, nil
+	}
+
+	return walk(doc, tokens, func(container any, key string) (any, error) {
+		var current any
+
+		switch node := container.(type) {
+		case map[string]any:
+			var found bool
+
+			current, found = node[key]
+			if !found {
+				return nil, ErrPathNotFound
+			}
+		case []any:
+			index, err := arrayIndex(key, len(node))
+			if err != nil {
+				return nil, err
+			}
+
+			current = node[index]
+		default:
+			return nil, ErrPathNotFound
+		}
+
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrTestFailed
+		}
+
+		return container, nil
+	})
+}
+
+// walk follows all tokens but the last one from doc, and replaces the container
+// it reaches with the result of update, called with the last token.
+// It returns the updated document.
+func walk(doc any, tokens []string, update func(container any, key string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return update(doc, tokens[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, found := node[tokens[0]]
+		if !found {
+			return nil, ErrPathNotFound
+		}
+
+		child, err := walk(child, tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+
+		node[tokens[0]] = child
+
+		return node, nil
+	case []any:
+		index, err := arrayIndex(tokens[0], len(node))
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := walk(node[index], tokens[1:], update)
+		if err != nil {
+			return nil, err
+		}
+
+		node[index] = child
+
+		return node, nil
+	default:
+		return nil, ErrPathNotFound
+	}
+}
+
+// arrayIndex parses an array index token, which must be lower than length.
+func arrayIndex(token string, length int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, ErrInvalidPath
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, ErrInvalidPath
+	}
+
+	if index >= length {
+		return 0, ErrPathNotFound
+	}
+
+	return index, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference tokens.
+// The empty pointer references the whole document and returns no token.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, ErrInvalidPath
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+
+	for i, token := range tokens {
+		tokens[i] = unescaper.Replace(token)
+	}
+
+	return tokens, nil
+}
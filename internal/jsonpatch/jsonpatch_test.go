@@ -0,0 +1,104 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, document string) any {
+	t.Helper()
+
+	var value any
+
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+func TestMergePatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{"replace", `{"title":"a","year":1}`, `{"title":"b"}`, `{"title":"b","year":1}`},
+		{"remove", `{"title":"a","year":1}`, `{"year":null}`, `{"title":"a"}`},
+		{"replace array", `{"genres":["a","b"]}`, `{"genres":["c"]}`, `{"genres":["c"]}`},
+		{"nested", `{"a":{"b":1,"c":2}}`, `{"a":{"c":null,"d":3}}`, `{"a":{"b":1,"d":3}}`},
+		{"non object patch", `{"a":1}`, `["x"]`, `["x"]`},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := MergePatch(decode(t, test.target), decode(t, test.patch))
+			if want := decode(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	const doc = `{"title":"a","genres":["drama","comedy"]}`
+
+	tests := []struct {
+		name    string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{"replace", `[{"op":"replace","path":"/title","value":"b"}]`, `{"title":"b","genres":["drama","comedy"]}`, nil},
+		{"add to array", `[{"op":"add","path":"/genres/1","value":"sci-fi"}]`, `{"title":"a","genres":["drama","sci-fi","comedy"]}`, nil},
+		{"append to array", `[{"op":"add","path":"/genres/-","value":"sci-fi"}]`, `{"title":"a","genres":["drama","comedy","sci-fi"]}`, nil},
+		{"remove from array", `[{"op":"remove","path":"/genres/0"}]`, `{"title":"a","genres":["comedy"]}`, nil},
+		{"replace in array", `[{"op":"replace","path":"/genres/1","value":"horror"}]`, `{"title":"a","genres":["drama","horror"]}`, nil},
+		{"test then replace", `[{"op":"test","path":"/title","value":"a"},{"op":"replace","path":"/title","value":"b"}]`, `{"title":"b","genres":["drama","comedy"]}`, nil},
+		{"escaped path", `[{"op":"add","path":"/a~1b","value":1}]`, `{"title":"a","a/b":1,"genres":["drama","comedy"]}`, nil},
+		{"failed test", `[{"op":"test","path":"/title","value":"b"}]`, ``, ErrTestFailed},
+		{"missing key", `[{"op":"remove","path":"/year"}]`, ``, ErrPathNotFound},
+		{"out of bounds", `[{"op":"replace","path":"/genres/2","value":"x"}]`, ``, ErrPathNotFound},
+		{"invalid index", `[{"op":"remove","path":"/genres/01"}]`, ``, ErrInvalidPath},
+		{"missing value", `[{"op":"add","path":"/year"}]`, ``, ErrMissingValue},
+		{"unsupported", `[{"op":"move","from":"/title","path":"/year"}]`, ``, ErrUnsupportedOperation},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var patch []Operation
+
+			if err := json.Unmarshal([]byte(test.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Apply(decode(t, doc), patch)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v want %v", err, test.wantErr)
+			}
+
+			if test.wantErr != nil {
+				return
+			}
+
+			if want := decode(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v want %v", got, want)
+			}
+		})
+	}
+}
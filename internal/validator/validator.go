@@ -2,8 +2,10 @@
 package validator
 
 import (
+	"fmt"
 	"regexp"
 	"slices"
+	"strings"
 )
 
 // EmailRX defines the regular expression used to validate emails.
@@ -11,14 +13,48 @@ import (
 //nolint:lll
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
-// Validator contains a map of validation errors.
+// Codes of the errors reported by the constructors of this package.
+// Clients rely on them to localize messages, so they must never change.
+const (
+	CodeInvalid      = "invalid"
+	CodeRequired     = "required"
+	CodeMinLength    = "min_length"
+	CodeMaxLength    = "max_length"
+	CodeLength       = "length"
+	CodeGreaterThan  = "greater_than"
+	CodeMin          = "min"
+	CodeMax          = "max"
+	CodeMinItems     = "min_items"
+	CodeMaxItems     = "max_items"
+	CodeUnique       = "unique"
+	CodeOneOf        = "one_of"
+	CodeUnknownValue = "unknown_value"
+	CodeEmail        = "email"
+	CodeInteger      = "integer"
+	CodeBoolean      = "boolean"
+)
+
+// A FieldError describes why a field is invalid. The message is in English,
+// clients can build their own from the code and the parameters.
+type FieldError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// Errors maps the JSON pointers of invalid fields to their errors.
+type Errors map[string][]FieldError
+
+// Validator collects validation errors.
+// Nested validators add their errors to the same map, under their prefix.
 type Validator struct {
-	Errors map[string]string
+	Errors Errors
+	prefix string
 }
 
 // New is a helper which creates a new Validator instance with an empty errors map.
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{Errors: make(Errors)}
 }
 
 // Valid returns true if the errors map doesn't contain any entries.
@@ -26,21 +62,189 @@ func (v *Validator) Valid() bool {
 	return len(v.Errors) == 0
 }
 
-// AddError adds an error message to the map (so long as no entry already exists for
-// the given key).
-func (v *Validator) AddError(key, message string) {
-	if _, exists := v.Errors[key]; !exists {
-		v.Errors[key] = message
+// Add adds an error for the field at key, a JSON pointer or a key like releases[0].date,
+// relative to the prefix of the validator. The same error is only reported once per field.
+func (v *Validator) Add(key string, err FieldError) {
+	pointer := v.prefix + Pointer(key)
+
+	if !slices.ContainsFunc(v.Errors[pointer], func(existing FieldError) bool {
+		return existing.Code == err.Code && existing.Message == err.Message
+	}) {
+		v.Errors[pointer] = append(v.Errors[pointer], err)
 	}
 }
 
-// Check adds an error message to the map only if a validation check is not 'ok'.
+// AddError adds an error message for the field at key, see [Validator.Add].
+func (v *Validator) AddError(key, message string) {
+	v.Add(key, Invalid(message))
+}
+
+// Check adds an error message for the field at key only if a validation check is not 'ok'.
 func (v *Validator) Check(ok bool, key, message string) { //nolint:revive // "ok" is not a control-coupling flag.
 	if !ok {
 		v.AddError(key, message)
 	}
 }
 
+// CheckError adds the error for the field at key only if a validation check is not 'ok'.
+func (v *Validator) CheckError(ok bool, key string, err FieldError) { //nolint:revive
+	if !ok {
+		v.Add(key, err)
+	}
+}
+
+// Nested returns a validator for the value at key, adding its errors to v.
+func (v *Validator) Nested(key string) *Validator {
+	return &Validator{Errors: v.Errors, prefix: v.prefix + Pointer(key)}
+}
+
+// Each validates every element of values with a validator nested at their index under key.
+func Each[T any](v *Validator, key string, values []T, validate func(v *Validator, i int, value T)) {
+	nested := v.Nested(key)
+
+	for i, value := range values {
+		validate(nested.Nested(fmt.Sprint(i)), i, value)
+	}
+}
+
+// Pointer converts a key like releases[0].date to a JSON pointer like /releases/0/date.
+// Keys that are already JSON pointers, including the empty key, are returned as is.
+func Pointer(key string) string {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return key
+	}
+
+	escape := strings.NewReplacer("~", "~0", "/", "~1")
+
+	var pointer strings.Builder
+
+	for _, token := range strings.FieldsFunc(key, func(r rune) bool { return r == '.' || r == '[' || r == ']' }) {
+		pointer.WriteString("/" + escape.Replace(token))
+	}
+
+	return pointer.String()
+}
+
+// Invalid returns an error with the generic invalid code.
+func Invalid(message string) FieldError {
+	return FieldError{Code: CodeInvalid, Message: message}
+}
+
+// Required returns the error of missing values.
+func Required() FieldError {
+	return FieldError{Code: CodeRequired, Message: "must be provided"}
+}
+
+// MinLength returns the error of strings shorter than minLength bytes.
+func MinLength(minLength int) FieldError {
+	return FieldError{
+		Code:    CodeMinLength,
+		Message: fmt.Sprintf("must be at least %d bytes long", minLength),
+		Params:  map[string]any{"min": minLength},
+	}
+}
+
+// MaxLength returns the error of strings longer than maxLength bytes.
+func MaxLength(maxLength int) FieldError {
+	return FieldError{
+		Code:    CodeMaxLength,
+		Message: fmt.Sprintf("must not be more than %d bytes long", maxLength),
+		Params:  map[string]any{"max": maxLength},
+	}
+}
+
+// Length returns the error of strings that aren't exactly length bytes long.
+func Length(length int) FieldError {
+	return FieldError{
+		Code:    CodeLength,
+		Message: fmt.Sprintf("must be %d bytes long", length),
+		Params:  map[string]any{"length": length},
+	}
+}
+
+// GreaterThan returns the error of numbers lower than or equal to limit.
+func GreaterThan[T int | int32 | int64](limit T) FieldError {
+	return FieldError{
+		Code:    CodeGreaterThan,
+		Message: fmt.Sprintf("must be greater than %d", limit),
+		Params:  map[string]any{"limit": limit},
+	}
+}
+
+// Min returns the error of numbers lower than minValue.
+func Min[T int | int32 | int64](minValue T) FieldError {
+	return FieldError{
+		Code:    CodeMin,
+		Message: fmt.Sprintf("must be at least %d", minValue),
+		Params:  map[string]any{"min": minValue},
+	}
+}
+
+// Max returns the error of numbers greater than maxValue.
+func Max[T int | int32 | int64](maxValue T) FieldError {
+	return FieldError{
+		Code:    CodeMax,
+		Message: fmt.Sprintf("must be a maximum of %d", maxValue),
+		Params:  map[string]any{"max": maxValue},
+	}
+}
+
+// MinItems returns the error of lists with less than minItems values.
+func MinItems(minItems int) FieldError {
+	return FieldError{
+		Code:    CodeMinItems,
+		Message: fmt.Sprintf("must contain at least %d values", minItems),
+		Params:  map[string]any{"min": minItems},
+	}
+}
+
+// MaxItems returns the error of lists with more than maxItems values.
+func MaxItems(maxItems int) FieldError {
+	return FieldError{
+		Code:    CodeMaxItems,
+		Message: fmt.Sprintf("must not contain more than %d values", maxItems),
+		Params:  map[string]any{"max": maxItems},
+	}
+}
+
+// NotUnique returns the error of lists with duplicate values.
+func NotUnique() FieldError {
+	return FieldError{Code: CodeUnique, Message: "must not contain duplicate values"}
+}
+
+// OneOf returns the error of values that aren't one of the permitted values.
+func OneOf(permittedValues ...string) FieldError {
+	return FieldError{
+		Code:    CodeOneOf,
+		Message: "must be one of " + strings.Join(permittedValues, ", "),
+		Params:  map[string]any{"values": permittedValues},
+	}
+}
+
+// UnknownValue returns the error of values that aren't known.
+func UnknownValue(value string) FieldError {
+	return FieldError{
+		Code:    CodeUnknownValue,
+		Message: fmt.Sprintf("must be a known value, %q is unknown", value),
+		Params:  map[string]any{"value": value},
+	}
+}
+
+// InvalidEmail returns the error of invalid email addresses.
+func InvalidEmail() FieldError {
+	return FieldError{Code: CodeEmail, Message: "must be a valid email address"}
+}
+
+// Integer returns the error of values that aren't integers.
+func Integer() FieldError {
+	return FieldError{Code: CodeInteger, Message: "must be an integer value"}
+}
+
+// Boolean returns the error of values that aren't booleans.
+func Boolean() FieldError {
+	return FieldError{Code: CodeBoolean, Message: "must be a boolean value"}
+}
+
 // PermittedValue returns true if a specific value is in a list.
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	return slices.Contains(permittedValues, value)
@@ -0,0 +1,60 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPointer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", ""},
+		{"title", "/title"},
+		{"releases[0].date", "/releases/0/date"},
+		{"externalIds.imdb", "/externalIds/imdb"},
+		{"a/b~c", "/a~1b~0c"},
+		{"/genres/2", "/genres/2"},
+	}
+
+	for _, test := range tests {
+		if got := Pointer(test.key); got != test.want {
+			t.Errorf("%q: got %q want %q", test.key, got, test.want)
+		}
+	}
+}
+
+func TestValidator(t *testing.T) {
+	t.Parallel()
+
+	v := New()
+
+	v.Add("title", Required())
+	v.Add("title", Required())
+	v.Add("title", MaxLength(500))
+	v.Check(true, "year", "must be provided")
+
+	Each(v, "releases", []string{"FR", "", "US"}, func(v *Validator, _ int, country string) {
+		v.CheckError(country != "", "country", Required())
+	})
+
+	nested := v.Nested("externalIds")
+	nested.AddError("imdb", "must be an IMDb title identifier")
+
+	want := Errors{
+		"/title":              {Required(), MaxLength(500)},
+		"/releases/1/country": {Required()},
+		"/externalIds/imdb":   {Invalid("must be an IMDb title identifier")},
+	}
+
+	if !reflect.DeepEqual(v.Errors, want) {
+		t.Errorf("got %v want %v", v.Errors, want)
+	}
+
+	if nested.Valid() || v.Valid() {
+		t.Error("got valid validators")
+	}
+}
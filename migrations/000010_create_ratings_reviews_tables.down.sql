@@ -0,0 +1,10 @@
+DELETE FROM permissions WHERE code IN ('reviews:write', 'reviews:moderate');
+
+DROP TABLE IF EXISTS review_revisions;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS ratings;
+
+DROP INDEX IF EXISTS movies_rating_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_sum;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
@@ -0,0 +1,53 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_sum bigint NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating real GENERATED ALWAYS AS (
+    CASE WHEN rating_count = 0 THEN 0 ELSE rating_sum::real / rating_count END
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_rating_idx ON movies (rating);
+
+CREATE TABLE IF NOT EXISTS ratings (
+    movie_id   bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id    bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    rating     integer                     NOT NULL CHECK (rating BETWEEN 1 AND 10),
+    PRIMARY KEY (movie_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id           bigserial PRIMARY KEY,
+    movie_id     bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id      bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at   timestamp(0) with time zone NOT NULL DEFAULT now(),
+    updated_at   timestamp(0) with time zone NOT NULL DEFAULT now(),
+    body         text                        NOT NULL,
+    status       text                        NOT NULL DEFAULT 'published'
+        CHECK (status IN ('published', 'hidden')),
+    moderated_by bigint                      REFERENCES users ON DELETE SET NULL,
+    moderated_at timestamp(0) with time zone,
+    version      integer                     NOT NULL DEFAULT 1,
+    UNIQUE (movie_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS review_revisions (
+    review_id  bigint                      NOT NULL REFERENCES reviews ON DELETE CASCADE,
+    version    integer                     NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    body       text                        NOT NULL,
+    PRIMARY KEY (review_id, version)
+);
+
+INSERT INTO permissions (code)
+VALUES
+    ('reviews:write'),
+    ('reviews:moderate');
+
+-- Everyone who could read the catalogue can now review it.
+INSERT INTO users_permissions
+SELECT up.user_id, p.id
+FROM users_permissions AS up
+    JOIN permissions AS rp ON rp.id = up.permission_id AND rp.code = 'movies:read'
+    CROSS JOIN permissions AS p
+WHERE p.code = 'reviews:write'
+ON CONFLICT DO NOTHING;
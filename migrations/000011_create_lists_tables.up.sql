@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS lists (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint                      NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    kind       text                        NOT NULL CHECK (kind IN ('watchlist', 'watched', 'collection')),
+    name       text                        NOT NULL,
+    public     boolean                     NOT NULL DEFAULT false,
+    share_hash bytea UNIQUE,
+    version    integer                     NOT NULL DEFAULT 1
+);
+
+-- Each user has at most one watchlist and one watched list.
+CREATE UNIQUE INDEX IF NOT EXISTS lists_user_kind_idx ON lists (user_id, kind) WHERE kind <> 'collection';
+
+CREATE TABLE IF NOT EXISTS list_items (
+    list_id  bigint                      NOT NULL REFERENCES lists ON DELETE CASCADE,
+    movie_id bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    position integer                     NOT NULL CHECK (position >= 0),
+    PRIMARY KEY (list_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS list_items_position_idx ON list_items (list_id, position);
+CREATE INDEX IF NOT EXISTS list_items_movie_idx ON list_items (movie_id);
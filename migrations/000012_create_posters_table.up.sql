@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS posters (
+    movie_id      bigint                      PRIMARY KEY REFERENCES movies ON DELETE CASCADE,
+    uploaded_at   timestamp(0) with time zone NOT NULL DEFAULT now(),
+    key           text                        NOT NULL,
+    content_type  text                        NOT NULL,
+    size          bigint                      NOT NULL,
+    width         integer                     NOT NULL,
+    height        integer                     NOT NULL,
+    thumbnail_key text
+);
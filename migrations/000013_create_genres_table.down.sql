@@ -0,0 +1,4 @@
+-- Movies keep their canonical genre names.
+DELETE FROM permissions WHERE code = 'genres:admin';
+
+DROP TABLE IF EXISTS genres;
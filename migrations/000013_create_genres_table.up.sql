@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id         bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    name       text                        NOT NULL UNIQUE,
+    slug       text                        NOT NULL UNIQUE,
+    aliases    text[]                      NOT NULL DEFAULT '{}',
+    version    integer                     NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING GIN (aliases);
+
+-- Seed the vocabulary from the existing movies: spellings sharing a slug
+-- (e.g. "Sci-Fi" and "sci-fi") collapse onto the most used one.
+WITH spellings AS (
+    SELECT trim(genre) AS name,
+        trim(BOTH '-' FROM lower(regexp_replace(trim(genre), '[^[:alnum:]]+', '-', 'g'))) AS slug,
+        count(*) AS uses
+    FROM movies, unnest(genres) AS genre
+    GROUP BY 1, 2
+)
+INSERT INTO genres (name, slug)
+SELECT DISTINCT ON (slug) name, slug
+FROM spellings
+WHERE slug <> ''
+ORDER BY slug, uses DESC, name;
+
+-- Rewrite the movies with the canonical names, dropping the duplicates this creates.
+UPDATE movies AS m
+SET genres = ARRAY(
+    SELECT name
+    FROM (
+        SELECT g.name, min(t.ord) AS ord
+        FROM unnest(m.genres) WITH ORDINALITY AS t(genre, ord)
+        JOIN genres AS g
+            ON g.slug = trim(BOTH '-' FROM lower(regexp_replace(trim(t.genre), '[^[:alnum:]]+', '-', 'g')))
+        GROUP BY g.name
+    ) AS canonical
+    ORDER BY ord
+);
+
+INSERT INTO permissions (code)
+VALUES ('genres:admin');
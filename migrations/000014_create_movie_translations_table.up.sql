@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS movie_translations (
+    movie_id      bigint                      NOT NULL REFERENCES movies ON DELETE CASCADE,
+    locale        text                        NOT NULL,
+    title         text                        NOT NULL,
+    synopsis      text                        NOT NULL DEFAULT '',
+    search_config regconfig                   NOT NULL DEFAULT 'simple',
+    updated_at    timestamp(0) with time zone NOT NULL DEFAULT now(),
+    version       integer                     NOT NULL DEFAULT 1,
+    PRIMARY KEY (movie_id, locale)
+);
+
+CREATE INDEX IF NOT EXISTS movie_translations_search_idx
+    ON movie_translations USING GIN (to_tsvector(search_config, title || ' ' || synopsis));
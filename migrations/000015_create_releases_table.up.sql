@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS releases (
+    movie_id      bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country       text   NOT NULL CHECK (country ~ '^[A-Z]{2}$'),
+    kind          text   NOT NULL CHECK (kind IN ('theatrical', 'streaming', 'physical')),
+    date          date   NOT NULL,
+    certification text   NOT NULL DEFAULT '',
+    PRIMARY KEY (movie_id, country, kind)
+);
+
+CREATE INDEX IF NOT EXISTS releases_country_date_idx ON releases (country, date);
+CREATE INDEX IF NOT EXISTS releases_date_idx ON releases (date);
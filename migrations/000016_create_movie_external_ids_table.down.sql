@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_title_key_idx;
+DROP FUNCTION IF EXISTS movie_title_key(text);
+DROP TABLE IF EXISTS movie_external_ids;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+    movie_id    bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source      text   NOT NULL CHECK (source IN ('imdb', 'tmdb', 'wikidata')),
+    external_id text   NOT NULL,
+    PRIMARY KEY (movie_id, source),
+    UNIQUE (source, external_id)
+);
+
+-- movie_title_key normalizes titles to find likely duplicates:
+-- "The Matrix" and "the matrix!" share the "thematrix" key.
+CREATE OR REPLACE FUNCTION movie_title_key(title text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT lower(regexp_replace(title, '[^[:alnum:]]+', '', 'g')) $$;
+
+CREATE INDEX IF NOT EXISTS movies_title_key_idx ON movies (movie_title_key(title), year);
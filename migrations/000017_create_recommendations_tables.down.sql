@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_recommendations;
+DROP TABLE IF EXISTS movie_similarities;
+DROP FUNCTION IF EXISTS genre_jaccard(text[], text[]);
@@ -0,0 +1,29 @@
+-- genre_jaccard is the Jaccard index of two genre sets:
+-- the number of shared genres over the number of distinct genres.
+CREATE OR REPLACE FUNCTION genre_jaccard(a text[], b text[]) RETURNS double precision
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$
+        SELECT CASE WHEN cardinality(a) + cardinality(b) = 0 THEN 0 ELSE
+            (SELECT count(*) FROM (SELECT unnest(a) INTERSECT SELECT unnest(b)) AS shared)::double precision
+            / (SELECT count(*) FROM (SELECT unnest(a) UNION SELECT unnest(b)) AS distinct_genres)
+        END
+    $$;
+
+-- Both tables are precomputed in the background and fully replaced on each refresh.
+CREATE TABLE IF NOT EXISTS movie_similarities (
+    movie_id   bigint           NOT NULL REFERENCES movies ON DELETE CASCADE,
+    similar_id bigint           NOT NULL REFERENCES movies ON DELETE CASCADE,
+    score      double precision NOT NULL,
+    PRIMARY KEY (movie_id, similar_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_similarities_score_idx ON movie_similarities (movie_id, score DESC);
+
+CREATE TABLE IF NOT EXISTS user_recommendations (
+    user_id  bigint           NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint           NOT NULL REFERENCES movies ON DELETE CASCADE,
+    score    double precision NOT NULL,
+    PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_recommendations_score_idx ON user_recommendations (user_id, score DESC);